@@ -1,7 +1,10 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"os"
 	"os/exec"
 	"strings"
 )
@@ -25,3 +28,47 @@ func runCmd(ctx context.Context, name string, args ...string) ([]byte, error) {
 	}
 	return output, err
 }
+
+// Writes `input` on the standard input of the command, only the standard
+// output is returned and the standard error is logged
+func runCmdWithInput(ctx context.Context, input []byte, name string, args ...string) ([]byte, error) {
+	return runCmdWithEnv(ctx, nil, input, name, args...)
+}
+
+// Same as runCmdWithInput with `env` added to the environment of the command
+func runCmdWithEnv(ctx context.Context, env []string, input []byte, name string, args ...string) ([]byte, error) {
+	log := LoggerFromContext(ctx)
+
+	cmd := exec.Command(name, args...)
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	cmd.Stdin = bytes.NewReader(input)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		// Output only fills the stderr of the error when it is not redirected
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			exitErr.Stderr = stderr.Bytes()
+		}
+		log.Error("failed to run command",
+			"command", name,
+			"args", strings.Join(args, " "),
+			"env", env,
+			"input", string(input[:]),
+			"output", string(output[:]),
+			"stderr", stderr.String(),
+			"error", err)
+	} else {
+		log.Debug("run command",
+			"command", name,
+			"args", strings.Join(args, " "),
+			"env", env,
+			"input", string(input[:]),
+			"output", string(output[:]),
+			"stderr", stderr.String())
+	}
+	return output, err
+}
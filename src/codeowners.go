@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+type CodeOwnersGroupBy string
+
+const (
+	GroupByOwner CodeOwnersGroupBy = "owner"
+	GroupByRule  CodeOwnersGroupBy = "rule"
+)
+
+// Locations searched when `settings.codeOwners.path` is not set
+var defaultCodeOwnersPaths = []string{
+	".github/CODEOWNERS",
+	"CODEOWNERS",
+	"docs/CODEOWNERS",
+	".gitlab/CODEOWNERS",
+}
+
+type codeOwnersRule struct {
+	pattern string
+	owners  []string
+}
+
+var unsafeNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+func importCodeOwners(ctx context.Context, bigChange *BigChange) error {
+	log := LoggerFromContext(ctx)
+	codeOwners := bigChange.Settings.CodeOwners
+
+	rawCodeOwners, path, err := readCodeOwners(codeOwners.Path)
+	if err != nil {
+		log.Error("failed to read CODEOWNERS file", "path", codeOwners.Path, "error", err)
+		return err
+	}
+
+	rules := parseCodeOwners(rawCodeOwners)
+	domains := codeOwnersDomains(rules, codeOwners.GroupBy)
+	for _, domain := range domains {
+		if err := validateDomainPaths(ctx, domain, "Domain.Paths", domain.Paths); err != nil {
+			return err
+		}
+	}
+	log.Debug("domains imported from CODEOWNERS", "path", path, "rules", len(rules), "domains", len(domains))
+
+	// Hand written domains keep precedence over the imported ones
+	bigChange.Domains = append(bigChange.Domains, domains...)
+	return nil
+}
+
+func readCodeOwners(path string) ([]byte, string, error) {
+	if path != "" {
+		rawCodeOwners, err := os.ReadFile(path)
+		return rawCodeOwners, path, err
+	}
+
+	for _, defaultPath := range defaultCodeOwnersPaths {
+		rawCodeOwners, err := os.ReadFile(defaultPath)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		return rawCodeOwners, defaultPath, err
+	}
+	return nil, "", fmt.Errorf("no CODEOWNERS file found in %s", strings.Join(defaultCodeOwnersPaths, ", "))
+}
+
+func parseCodeOwners(rawCodeOwners []byte) []codeOwnersRule {
+	var rules []codeOwnersRule
+
+	scanner := bufio.NewScanner(bytes.NewReader(rawCodeOwners))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		// Comments, empty lines and GitLab sections headers
+		if line == "" || strings.HasPrefix(line, "#") ||
+			strings.HasPrefix(line, "[") || strings.HasPrefix(line, "^[") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		rule := codeOwnersRule{pattern: strings.ReplaceAll(fields[0], `\#`, "#")}
+		for _, owner := range fields[1:] {
+			if strings.HasPrefix(owner, "#") {
+				break
+			}
+			rule.owners = append(rule.owners, owner)
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// CODEOWNERS patterns follow gitignore rules, they are converted to exact doublestar
+// globs: a pattern matches the file or the folder of that name with everything below
+// it, while a trailing `*` does not cross folders
+func codeOwnersPatternToDomainPath(pattern string) string {
+	if pattern == "*" || pattern == "/*" || pattern == "/" {
+		return "**"
+	}
+
+	anchored := strings.Contains(strings.TrimSuffix(pattern, "/"), "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+	if !anchored && !strings.HasPrefix(pattern, "**/") {
+		pattern = "**/" + pattern
+	}
+	switch {
+	case strings.HasSuffix(pattern, "/"):
+		return pattern + "**"
+	case strings.HasSuffix(pattern, "/*"), strings.HasSuffix(pattern, "/**"):
+		return pattern
+	}
+	return fmt.Sprintf("{%[1]s,%[1]s/**}", pattern)
+}
+
+var codeOwnersGlobChars = regexp.MustCompile(`\*\*|\*|\?|\[[^\]]*\]`)
+
+// A later rule overrides an earlier one when it matches everything the earlier one
+// does, checked on a path built from the earlier pattern. On partial overlaps the
+// earlier rule is kept as an exclusion
+func codeOwnersRuleOverrides(laterPath string, earlierPath string) bool {
+	sample, _, _ := strings.Cut(strings.TrimPrefix(earlierPath, "{"), ",")
+	sample = codeOwnersGlobChars.ReplaceAllString(strings.TrimSuffix(sample, "/**"), "x")
+	return doublestar.MatchUnvalidated(laterPath, sample) &&
+		doublestar.MatchUnvalidated(laterPath, sample+"/x")
+}
+
+// In CODEOWNERS the last matching rule wins while domains are evaluated top
+// to bottom, so domains are generated from the last rule to the first one
+func codeOwnersDomains(rules []codeOwnersRule, groupBy CodeOwnersGroupBy) []*Domain {
+	if groupBy == GroupByRule {
+		domains := make([]*Domain, 0, len(rules))
+		for i := len(rules) - 1; i >= 0; i-- {
+			rule := rules[i]
+			domains = append(domains, &Domain{
+				Name:       codeOwnersDomainName(rule.pattern, fmt.Sprintf("rule-%d", i+1)),
+				Id:         fmt.Sprintf("CO%d", i+1),
+				Paths:      []string{codeOwnersPatternToDomainPath(rule.pattern)},
+				Teams:      codeOwnersTeams(rule.owners),
+				ExactPaths: true,
+			})
+		}
+		return dedupDomainNames(domains)
+	}
+
+	// Group the rules sharing the same owners, the group containing the
+	// highest precedence rule comes first
+	type ownersGroup struct {
+		domain    *Domain
+		firstRule int
+		lastRule  int
+	}
+	var groups []*ownersGroup
+	groupByOwners := make(map[string]*ownersGroup)
+	for i := len(rules) - 1; i >= 0; i-- {
+		rule := rules[i]
+		ownersKey := strings.Join(rule.owners, " ")
+		group, found := groupByOwners[ownersKey]
+		if !found {
+			name := "unowned"
+			if len(rule.owners) > 0 {
+				name = ownersKey
+			}
+			group = &ownersGroup{
+				domain: &Domain{
+					Name:       codeOwnersDomainName(name, fmt.Sprintf("owner-%d", len(groups)+1)),
+					Id:         fmt.Sprintf("CO%d", len(groups)+1),
+					Teams:      codeOwnersTeams(rule.owners),
+					ExactPaths: true,
+				},
+				lastRule: i,
+			}
+			groupByOwners[ownersKey] = group
+			groups = append(groups, group)
+		}
+		group.firstRule = i
+		group.domain.Paths = append(group.domain.Paths, codeOwnersPatternToDomainPath(rule.pattern))
+	}
+
+	// Rules of other owners placed between the first and the last rule of a
+	// group override the group broader rules, unless a later rule of the group
+	// claims their paths again. Rules placed after the last one are already
+	// handled by the domains order
+	domains := make([]*Domain, 0, len(groups))
+	for _, group := range groups {
+		for i := group.firstRule + 1; i < group.lastRule; i++ {
+			if groupByOwners[strings.Join(rules[i].owners, " ")] == group {
+				continue
+			}
+			excludePath := codeOwnersPatternToDomainPath(rules[i].pattern)
+			overridden := false
+			for j := i + 1; j <= group.lastRule && !overridden; j++ {
+				overridden = groupByOwners[strings.Join(rules[j].owners, " ")] == group &&
+					codeOwnersRuleOverrides(codeOwnersPatternToDomainPath(rules[j].pattern), excludePath)
+			}
+			if !overridden {
+				group.domain.ExcludePaths = append(group.domain.ExcludePaths, excludePath)
+			}
+		}
+		domains = append(domains, group.domain)
+	}
+	return dedupDomainNames(domains)
+}
+
+func codeOwnersTeams(owners []string) []Team {
+	teams := make([]Team, 0, len(owners))
+	for _, owner := range owners {
+		teams = append(teams, Team{Name: owner})
+	}
+	return teams
+}
+
+// Domain names are used in branch names templates so they are kept git friendly
+func codeOwnersDomainName(raw string, fallback string) string {
+	name := strings.Trim(unsafeNameChars.ReplaceAllString(raw, "-"), "-.")
+	if name == "" {
+		return fallback
+	}
+	return name
+}
+
+func dedupDomainNames(domains []*Domain) []*Domain {
+	seen := make(map[string]int)
+	for _, domain := range domains {
+		seen[domain.Name]++
+		if count := seen[domain.Name]; count > 1 {
+			domain.Name = fmt.Sprintf("%s-%d", domain.Name, count)
+		}
+	}
+	return domains
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const codeOwnersFixture = `# Global owners
+*                   @org/platform
+
+# Frontend
+/apps/web/          @org/frontend @alice
+*.sql               @org/dba
+docs/               @org/platform
+/apps/web/legacy/   # no owners
+`
+
+var codeOwnersPatternTests = []struct {
+	pattern            string
+	expectedDomainPath string
+}{
+	{pattern: "*", expectedDomainPath: "**"},
+	{pattern: "/apps/web/", expectedDomainPath: "apps/web/**"},
+	{pattern: "apps/web", expectedDomainPath: "{apps/web,apps/web/**}"},
+	{pattern: "/src/app", expectedDomainPath: "{src/app,src/app/**}"},
+	{pattern: "docs/*", expectedDomainPath: "docs/*"},
+	{pattern: "*.sql", expectedDomainPath: "{**/*.sql,**/*.sql/**}"},
+	{pattern: "docs/", expectedDomainPath: "**/docs/**"},
+	{pattern: "**/logs", expectedDomainPath: "{**/logs,**/logs/**}"},
+}
+
+func TestCodeOwnersPatternToDomainPath(t *testing.T) {
+	for _, tt := range codeOwnersPatternTests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			gotDomainPath := codeOwnersPatternToDomainPath(tt.pattern)
+
+			if gotDomainPath != tt.expectedDomainPath {
+				t.Errorf("got '%v', want '%v'", gotDomainPath, tt.expectedDomainPath)
+			}
+		})
+	}
+}
+
+var codeOwnersDomainsTests = []struct {
+	description     string
+	groupBy         CodeOwnersGroupBy
+	expectedDomains []*Domain
+}{
+	{
+		description: "One domain per rule",
+		groupBy:     GroupByRule,
+		expectedDomains: []*Domain{
+			{Name: "apps-web-legacy", Id: "CO5", Paths: []string{"apps/web/legacy/**"}, Teams: []Team{}, ExactPaths: true},
+			{Name: "docs", Id: "CO4", Paths: []string{"**/docs/**"}, Teams: []Team{{Name: "@org/platform"}}, ExactPaths: true},
+			{Name: "sql", Id: "CO3", Paths: []string{"{**/*.sql,**/*.sql/**}"}, Teams: []Team{{Name: "@org/dba"}}, ExactPaths: true},
+			{Name: "apps-web", Id: "CO2", Paths: []string{"apps/web/**"}, Teams: []Team{{Name: "@org/frontend"}, {Name: "@alice"}}, ExactPaths: true},
+			{Name: "rule-1", Id: "CO1", Paths: []string{"**"}, Teams: []Team{{Name: "@org/platform"}}, ExactPaths: true},
+		},
+	},
+	{
+		description: "One domain per owner",
+		groupBy:     GroupByOwner,
+		expectedDomains: []*Domain{
+			{Name: "unowned", Id: "CO1", Paths: []string{"apps/web/legacy/**"}, Teams: []Team{}, ExactPaths: true},
+			{
+				Name:         "org-platform",
+				Id:           "CO2",
+				Paths:        []string{"**/docs/**", "**"},
+				ExcludePaths: []string{"apps/web/**", "{**/*.sql,**/*.sql/**}"},
+				Teams:        []Team{{Name: "@org/platform"}},
+				ExactPaths:   true,
+			},
+			{Name: "org-dba", Id: "CO3", Paths: []string{"{**/*.sql,**/*.sql/**}"}, Teams: []Team{{Name: "@org/dba"}}, ExactPaths: true},
+			{Name: "org-frontend-alice", Id: "CO4", Paths: []string{"apps/web/**"}, Teams: []Team{{Name: "@org/frontend"}, {Name: "@alice"}}, ExactPaths: true},
+		},
+	},
+}
+
+func TestCodeOwnersDomains(t *testing.T) {
+	for _, tt := range codeOwnersDomainsTests {
+		t.Run(tt.description, func(t *testing.T) {
+			gotDomains := codeOwnersDomains(parseCodeOwners([]byte(codeOwnersFixture)), tt.groupBy)
+
+			diff := cmp.Diff(gotDomains, tt.expectedDomains)
+			if diff != "" {
+				t.Errorf("%v", diff)
+			}
+		})
+	}
+}
+
+var codeOwnersLastRuleWinsTests = []struct {
+	description   string
+	codeOwners    string
+	files         []string
+	expectedFiles map[string][]string
+}{
+	{
+		description: "Rules of other owners are excluded",
+		codeOwners:  codeOwnersFixture,
+		files:       []string{"apps/web/main.ts", "apps/web/db/init.sql", "apps/web/legacy/old.js", "docs/index.md", "go.mod"},
+		expectedFiles: map[string][]string{
+			"unowned":            {"apps/web/legacy/old.js"},
+			"org-platform":       {"docs/index.md", "go.mod"},
+			"org-dba":            {"apps/web/db/init.sql"},
+			"org-frontend-alice": {"apps/web/main.ts"},
+		},
+	},
+	{
+		description: "A later rule of the owner claims the excluded paths again",
+		codeOwners:  "* @a\ndocs/internal/ @b\ndocs/ @a\n",
+		files:       []string{"docs/internal/x.md", "src/main.go"},
+		expectedFiles: map[string][]string{
+			"a": {"docs/internal/x.md", "src/main.go"},
+		},
+	},
+	{
+		description: "A later rule of the owner partially overlapping keeps the exclusion",
+		codeOwners:  "* @a\ndocs/internal/ @b\ndocs/*.md @a\n",
+		files:       []string{"docs/internal/x.md", "docs/index.md"},
+		expectedFiles: map[string][]string{
+			"a": {"docs/index.md"},
+			"b": {"docs/internal/x.md"},
+		},
+	},
+	{
+		description: "Anchored paths are not prefixes and trailing stars do not cross folders",
+		codeOwners:  "* @a\n/src/app @b\ndocs/* @c\n",
+		files:       []string{"src/app", "src/app/main.go", "src/apple.go", "docs/y.md", "docs/sub/y.md"},
+		expectedFiles: map[string][]string{
+			"a": {"src/apple.go", "docs/sub/y.md"},
+			"b": {"src/app", "src/app/main.go"},
+			"c": {"docs/y.md"},
+		},
+	},
+}
+
+func TestCodeOwnersLastRuleWins(t *testing.T) {
+	for _, tt := range codeOwnersLastRuleWinsTests {
+		t.Run(tt.description, func(t *testing.T) {
+			domains := codeOwnersDomains(parseCodeOwners([]byte(tt.codeOwners)), GroupByOwner)
+
+			assignFiles(domains, tt.files, FirstMatch)
+
+			gotFiles := make(map[string][]string)
+			for _, domain := range domains {
+				if len(domain.Files) > 0 {
+					gotFiles[domain.Name] = domain.Files
+				}
+			}
+			diff := cmp.Diff(gotFiles, tt.expectedFiles)
+			if diff != "" {
+				t.Errorf("%v", diff)
+			}
+		})
+	}
+}
+
+func TestImportCodeOwners(t *testing.T) {
+	ctxWithSilentLogger := ContextWithSilentLogger(context.Background())
+	codeOwnersPath := filepath.Join(t.TempDir(), "CODEOWNERS")
+	if err := os.WriteFile(codeOwnersPath, []byte("/apps/ @org/apps\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	bigChange := fixtureBigChange(func(bc *BigChange) {
+		bc.Domains = bc.Domains[:1]
+		bc.Settings.CodeOwners = &CodeOwners{Path: codeOwnersPath, GroupBy: GroupByOwner}
+	})
+	gotErr := importCodeOwners(ctxWithSilentLogger, bigChange)
+	if gotErr != nil {
+		t.Errorf("got '%v', want '<nil>'", gotErr)
+	}
+
+	diff := cmp.Diff(bigChange.Domains, []*Domain{
+		fixtureBigChange().Domains[0],
+		{Name: "org-apps", Id: "CO1", Paths: []string{"apps/**"}, Teams: []Team{{Name: "@org/apps"}}, ExactPaths: true},
+	})
+	if diff != "" {
+		t.Errorf("%v", diff)
+	}
+
+	bigChange.Settings.CodeOwners.Path = filepath.Join(t.TempDir(), "missing")
+	gotErr = importCodeOwners(ctxWithSilentLogger, bigChange)
+	if gotErr == nil {
+		t.Errorf("got '<nil>', want an error for a missing CODEOWNERS file")
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// Paths without glob meta characters keep behaving as plain prefixes,
+// so existing configs like `domains/dom1/` match exactly as before
+func isGlobPattern(domainPath string) bool {
+	return strings.ContainsAny(domainPath, "*?[{\\")
+}
+
+func normalizeDomainPath(domainPath string) string {
+	if domainPath == "." || domainPath == "./" {
+		return ""
+	}
+	return strings.TrimPrefix(domainPath, "./")
+}
+
+func validateDomainPath(domainPath string) bool {
+	return doublestar.ValidatePattern(normalizeDomainPath(domainPath))
+}
+
+func matchDomainPath(domainPath string, filePath string) bool {
+	pattern := normalizeDomainPath(domainPath)
+	if !isGlobPattern(pattern) {
+		return strings.HasPrefix(filePath, pattern)
+	}
+
+	// A trailing slash selects everything below the matched directories
+	if strings.HasSuffix(pattern, "/") {
+		return doublestar.MatchUnvalidated(pattern+"**", filePath)
+	}
+	return doublestar.MatchUnvalidated(pattern, filePath) ||
+		doublestar.MatchUnvalidated(pattern+"/**", filePath)
+}
+
+// The legacy `path` field is kept for backward compatibility and merged with `paths`
+func (domain *Domain) includePaths() []string {
+	includePaths := make([]string, 0, len(domain.Paths)+1)
+	if domain.Path != "" {
+		includePaths = append(includePaths, domain.Path)
+	}
+	return append(includePaths, domain.Paths...)
+}
+
+func (domain *Domain) matchPath(domainPath string, filePath string) bool {
+	if domain.ExactPaths {
+		return doublestar.MatchUnvalidated(domainPath, filePath)
+	}
+	return matchDomainPath(domainPath, filePath)
+}
+
+func (domain *Domain) matchFile(filePath string) bool {
+	for _, excludePath := range domain.ExcludePaths {
+		if domain.matchPath(excludePath, filePath) {
+			return false
+		}
+	}
+	for _, includePath := range domain.includePaths() {
+		if domain.matchPath(includePath, filePath) {
+			return true
+		}
+	}
+	return false
+}
+
+type AssignmentStrategy string
+
+const (
+	FirstMatch   AssignmentStrategy = "first-match"
+	MostSpecific AssignmentStrategy = "most-specific"
+)
+
+type RenamePolicy string
+
+const (
+	// Each path of a rename goes to its own domain, which removes or adds the file
+	RenameSplit RenamePolicy = "split"
+	// The whole rename goes to the domain of the new path
+	RenameDestination RenamePolicy = "destination"
+	// The whole rename goes to the domain of the old path
+	RenameSource RenamePolicy = "source"
+)
+
+type ChangeStatus string
+
+const (
+	ChangeAdded    ChangeStatus = "A"
+	ChangeModified ChangeStatus = "M"
+	ChangeDeleted  ChangeStatus = "D"
+	ChangeRenamed  ChangeStatus = "R"
+	ChangeCopied   ChangeStatus = "C"
+)
+
+// A file changed on the branch to split, `OldPath` is only set for renames and copies
+type FileChange struct {
+	Status  ChangeStatus
+	Path    string
+	OldPath string
+}
+
+// Lists the files changed on the remote branch compared to the main branch, deletions
+// are skipped unless allowed while the old path of a rename is always kept
+func (bit *BigIsTiny) listChangedFilesFromDiff(ctx context.Context, settings *Settings) ([]FileChange, error) {
+	rawDiff, err := bit.gitOps.gitDiffNameStatus(ctx,
+		settings.MainBranch,
+		fmt.Sprintf("%s/%s", settings.Remote, settings.BranchToSplit))
+	if err != nil {
+		return nil, err
+	}
+
+	// With `-z` entries are `<status>\0<path>\0`, or `<status><score>\0<old path>\0<new path>\0`
+	// for renames and copies
+	fields := strings.Split(strings.TrimSuffix(string(rawDiff[:]), "\x00"), "\x00")
+	changes := make([]FileChange, 0, len(fields)/2)
+	for i := 0; i+1 < len(fields); i += 2 {
+		if fields[i] == "" {
+			break
+		}
+		change := FileChange{Status: ChangeStatus(fields[i][:1]), Path: fields[i+1]}
+		if change.Status == ChangeRenamed || change.Status == ChangeCopied {
+			if i+2 >= len(fields) {
+				log := LoggerFromContext(ctx)
+				log.Error("invalid diff entry", "status", fields[i], "path", fields[i+1])
+				return nil, fmt.Errorf("invalid diff entry")
+			}
+			change.OldPath, change.Path = fields[i+1], fields[i+2]
+			i++
+		}
+		if change.Status == ChangeDeleted && !bit.flags.AllowDeletions {
+			continue
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+type FileOverlap struct {
+	File       string   `json:"file"`
+	Domains    []string `json:"domains"`
+	AssignedTo string   `json:"assignedTo"`
+}
+
+type FileAssignment struct {
+	Overlaps   []FileOverlap `json:"overlaps"`
+	Unassigned []string      `json:"unassigned"`
+}
+
+// Each changed file is assigned to exactly one domain, the result is stored in `Domain.Files`
+func assignFiles(domains []*Domain, changedFiles []string, strategy AssignmentStrategy) *FileAssignment {
+	assignment := &FileAssignment{}
+	for _, domain := range domains {
+		domain.Files = nil
+	}
+
+	for _, filePath := range changedFiles {
+		var matchingDomains []*Domain
+		for _, domain := range domains {
+			if domain.matchFile(filePath) {
+				matchingDomains = append(matchingDomains, domain)
+			}
+		}
+		if len(matchingDomains) == 0 {
+			assignment.Unassigned = append(assignment.Unassigned, filePath)
+			continue
+		}
+
+		owner := matchingDomains[0]
+		if strategy == MostSpecific {
+			bestSpecificity := owner.specificity(filePath)
+			for _, domain := range matchingDomains[1:] {
+				if specificity := domain.specificity(filePath); specificity > bestSpecificity {
+					owner, bestSpecificity = domain, specificity
+				}
+			}
+		}
+		owner.Files = append(owner.Files, filePath)
+
+		if len(matchingDomains) > 1 {
+			overlap := FileOverlap{File: filePath, AssignedTo: owner.Name}
+			for _, domain := range matchingDomains {
+				overlap.Domains = append(overlap.Domains, domain.Name)
+			}
+			assignment.Overlaps = append(assignment.Overlaps, overlap)
+		}
+	}
+	return assignment
+}
+
+// Same as assignFiles with the renames assigned according to `policy`, the path not
+// assigned by the policy follows the other one. The old path of a copy is untouched
+func assignChanges(domains []*Domain, changes []FileChange, strategy AssignmentStrategy, policy RenamePolicy) *FileAssignment {
+	var changedFiles []string
+	followers := make(map[string][]string)
+	for _, change := range changes {
+		switch {
+		case change.Status == ChangeRenamed && policy == RenameDestination:
+			changedFiles = append(changedFiles, change.Path)
+			followers[change.Path] = append(followers[change.Path], change.OldPath)
+		case change.Status == ChangeRenamed && policy == RenameSource:
+			changedFiles = append(changedFiles, change.OldPath)
+			followers[change.OldPath] = append(followers[change.OldPath], change.Path)
+		case change.Status == ChangeRenamed:
+			changedFiles = append(changedFiles, change.OldPath, change.Path)
+		default:
+			changedFiles = append(changedFiles, change.Path)
+		}
+	}
+
+	assignment := assignFiles(domains, changedFiles, strategy)
+	if len(followers) == 0 {
+		return assignment
+	}
+	withFollowers := func(files []string) []string {
+		var result []string
+		for _, file := range files {
+			result = append(result, file)
+			result = append(result, followers[file]...)
+		}
+		return result
+	}
+	for _, domain := range domains {
+		domain.Files = withFollowers(domain.Files)
+	}
+	assignment.Unassigned = withFollowers(assignment.Unassigned)
+	return assignment
+}
+
+// The specificity of a domain for a file is the number of literal characters
+// in the longest pattern matching it, so `domains/dom1/` beats `./` and
+// `**/migrations/*.sql` beats `services/`
+func (domain *Domain) specificity(filePath string) int {
+	best := -1
+	for _, includePath := range domain.includePaths() {
+		if !domain.matchPath(includePath, filePath) {
+			continue
+		}
+		if literalLen := patternLiteralLen(normalizeDomainPath(includePath)); literalLen > best {
+			best = literalLen
+		}
+	}
+	return best
+}
+
+// Only the first alternative of `{a,b}` is counted, so `{src/app,src/app/**}` is as
+// specific as `src/app`
+func patternLiteralLen(pattern string) int {
+	literalLen := 0
+	inClass, inOtherAlternative := false, false
+	for _, c := range pattern {
+		switch {
+		case c == '}':
+			inOtherAlternative = false
+		case inOtherAlternative:
+		case c == ',':
+			inOtherAlternative = true
+		case c == '[':
+			inClass = true
+		case c == ']':
+			inClass = false
+		case inClass, c == '*', c == '?', c == '{', c == '\\':
+		default:
+			literalLen++
+		}
+	}
+	return literalLen
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var matchDomainPathTests = []struct {
+	description    string
+	domainPath     string
+	filePath       string
+	expectedResult bool
+}{
+	{
+		description:    "Plain path - prefix match",
+		domainPath:     "domains/dom1/",
+		filePath:       "domains/dom1/sub/file1",
+		expectedResult: true,
+	},
+	{
+		description:    "Plain path - no match",
+		domainPath:     "domains/dom1/",
+		filePath:       "domains/dom2/file1",
+		expectedResult: false,
+	},
+	{
+		description:    "Catch all path",
+		domainPath:     "./",
+		filePath:       "any/file",
+		expectedResult: true,
+	},
+	{
+		description:    "Leading dot slash is ignored",
+		domainPath:     "./domains/dom1/",
+		filePath:       "domains/dom1/file1",
+		expectedResult: true,
+	},
+	{
+		description:    "Double star - nested file",
+		domainPath:     "**/migrations/*.sql",
+		filePath:       "services/users/migrations/001_init.sql",
+		expectedResult: true,
+	},
+	{
+		description:    "Double star - root file",
+		domainPath:     "**/migrations/*.sql",
+		filePath:       "migrations/001_init.sql",
+		expectedResult: true,
+	},
+	{
+		description:    "Double star - wrong extension",
+		domainPath:     "**/migrations/*.sql",
+		filePath:       "services/users/migrations/README.md",
+		expectedResult: false,
+	},
+	{
+		description:    "Single star directory with trailing slash",
+		domainPath:     "services/*/api/",
+		filePath:       "services/users/api/v1/handler.go",
+		expectedResult: true,
+	},
+	{
+		description:    "Single star does not cross directories",
+		domainPath:     "services/*/api/",
+		filePath:       "services/users/internal/api/handler.go",
+		expectedResult: false,
+	},
+	{
+		description:    "Glob without trailing slash matches directories content",
+		domainPath:     "services/*/api",
+		filePath:       "services/users/api/handler.go",
+		expectedResult: true,
+	},
+	{
+		description:    "Alternatives",
+		domainPath:     "docs/*.{md,txt}",
+		filePath:       "docs/notes.txt",
+		expectedResult: true,
+	},
+}
+
+func TestMatchDomainPath(t *testing.T) {
+	for _, tt := range matchDomainPathTests {
+		t.Run(tt.description, func(t *testing.T) {
+			gotResult := matchDomainPath(tt.domainPath, tt.filePath)
+
+			if gotResult != tt.expectedResult {
+				t.Errorf("got '%v', want '%v'", gotResult, tt.expectedResult)
+			}
+		})
+	}
+}
+
+var domainMatchFileTests = []struct {
+	description   string
+	domain        *Domain
+	expectedFiles []string
+}{
+	{
+		description: "Single glob path",
+		domain:      &Domain{Path: "services/*/api/"},
+		expectedFiles: []string{
+			"services/users/api/handler.go",
+			"services/users/api/generated/client.go",
+			"services/orders/api/handler.go",
+		},
+	},
+	{
+		description: "Path and paths are merged",
+		domain: &Domain{
+			Path:  "services/users/",
+			Paths: []string{"docs/", "vendor/lib/"},
+		},
+		expectedFiles: []string{
+			"services/users/api/handler.go",
+			"services/users/api/generated/client.go",
+			"services/users/model.go",
+			"docs/users.md",
+			"vendor/lib/lib.go",
+		},
+	},
+	{
+		description: "Exclude paths are skipped",
+		domain: &Domain{
+			Paths:        []string{"services/", "vendor/"},
+			ExcludePaths: []string{"**/generated/", "vendor/"},
+		},
+		expectedFiles: []string{
+			"services/users/api/handler.go",
+			"services/users/model.go",
+			"services/orders/api/handler.go",
+		},
+	},
+}
+
+func TestDomainMatchFile(t *testing.T) {
+	changedFiles := []string{
+		"services/users/api/handler.go",
+		"services/users/api/generated/client.go",
+		"services/users/model.go",
+		"services/orders/api/handler.go",
+		"docs/users.md",
+		"vendor/lib/lib.go",
+	}
+
+	for _, tt := range domainMatchFileTests {
+		t.Run(tt.description, func(t *testing.T) {
+			var gotFiles []string
+			for _, filePath := range changedFiles {
+				if tt.domain.matchFile(filePath) {
+					gotFiles = append(gotFiles, filePath)
+				}
+			}
+
+			diff := cmp.Diff(gotFiles, tt.expectedFiles)
+			if diff != "" {
+				t.Errorf("%v", diff)
+			}
+		})
+	}
+}
+
+type givenAssignFiles struct {
+	domains      []*Domain
+	changedFiles []string
+	strategy     AssignmentStrategy
+}
+
+var assignFilesTests = []struct {
+	description        string
+	given              givenAssignFiles
+	expectedFiles      map[string][]string
+	expectedAssignment *FileAssignment
+}{
+	{
+		description: "First match - catch all declared first takes everything",
+		given: givenAssignFiles{
+			domains: []*Domain{
+				{Name: "misc", Path: "./"},
+				{Name: "dom1", Path: "domains/dom1/"},
+			},
+			changedFiles: []string{"domains/dom1/file1", "README.md"},
+			strategy:     FirstMatch,
+		},
+		expectedFiles: map[string][]string{
+			"misc": {"domains/dom1/file1", "README.md"},
+		},
+		expectedAssignment: &FileAssignment{
+			Overlaps: []FileOverlap{
+				{File: "domains/dom1/file1", Domains: []string{"misc", "dom1"}, AssignedTo: "misc"},
+			},
+		},
+	},
+	{
+		description: "Most specific - catch all declared first only takes leftovers",
+		given: givenAssignFiles{
+			domains: []*Domain{
+				{Name: "misc", Path: "./"},
+				{Name: "dom1", Path: "domains/dom1/"},
+				{Name: "sql", Path: "**/migrations/*.sql"},
+			},
+			changedFiles: []string{"domains/dom1/file1", "domains/dom1/migrations/001.sql", "README.md"},
+			strategy:     MostSpecific,
+		},
+		expectedFiles: map[string][]string{
+			"misc": {"README.md"},
+			"dom1": {"domains/dom1/file1"},
+			"sql":  {"domains/dom1/migrations/001.sql"},
+		},
+		expectedAssignment: &FileAssignment{
+			Overlaps: []FileOverlap{
+				{File: "domains/dom1/file1", Domains: []string{"misc", "dom1"}, AssignedTo: "dom1"},
+				{File: "domains/dom1/migrations/001.sql", Domains: []string{"misc", "dom1", "sql"}, AssignedTo: "sql"},
+			},
+		},
+	},
+	{
+		description: "Most specific - only the first alternative of exact globs is counted",
+		given: givenAssignFiles{
+			domains: []*Domain{
+				{Name: "codeowners", Paths: []string{"{apps/web,apps/web/**}"}, ExactPaths: true},
+				{Name: "main", Path: "apps/web/main/"},
+			},
+			changedFiles: []string{"apps/web/main/file1", "apps/web/file2"},
+			strategy:     MostSpecific,
+		},
+		expectedFiles: map[string][]string{
+			"codeowners": {"apps/web/file2"},
+			"main":       {"apps/web/main/file1"},
+		},
+		expectedAssignment: &FileAssignment{
+			Overlaps: []FileOverlap{
+				{File: "apps/web/main/file1", Domains: []string{"codeowners", "main"}, AssignedTo: "main"},
+			},
+		},
+	},
+	{
+		description: "Files outside of any domain are reported",
+		given: givenAssignFiles{
+			domains: []*Domain{
+				{Name: "dom1", Path: "domains/dom1/"},
+			},
+			changedFiles: []string{"domains/dom1/file1", "domains/dom2/file2"},
+			strategy:     FirstMatch,
+		},
+		expectedFiles: map[string][]string{
+			"dom1": {"domains/dom1/file1"},
+		},
+		expectedAssignment: &FileAssignment{
+			Unassigned: []string{"domains/dom2/file2"},
+		},
+	},
+}
+
+func TestAssignFiles(t *testing.T) {
+	for _, tt := range assignFilesTests {
+		t.Run(tt.description, func(t *testing.T) {
+			gotAssignment := assignFiles(tt.given.domains, tt.given.changedFiles, tt.given.strategy)
+
+			diff := cmp.Diff(gotAssignment, tt.expectedAssignment)
+			if diff != "" {
+				t.Errorf("%v", diff)
+			}
+
+			gotFiles := make(map[string][]string)
+			for _, domain := range tt.given.domains {
+				if len(domain.Files) > 0 {
+					gotFiles[domain.Name] = domain.Files
+				}
+			}
+			diff = cmp.Diff(gotFiles, tt.expectedFiles)
+			if diff != "" {
+				t.Errorf("%v", diff)
+			}
+		})
+	}
+}
+
+type givenAssignChanges struct {
+	domains []*Domain
+	changes []FileChange
+	policy  RenamePolicy
+}
+
+var assignChangesTests = []struct {
+	description        string
+	given              givenAssignChanges
+	expectedFiles      map[string][]string
+	expectedAssignment *FileAssignment
+}{
+	{
+		description: "Split - each path of a rename goes to its own domain",
+		given: givenAssignChanges{
+			domains: []*Domain{
+				{Name: "dom1", Path: "domains/dom1/"},
+				{Name: "dom2", Path: "domains/dom2/"},
+			},
+			changes: []FileChange{
+				{Status: ChangeRenamed, OldPath: "domains/dom1/old", Path: "domains/dom2/new"},
+				{Status: ChangeModified, Path: "domains/dom1/file1"},
+			},
+			policy: RenameSplit,
+		},
+		expectedFiles: map[string][]string{
+			"dom1": {"domains/dom1/old", "domains/dom1/file1"},
+			"dom2": {"domains/dom2/new"},
+		},
+		expectedAssignment: &FileAssignment{},
+	},
+	{
+		description: "Destination - the old path follows the new one",
+		given: givenAssignChanges{
+			domains: []*Domain{
+				{Name: "dom1", Path: "domains/dom1/"},
+				{Name: "dom2", Path: "domains/dom2/"},
+			},
+			changes: []FileChange{
+				{Status: ChangeRenamed, OldPath: "domains/dom1/old", Path: "domains/dom2/new"},
+				{Status: ChangeModified, Path: "domains/dom1/file1"},
+			},
+			policy: RenameDestination,
+		},
+		expectedFiles: map[string][]string{
+			"dom1": {"domains/dom1/file1"},
+			"dom2": {"domains/dom2/new", "domains/dom1/old"},
+		},
+		expectedAssignment: &FileAssignment{},
+	},
+	{
+		description: "Source - the new path follows the old one, also when unassigned",
+		given: givenAssignChanges{
+			domains: []*Domain{
+				{Name: "dom2", Path: "domains/dom2/"},
+			},
+			changes: []FileChange{
+				{Status: ChangeRenamed, OldPath: "domains/dom1/old", Path: "domains/dom2/new"},
+			},
+			policy: RenameSource,
+		},
+		expectedFiles: map[string][]string{},
+		expectedAssignment: &FileAssignment{
+			Unassigned: []string{"domains/dom1/old", "domains/dom2/new"},
+		},
+	},
+	{
+		description: "Copies leave their old path untouched",
+		given: givenAssignChanges{
+			domains: []*Domain{
+				{Name: "dom1", Path: "domains/dom1/"},
+				{Name: "dom2", Path: "domains/dom2/"},
+			},
+			changes: []FileChange{
+				{Status: ChangeCopied, OldPath: "domains/dom1/file1", Path: "domains/dom2/copy"},
+			},
+			policy: RenameDestination,
+		},
+		expectedFiles: map[string][]string{
+			"dom2": {"domains/dom2/copy"},
+		},
+		expectedAssignment: &FileAssignment{},
+	},
+}
+
+func TestAssignChanges(t *testing.T) {
+	for _, tt := range assignChangesTests {
+		t.Run(tt.description, func(t *testing.T) {
+			gotAssignment := assignChanges(tt.given.domains, tt.given.changes, FirstMatch, tt.given.policy)
+
+			diff := cmp.Diff(gotAssignment, tt.expectedAssignment)
+			if diff != "" {
+				t.Errorf("%v", diff)
+			}
+
+			gotFiles := make(map[string][]string)
+			for _, domain := range tt.given.domains {
+				if len(domain.Files) > 0 {
+					gotFiles[domain.Name] = domain.Files
+				}
+			}
+			diff = cmp.Diff(gotFiles, tt.expectedFiles)
+			if diff != "" {
+				t.Errorf("%v", diff)
+			}
+		})
+	}
+}
@@ -3,18 +3,26 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"strings"
+	"sync"
+	"testing"
 
 	"github.com/google/go-cmp/cmp"
 )
 
 func fixtureFlags(mods ...func(*Flags)) *Flags {
 	flags := &Flags{
+		Command:        RunCommand,
 		Cleanup:        false,
 		Verbose:        false,
 		ConfigPath:     "bit_config.json",
-		Platform:       Platform(GitHub),
+		Platform:       DefaultPlatform,
+		Format:         TableFormat,
 		AllowDeletions: false,
+		GitBackend:     GitBackendCli,
 	}
 	for _, mod := range mods {
 		mod(flags)
@@ -33,6 +41,8 @@ func fixtureBigChange(mods ...func(*BigChange)) *BigChange {
 			CommitMsgTemplate:  "implement new feature for {{domain_name}} at {{team_name_1}}({{team_url_1}}) and {{team_name_2}}({{team_url_2}})",
 			PrNameTemplate:     "{{domain_id}} {{domain_name}}: Big change split",
 			PrDescTemplate:     "This change refers to this refactor for domain {{domain_id}} {{domain_name}}: https://example.com",
+			AssignmentStrategy: FirstMatch,
+			RenamePolicy:       RenameSplit,
 		},
 		Domains: []*Domain{
 			{
@@ -86,27 +96,50 @@ func fixtureBigChange(mods ...func(*BigChange)) *BigChange {
 
 func fixtureGitOps(mods ...func(*GitOps)) *GitOps {
 	gitOps := &GitOps{
-		gitCheckout:           func(ctx context.Context, s string) error { return nil },
-		gitCheckoutNewBranch:  func(ctx context.Context, s string) error { return nil },
+		gitCommitFiles: func(ctx context.Context, s1, s2 string, files []string, s3 string) (string, error) {
+			return "commit-sha", nil
+		},
+		gitReplayCommits: func(ctx context.Context, s1, s2 string, files []string) (string, error) {
+			return "commit-sha", nil
+		},
+		gitCreateBranch:       func(ctx context.Context, s1, s2 string) error { return nil },
+		gitResetBranch:        func(ctx context.Context, s1, s2 string) error { return nil },
 		gitDeleteBranch:       func(ctx context.Context, s string) error { return nil },
 		gitDeleteRemoteBranch: func(ctx context.Context, s1, s2 string) error { return nil },
-		// Used to check if branches need to be created because files were modified
-		gitStatus: func(ctx context.Context) ([]byte, error) {
-			return []byte(" M domains/dom1/file1\n A domains/dom2/file2\n"), nil
+		// Used to list the changed files, the deletion is skipped unless allowed
+		gitDiffNameStatus: func(ctx context.Context, s1, s2 string) ([]byte, error) {
+			return []byte("M\x00domains/dom1/file1\x00A\x00domains/dom2/file2\x00D\x00domains/dom3/file3\x00"), nil
 		},
-		gitAdd:             func(ctx context.Context, s string) error { return nil },
-		gitCommit:          func(ctx context.Context, s string) error { return nil },
-		gitCheckoutFiles:   func(ctx context.Context, s1, s2 string, allowDeletions bool) error { return nil },
-		gitReset:           func(ctx context.Context) error { return nil },
 		gitPushSetUpstream: func(ctx context.Context, s1, s2 string) error { return nil },
-		createPr: func(ctx context.Context, s1 *Settings, s2, s3, s4 string) (string, error) {
-			return s2 + "/pr", nil
-		},
-		abandonPr: func(ctx context.Context, s string) error {
-			if len(strings.Split(s, "/")) < 2 {
-				return fmt.Errorf("unreachable")
-			}
-			return nil
+		gitPushForce:       func(ctx context.Context, s1, s2 string) error { return nil },
+		gitFetch:           func(ctx context.Context, s string) error { return nil },
+		gitRevParse:        func(ctx context.Context, s string) (string, error) { return s + "-sha", nil },
+		gitRewordCommit:    func(ctx context.Context, s1, s2 string) (string, error) { return s1 + "-reworded-sha", nil },
+		gitPushRef:         func(ctx context.Context, s1, s2, s3 string) error { return nil },
+		gitRemoteUrl:       func(ctx context.Context, s string) (string, error) { return "git@github.com:o/r.git", nil },
+		platform: &fakePlatform{
+			createPr: func(ctx context.Context, s1 *Settings, s2, s3, s4 string) (string, error) {
+				return s2 + "/pr", nil
+			},
+			closePr: func(ctx context.Context, s1 *Settings, s string) error {
+				if len(strings.Split(s, "/")) < 2 {
+					return fmt.Errorf("unreachable")
+				}
+				return nil
+			},
+			findPr: func(ctx context.Context, s1 *Settings, s2 string) (string, error) { return "", nil },
+			updatePr: func(ctx context.Context, s1 *Settings, s2, s3, s4 string) error {
+				return nil
+			},
+			prStatus: func(ctx context.Context, s1 *Settings, s2 string) (*PrStatus, error) {
+				return &PrStatus{
+					Url:       s2 + "/pr",
+					State:     PrStateOpen,
+					Review:    ReviewApproved,
+					Checks:    ChecksSuccess,
+					Mergeable: MergeableYes,
+				}, nil
+			},
 		},
 	}
 	for _, mod := range mods {
@@ -115,6 +148,115 @@ func fixtureGitOps(mods ...func(*GitOps)) *GitOps {
 	return gitOps
 }
 
+// Platform with mockable operations, the features without mock are unsupported
+type fakePlatform struct {
+	createPr        func(context.Context, *Settings, string, string, string) (string, error)
+	findPr          func(context.Context, *Settings, string) (string, error)
+	updatePr        func(context.Context, *Settings, string, string, string) error
+	closePr         func(context.Context, *Settings, string) error
+	prStatus        func(context.Context, *Settings, string) (*PrStatus, error)
+	addReviewers    func(context.Context, *Settings, string, []string) error
+	addLabels       func(context.Context, *Settings, string, []string) error
+	setMilestone    func(context.Context, *Settings, string, string) error
+	linkWorkItems   func(context.Context, *Settings, string, []int) error
+	enableAutoMerge func(context.Context, *Settings, string, *AutoMerge) error
+	createIssue     func(context.Context, *Settings, string, string) (string, error)
+	findIssue       func(context.Context, *Settings, string) (string, error)
+	updateIssue     func(context.Context, *Settings, string, string, string) error
+}
+
+func platformOf(gitOps *GitOps) *fakePlatform {
+	return gitOps.platform.(*fakePlatform)
+}
+
+func (p *fakePlatform) CreatePr(ctx context.Context, settings *Settings, head, title, body string) (string, error) {
+	return p.createPr(ctx, settings, head, title, body)
+}
+
+func (p *fakePlatform) FindPr(ctx context.Context, settings *Settings, head string) (string, error) {
+	return p.findPr(ctx, settings, head)
+}
+
+func (p *fakePlatform) UpdatePr(ctx context.Context, settings *Settings, head, title, body string) error {
+	return p.updatePr(ctx, settings, head, title, body)
+}
+
+func (p *fakePlatform) ClosePr(ctx context.Context, settings *Settings, head string) error {
+	return p.closePr(ctx, settings, head)
+}
+
+func (p *fakePlatform) PrStatus(ctx context.Context, settings *Settings, head string) (*PrStatus, error) {
+	return p.prStatus(ctx, settings, head)
+}
+
+func (p *fakePlatform) AddReviewers(ctx context.Context, settings *Settings, head string, reviewers []string) error {
+	if p.addReviewers == nil {
+		return unsupportedFeatures{}.AddReviewers(ctx, settings, head, reviewers)
+	}
+	return p.addReviewers(ctx, settings, head, reviewers)
+}
+
+func (p *fakePlatform) AddLabels(ctx context.Context, settings *Settings, head string, labels []string) error {
+	if p.addLabels == nil {
+		return unsupportedFeatures{}.AddLabels(ctx, settings, head, labels)
+	}
+	return p.addLabels(ctx, settings, head, labels)
+}
+
+func (p *fakePlatform) SetMilestone(ctx context.Context, settings *Settings, head string, milestone string) error {
+	if p.setMilestone == nil {
+		return unsupportedFeatures{}.SetMilestone(ctx, settings, head, milestone)
+	}
+	return p.setMilestone(ctx, settings, head, milestone)
+}
+
+func (p *fakePlatform) LinkWorkItems(ctx context.Context, settings *Settings, head string, workItems []int) error {
+	if p.linkWorkItems == nil {
+		return unsupportedFeatures{}.LinkWorkItems(ctx, settings, head, workItems)
+	}
+	return p.linkWorkItems(ctx, settings, head, workItems)
+}
+
+func (p *fakePlatform) EnableAutoMerge(ctx context.Context, settings *Settings, head string, autoMerge *AutoMerge) error {
+	if p.enableAutoMerge == nil {
+		return unsupportedFeatures{}.EnableAutoMerge(ctx, settings, head, autoMerge)
+	}
+	return p.enableAutoMerge(ctx, settings, head, autoMerge)
+}
+
+func (p *fakePlatform) CreateIssue(ctx context.Context, settings *Settings, title, body string) (string, error) {
+	if p.createIssue == nil {
+		return unsupportedFeatures{}.CreateIssue(ctx, settings, title, body)
+	}
+	return p.createIssue(ctx, settings, title, body)
+}
+
+func (p *fakePlatform) FindIssue(ctx context.Context, settings *Settings, title string) (string, error) {
+	if p.findIssue == nil {
+		return unsupportedFeatures{}.FindIssue(ctx, settings, title)
+	}
+	return p.findIssue(ctx, settings, title)
+}
+
+func (p *fakePlatform) UpdateIssue(ctx context.Context, settings *Settings, issueUrl, title, body string) error {
+	if p.updateIssue == nil {
+		return unsupportedFeatures{}.UpdateIssue(ctx, settings, issueUrl, title, body)
+	}
+	return p.updateIssue(ctx, settings, issueUrl, title, body)
+}
+
+func fixtureStateOps(mods ...func(*StateOps)) *StateOps {
+	stateOps := &StateOps{
+		loadState:   func(ctx context.Context, s string) (*RunState, error) { return nil, nil },
+		saveState:   func(ctx context.Context, rs *RunState) error { return nil },
+		deleteState: func(ctx context.Context, s string) error { return nil },
+	}
+	for _, mod := range mods {
+		mod(stateOps)
+	}
+	return stateOps
+}
+
 func checkExportResults(expectedDomains []*Domain) ExportResultsFunc {
 	return func(ctx context.Context, flags *Flags, config *BigChange) error {
 		// We got a different configuration of what's expected
@@ -125,3 +267,40 @@ func checkExportResults(expectedDomains []*Domain) ExportResultsFunc {
 		return nil
 	}
 }
+
+type apiRequest struct {
+	Method string
+	Url    string
+	Auth   string
+	Body   string
+}
+
+// Fake REST API answering with the given responses, keyed by "METHOD path"
+func fixtureApi(t *testing.T, responses map[string]string) (*httptest.Server, *[]apiRequest) {
+	var mu sync.Mutex
+	requests := []apiRequest{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		requests = append(requests, apiRequest{
+			Method: r.Method,
+			Url:    r.URL.String(),
+			Auth:   r.Header.Get("Authorization"),
+			Body:   string(body),
+		})
+		mu.Unlock()
+
+		resp, ok := responses[r.Method+" "+r.URL.Path]
+		if !ok {
+			http.Error(w, `{"error": "not found"}`, http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, resp)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
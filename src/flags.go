@@ -6,57 +6,142 @@ import (
 	"os"
 )
 
-const usage = `Usage: bit [-v | --verbose] [-cleanup] [-p | --platform] [-m | --markdown] [-o | --output] [-h | --help] <path to config file>
+const usage = `Usage: bit [command] [-v | --verbose] [-cleanup] [-resume] [-p | --platform] [-g | --git] [-m | --markdown] [-o | --output] [-f | --format] [-h | --help] <path to config file>
 
 If not specified the default path to the config file is './bit_config.json'
 
+Commands:
+  run
+        split the branch, push the new branches and create the PRs (default)
+  plan
+        print the branches, commit messages, PRs and files of each domain without changing anything
+  sync
+        update the existing split branches and PRs after the big change branch received new commits
+  status
+        report the state, reviews, checks and mergeability of the PR of each domain
+
   -cleanup
         delete branches and PRs
+  -resume
+        continue a failed run from its last completed step (state is saved in .bit/state)
   -v, --verbose
         set logs to DEBUG level
   -p, --platform
-        platform used for PRs, can be "github" (default) or "azure"
+        platform used for PRs, can be "github" (default), "github-api", "azure", "azure-api", "gitlab", "bitbucket", "gitea" ("forgejo"), "gerrit"
+        or the name of an external platform plugin "bit-platform-<name>"
+  -g, --git
+        git backend, can be "cli" (default) to run the installed git or "go-git" for the in-process implementation
   -o, --output
         writes the results in the specified file
+  -f, --format
+        format of the status report, can be "table" (default) or "json"
   -d, --allow-deletions
-        also updates file deletions from the source branch (git --no-overlay flag)
+        also splits the files deleted on the source branch, the old path of a renamed file is always removed
   -h, --help
         print this help information
 `
 
+type Command int
+
+const (
+	RunCommand Command = iota
+	PlanCommand
+	SyncCommand
+	StatusCommand
+)
+
+type GitBackend string
+
+const (
+	GitBackendCli   GitBackend = "cli"
+	GitBackendGoGit GitBackend = "go-git"
+)
+
+type OutputFormat string
+
+const (
+	TableFormat OutputFormat = "table"
+	JsonFormat  OutputFormat = "json"
+)
+
+func (c Command) String() string {
+	switch c {
+	case RunCommand:
+		return "run"
+	case PlanCommand:
+		return "plan"
+	case SyncCommand:
+		return "sync"
+	case StatusCommand:
+		return "status"
+	default:
+		return fmt.Sprintf("%d", int(c))
+	}
+}
+
 func getFlags(progName string, args []string) (*Flags, error) {
+	command := RunCommand
+	if len(args) > 0 {
+		switch args[0] {
+		case "run":
+			args = args[1:]
+		case "plan":
+			command = PlanCommand
+			args = args[1:]
+		case "sync":
+			command = SyncCommand
+			args = args[1:]
+		case "status":
+			command = StatusCommand
+			args = args[1:]
+		}
+	}
+
 	rawFlags := flag.NewFlagSet(progName, flag.ExitOnError)
 
-	var verbose, cleanup, allowDeletions bool
-	var rawPlatform, fileOut string
-	var platform Platform
+	var verbose, cleanup, resume, allowDeletions bool
+	var rawPlatform, rawGitBackend, fileOut, rawFormat string
 	rawFlags.BoolVar(&cleanup, "cleanup", false, "delete branches and PRs")
+	rawFlags.BoolVar(&resume, "resume", false, "continue a failed run from its last completed step")
 	rawFlags.BoolVar(&verbose, "verbose", false, "set logs to DEBUG level")
 	rawFlags.BoolVar(&verbose, "v", false, "set logs to DEBUG level")
 	rawFlags.BoolVar(&allowDeletions, "d", false, "writes the results in the specified file")
 	rawFlags.BoolVar(&allowDeletions, "allow-deletions", false, "writes the results in the specified file")
-	rawFlags.StringVar(&rawPlatform, "platform", "github", "platform used for PRs, can be `github` (default) or `azure`")
-	rawFlags.StringVar(&rawPlatform, "p", "github", "platform used for PRs, can be `github` (default) or `azure`")
+	rawFlags.StringVar(&rawPlatform, "platform", DefaultPlatform, "platform used for PRs, can be `github` (default), `github-api`, `azure`, `azure-api`, `gitlab`, `bitbucket`, `gitea`, `gerrit` or a plugin name")
+	rawFlags.StringVar(&rawPlatform, "p", DefaultPlatform, "platform used for PRs, can be `github` (default), `github-api`, `azure`, `azure-api`, `gitlab`, `bitbucket`, `gitea`, `gerrit` or a plugin name")
+	rawFlags.StringVar(&rawGitBackend, "git", string(GitBackendCli), "git backend, can be `cli` (default) or `go-git`")
+	rawFlags.StringVar(&rawGitBackend, "g", string(GitBackendCli), "git backend, can be `cli` (default) or `go-git`")
 	rawFlags.StringVar(&fileOut, "output", "", "writes the results in the specified file")
 	rawFlags.StringVar(&fileOut, "o", "", "writes the results in the specified file")
+	rawFlags.StringVar(&rawFormat, "format", "table", "format of the status report, can be `table` (default) or `json`")
+	rawFlags.StringVar(&rawFormat, "f", "table", "format of the status report, can be `table` (default) or `json`")
 	rawFlags.Usage = func() { fmt.Fprint(os.Stderr, usage) }
 	rawFlags.Parse(args)
 
-	switch rawPlatform {
-	case "github":
-		platform = Platform(GitHub)
-	case "azure":
-		platform = Platform(Azure)
-	default:
-		return nil, fmt.Errorf("platform '%s' is not supported", rawPlatform)
+	if _, err := GetPlatform(rawPlatform); err != nil {
+		return nil, err
+	}
+
+	gitBackend := GitBackend(rawGitBackend)
+	if gitBackend != GitBackendCli && gitBackend != GitBackendGoGit {
+		return nil, fmt.Errorf("git backend '%s' is not supported", rawGitBackend)
+	}
+
+	format := OutputFormat(rawFormat)
+	if format != TableFormat && format != JsonFormat {
+		return nil, fmt.Errorf("format '%s' is not supported", rawFormat)
 	}
 
 	flags := &Flags{
+		Command:        command,
 		Cleanup:        cleanup,
+		Resume:         resume,
 		Verbose:        verbose,
-		Platform:       platform,
+		Platform:       rawPlatform,
 		FileOut:        fileOut,
+		Format:         format,
 		AllowDeletions: allowDeletions,
+		GitBackend:     gitBackend,
 	}
 	if configPath := rawFlags.Arg(0); configPath != "" {
 		flags.ConfigPath = configPath
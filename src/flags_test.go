@@ -21,13 +21,14 @@ var flagsTests = []struct {
 	{
 		description: "Happy path - all flags passed (long versions)",
 		args: []string{
-			"-verbose", "-cleanup", "-platform", "azure", "-output", "../file.out", "-allow-deletions", "anotherConfig.json",
+			"-verbose", "-cleanup", "-platform", "azure", "-output", "../file.out", "-format", "json", "-allow-deletions", "anotherConfig.json",
 		},
 		expectedFlags: fixtureFlags(func(f *Flags) {
 			f.Cleanup = true
 			f.Verbose = true
-			f.Platform = Platform(Azure)
+			f.Platform = "azure"
 			f.FileOut = "../file.out"
+			f.Format = JsonFormat
 			f.ConfigPath = "anotherConfig.json"
 			f.AllowDeletions = true
 		}),
@@ -40,11 +41,103 @@ var flagsTests = []struct {
 		expectedFlags: fixtureFlags(func(f *Flags) {
 			f.Verbose = true
 			f.FileOut = "../file.out"
-			f.Platform = Platform(Azure)
+			f.Platform = "azure"
 			f.ConfigPath = "anotherConfig.json"
 			f.AllowDeletions = true
 		}),
 	},
+	{
+		description: "Happy path - explicit run command",
+		args:        []string{"run", "-v", "anotherConfig.json"},
+		expectedFlags: fixtureFlags(func(f *Flags) {
+			f.Verbose = true
+			f.ConfigPath = "anotherConfig.json"
+		}),
+	},
+	{
+		description: "Happy path - plan command",
+		args:        []string{"plan", "-o", "plan.json", "anotherConfig.json"},
+		expectedFlags: fixtureFlags(func(f *Flags) {
+			f.Command = PlanCommand
+			f.FileOut = "plan.json"
+			f.ConfigPath = "anotherConfig.json"
+		}),
+	},
+	{
+		description: "Happy path - sync command",
+		args:        []string{"sync", "-p", "azure"},
+		expectedFlags: fixtureFlags(func(f *Flags) {
+			f.Command = SyncCommand
+			f.Platform = "azure"
+		}),
+	},
+	{
+		description: "Happy path - status command with json format",
+		args:        []string{"status", "-f", "json"},
+		expectedFlags: fixtureFlags(func(f *Flags) {
+			f.Command = StatusCommand
+			f.Format = JsonFormat
+		}),
+	},
+	{
+		description: "Fail on format flag",
+		args:        []string{"status", "-format", "xml"},
+		expectedErr: fmt.Errorf("invalid format"),
+	},
+	{
+		description: "Happy path - gitlab platform",
+		args:        []string{"-p", "gitlab"},
+		expectedFlags: fixtureFlags(func(f *Flags) {
+			f.Platform = "gitlab"
+		}),
+	},
+	{
+		description: "Happy path - bitbucket platform",
+		args:        []string{"-p", "bitbucket"},
+		expectedFlags: fixtureFlags(func(f *Flags) {
+			f.Platform = "bitbucket"
+		}),
+	},
+	{
+		description: "Happy path - forgejo is an alias of the gitea platform",
+		args:        []string{"-p", "forgejo"},
+		expectedFlags: fixtureFlags(func(f *Flags) {
+			f.Platform = "forgejo"
+		}),
+	},
+	{
+		description: "Happy path - gerrit platform",
+		args:        []string{"-p", "gerrit"},
+		expectedFlags: fixtureFlags(func(f *Flags) {
+			f.Platform = "gerrit"
+		}),
+	},
+	{
+		description: "Happy path - github-api platform",
+		args:        []string{"-p", "github-api"},
+		expectedFlags: fixtureFlags(func(f *Flags) {
+			f.Platform = "github-api"
+		}),
+	},
+	{
+		description: "Happy path - azure-api platform",
+		args:        []string{"-p", "azure-api"},
+		expectedFlags: fixtureFlags(func(f *Flags) {
+			f.Platform = "azure-api"
+		}),
+	},
+	{
+		description: "Happy path - go-git backend",
+		args:        []string{"-git", "go-git"},
+		expectedFlags: fixtureFlags(func(f *Flags) {
+			f.GitBackend = GitBackendGoGit
+		}),
+	},
+	{
+		description: "Fail on git backend flag",
+		args:        []string{"-g", "libgit2"},
+		expectedErr: fmt.Errorf("invalid git backend"),
+	},
 	{
 		description: "Fail on platform flag",
 		args: []string{
@@ -0,0 +1,664 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/utils/merkletrie"
+)
+
+// In-process backend implementing the same operations as the git CLI, SSH remotes
+// use the ssh-agent and HTTPS remotes the GIT_USERNAME and GIT_PASSWORD variables
+type goGitRepo struct {
+	repo *git.Repository
+	// Guards the refs and the config, go-git does not lock them
+	mu sync.Mutex
+}
+
+func newGoGitOps(ctx context.Context, platform Platform) (*GitOps, error) {
+	repo, err := git.PlainOpenWithOptions(".", &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		log := LoggerFromContext(ctx)
+		log.Error("failed to open the repository", "error", err)
+		return nil, err
+	}
+	r := &goGitRepo{repo: repo}
+
+	gitOps := &GitOps{
+		gitCommitFiles:        r.commitFiles,
+		gitReplayCommits:      r.replayCommits,
+		gitCreateBranch:       r.createBranch,
+		gitResetBranch:        r.resetBranch,
+		gitDeleteBranch:       r.deleteBranch,
+		gitDeleteRemoteBranch: GetRemoteBranchOpForPlatform(platform, r.deleteRemoteBranch),
+		gitDiffNameStatus:     r.diffNameStatus,
+		gitPushSetUpstream:    GetRemoteBranchOpForPlatform(platform, r.pushSetUpstream),
+		gitPushForce:          GetRemoteBranchOpForPlatform(platform, r.pushForce),
+		gitFetch:              r.fetch,
+		gitRevParse:           r.revParse,
+		gitRewordCommit:       r.rewordCommit,
+		gitPushRef:            r.pushRef,
+		gitRemoteUrl:          r.remoteUrl,
+	}
+	gitOps.platform = platformWithGitOps(platform, gitOps)
+	return gitOps, nil
+}
+
+func goGitFailed(ctx context.Context, operation string, err error, args ...any) error {
+	log := LoggerFromContext(ctx)
+	log.Error("git operation failed", append([]any{"operation", operation, "error", err}, args...)...)
+	return err
+}
+
+// Same as gitCommitFiles, only the subtrees containing `files` are rewritten. The
+// changes are not merged line by line, a file changed on both branches is a conflict
+func (r *goGitRepo) commitFiles(ctx context.Context, parent string, source string, files []string, message string) (string, error) {
+	parentCommit, err := r.commit(parent)
+	if err != nil {
+		return "", goGitFailed(ctx, "commit files", err, "parent", parent)
+	}
+	sourceCommit, err := r.commit(source)
+	if err != nil {
+		return "", goGitFailed(ctx, "commit files", err, "source", source)
+	}
+	baseCommit, err := r.mergeBase(parentCommit, sourceCommit)
+	if err != nil {
+		return "", goGitFailed(ctx, "commit files", err, "parent", parent, "source", source)
+	}
+	trees, err := commitTrees(parentCommit, baseCommit, sourceCommit)
+	if err != nil {
+		return "", goGitFailed(ctx, "commit files", err)
+	}
+	treeHash, err := r.applyFiles(trees[0], trees[1], trees[2], files)
+	var conflictErr *ConflictError
+	if errors.As(err, &conflictErr) {
+		return "", err
+	}
+	if err != nil {
+		return "", goGitFailed(ctx, "commit files", err)
+	}
+
+	signature, err := r.signature()
+	if err != nil {
+		return "", goGitFailed(ctx, "commit files", err)
+	}
+	// The git CLI always ends the messages with a new line
+	if !strings.HasSuffix(message, "\n") {
+		message += "\n"
+	}
+	commit := &object.Commit{
+		Author:       *signature,
+		Committer:    *signature,
+		Message:      message,
+		TreeHash:     treeHash,
+		ParentHashes: []plumbing.Hash{parentCommit.Hash},
+	}
+	commitHash, err := r.store(commit)
+	if err != nil {
+		return "", goGitFailed(ctx, "commit files", err)
+	}
+	return commitHash.String(), nil
+}
+
+// Same as gitReplayCommits, with the same limits as commitFiles for the files changed
+// on both branches
+func (r *goGitRepo) replayCommits(ctx context.Context, parent string, source string, files []string) (string, error) {
+	parentCommit, err := r.commit(parent)
+	if err != nil {
+		return "", goGitFailed(ctx, "replay commits", err, "parent", parent)
+	}
+	sourceCommit, err := r.commit(source)
+	if err != nil {
+		return "", goGitFailed(ctx, "replay commits", err, "source", source)
+	}
+	baseCommit, err := r.mergeBase(parentCommit, sourceCommit)
+	if err != nil {
+		return "", goGitFailed(ctx, "replay commits", err, "parent", parent, "source", source)
+	}
+	history, err := firstParentHistory(sourceCommit, baseCommit)
+	if err != nil {
+		return "", goGitFailed(ctx, "replay commits", err, "source", source)
+	}
+	signature, err := r.signature()
+	if err != nil {
+		return "", goGitFailed(ctx, "replay commits", err)
+	}
+
+	tip := parentCommit
+	for i := len(history) - 1; i >= 0; i-- {
+		commit := history[i]
+		before, err := commit.Parent(0)
+		if err != nil {
+			return "", goGitFailed(ctx, "replay commits", err, "commit", commit.Hash.String())
+		}
+		trees, err := commitTrees(tip, before, commit)
+		if err != nil {
+			return "", goGitFailed(ctx, "replay commits", err, "commit", commit.Hash.String())
+		}
+
+		treeHash, err := r.applyFiles(trees[0], trees[1], trees[2], files)
+		var conflictErr *ConflictError
+		if errors.As(err, &conflictErr) {
+			conflictErr.Commit = commit.Hash.String()
+			return "", err
+		}
+		if err != nil {
+			return "", goGitFailed(ctx, "replay commits", err, "commit", commit.Hash.String())
+		}
+		if treeHash == tip.TreeHash {
+			continue
+		}
+
+		newCommit := &object.Commit{
+			Author:       commit.Author,
+			Committer:    *signature,
+			Message:      commit.Message,
+			TreeHash:     treeHash,
+			ParentHashes: []plumbing.Hash{tip.Hash},
+		}
+		newHash, err := r.store(newCommit)
+		if err != nil {
+			return "", goGitFailed(ctx, "replay commits", err, "commit", commit.Hash.String())
+		}
+		if tip, err = r.repo.CommitObject(newHash); err != nil {
+			return "", goGitFailed(ctx, "replay commits", err, "commit", newHash.String())
+		}
+	}
+	if tip.Hash == parentCommit.Hash {
+		return "", ErrNoChanges
+	}
+	return tip.Hash.String(), nil
+}
+
+// Commits of the first-parent history of `source` not reachable from `base`, newest
+// first as `git rev-list --first-parent`. The costly ancestor check is only needed
+// once a merge was met, on a linear history the walk stops on `base` itself
+func firstParentHistory(source *object.Commit, base *object.Commit) ([]*object.Commit, error) {
+	var history []*object.Commit
+	merged := false
+	for commit := source; commit.Hash != base.Hash && commit.NumParents() > 0; {
+		if merged {
+			isAncestor, err := commit.IsAncestor(base)
+			if err != nil {
+				return nil, err
+			}
+			if isAncestor {
+				break
+			}
+		}
+		merged = merged || commit.NumParents() > 1
+		history = append(history, commit)
+
+		var err error
+		if commit, err = commit.Parent(0); err != nil {
+			return nil, err
+		}
+	}
+	return history, nil
+}
+
+// Returns the hash of `tree` with the changes made to `files` from `base` to `target`.
+// Without a content merge a file can only take its version in `target` when `tree`
+// did not change it since `base`, or already has that version
+func (r *goGitRepo) applyFiles(tree *object.Tree, base *object.Tree, target *object.Tree, files []string) (plumbing.Hash, error) {
+	changes := make(map[string]*object.TreeEntry, len(files))
+	var conflicts []string
+	for _, file := range files {
+		entries := make([]*object.TreeEntry, 3)
+		for i, t := range []*object.Tree{tree, target, base} {
+			var err error
+			if entries[i], err = findTreeEntry(t, file); err != nil {
+				return plumbing.ZeroHash, err
+			}
+		}
+		treeEntry, targetEntry, baseEntry := entries[0], entries[1], entries[2]
+		switch {
+		case sameTreeEntry(baseEntry, targetEntry):
+			// Not changed between `base` and `target`
+		case sameTreeEntry(treeEntry, baseEntry):
+			changes[file] = targetEntry
+		case !sameTreeEntry(treeEntry, targetEntry):
+			conflicts = append(conflicts, file)
+		}
+	}
+	if len(conflicts) > 0 {
+		return plumbing.ZeroHash, &ConflictError{Files: conflicts}
+	}
+	if len(changes) == 0 {
+		return tree.Hash, nil
+	}
+
+	treeHash, err := r.editTree(tree, changes)
+	if treeHash == plumbing.ZeroHash && err == nil {
+		treeHash, err = r.store(&object.Tree{})
+	}
+	return treeHash, err
+}
+
+func commitTrees(commits ...*object.Commit) ([]*object.Tree, error) {
+	trees := make([]*object.Tree, len(commits))
+	for i, commit := range commits {
+		tree, err := commit.Tree()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the tree of '%s': %w", commit.Hash, err)
+		}
+		trees[i] = tree
+	}
+	return trees, nil
+}
+
+func (r *goGitRepo) mergeBase(a *object.Commit, b *object.Commit) (*object.Commit, error) {
+	bases, err := a.MergeBase(b)
+	if err != nil {
+		return nil, err
+	}
+	if len(bases) == 0 {
+		return nil, fmt.Errorf("no merge base between '%s' and '%s'", a.Hash, b.Hash)
+	}
+	return bases[0], nil
+}
+
+// Returns nil when the file is not in the tree
+func findTreeEntry(tree *object.Tree, file string) (*object.TreeEntry, error) {
+	entry, err := tree.FindEntry(file)
+	if errors.Is(err, object.ErrEntryNotFound) || errors.Is(err, object.ErrDirectoryNotFound) {
+		return nil, nil
+	}
+	return entry, err
+}
+
+func sameTreeEntry(a *object.TreeEntry, b *object.TreeEntry) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Hash == b.Hash && a.Mode == b.Mode
+}
+
+// Returns the hash of `tree` with `changes` applied, they are keyed by path relative
+// to the tree and a nil entry removes the path. `tree` is nil for new directories and
+// the zero hash is returned when no entry is left
+func (r *goGitRepo) editTree(tree *object.Tree, changes map[string]*object.TreeEntry) (plumbing.Hash, error) {
+	direct := make(map[string]*object.TreeEntry)
+	nested := make(map[string]map[string]*object.TreeEntry)
+	for filePath, entry := range changes {
+		dir, rest, found := strings.Cut(filePath, "/")
+		if !found {
+			direct[filePath] = entry
+			continue
+		}
+		if nested[dir] == nil {
+			nested[dir] = make(map[string]*object.TreeEntry)
+		}
+		nested[dir][rest] = entry
+	}
+
+	var entries []object.TreeEntry
+	subtrees := make(map[string]*object.Tree)
+	if tree != nil {
+		for _, entry := range tree.Entries {
+			_, isDirect := direct[entry.Name]
+			_, isNested := nested[entry.Name]
+			if isNested && entry.Mode == filemode.Dir {
+				subtree, err := r.repo.TreeObject(entry.Hash)
+				if err != nil {
+					return plumbing.ZeroHash, err
+				}
+				subtrees[entry.Name] = subtree
+			}
+			if !isDirect && !isNested {
+				entries = append(entries, entry)
+			}
+		}
+	}
+
+	for name, entry := range direct {
+		if entry != nil {
+			entries = append(entries, object.TreeEntry{Name: name, Mode: entry.Mode, Hash: entry.Hash})
+		}
+	}
+	for name, dirChanges := range nested {
+		subtreeHash, err := r.editTree(subtrees[name], dirChanges)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		// Directories left without files are removed as git does not track them
+		if subtreeHash != plumbing.ZeroHash {
+			entries = append(entries, object.TreeEntry{Name: name, Mode: filemode.Dir, Hash: subtreeHash})
+		}
+	}
+
+	if len(entries) == 0 {
+		return plumbing.ZeroHash, nil
+	}
+	sort.Sort(object.TreeEntrySorter(entries))
+	return r.store(&object.Tree{Entries: entries})
+}
+
+func (r *goGitRepo) store(obj interface {
+	Encode(plumbing.EncodedObject) error
+}) (plumbing.Hash, error) {
+	encoded := r.repo.Storer.NewEncodedObject()
+	if err := obj.Encode(encoded); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return r.repo.Storer.SetEncodedObject(encoded)
+}
+
+// Author and committer are read from the git config, as done by the git CLI
+func (r *goGitRepo) signature() (*object.Signature, error) {
+	cfg, err := r.repo.ConfigScoped(config.SystemScope)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.User.Name == "" || cfg.User.Email == "" {
+		return nil, fmt.Errorf("git user.name and user.email must be set")
+	}
+	return &object.Signature{Name: cfg.User.Name, Email: cfg.User.Email, When: time.Now()}, nil
+}
+
+func (r *goGitRepo) commit(rev string) (*object.Commit, error) {
+	hash, err := r.repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve '%s': %w", rev, err)
+	}
+	return r.repo.CommitObject(*hash)
+}
+
+func (r *goGitRepo) createBranch(ctx context.Context, branchName string, commitSha string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	refName := plumbing.NewBranchReferenceName(branchName)
+	if _, err := r.repo.Reference(refName, false); err == nil {
+		return goGitFailed(ctx, "create branch", fmt.Errorf("branch '%s' already exists", branchName))
+	}
+	err := r.repo.Storer.SetReference(plumbing.NewHashReference(refName, plumbing.NewHash(commitSha)))
+	if err != nil {
+		return goGitFailed(ctx, "create branch", err, "branch", branchName)
+	}
+	return nil
+}
+
+func (r *goGitRepo) resetBranch(ctx context.Context, branchName string, commitSha string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	refName := plumbing.NewBranchReferenceName(branchName)
+	err := r.repo.Storer.SetReference(plumbing.NewHashReference(refName, plumbing.NewHash(commitSha)))
+	if err != nil {
+		return goGitFailed(ctx, "reset branch", err, "branch", branchName)
+	}
+	return nil
+}
+
+func (r *goGitRepo) rewordCommit(ctx context.Context, branchName string, message string) (string, error) {
+	commit, err := r.commit(branchName)
+	if err != nil {
+		return "", goGitFailed(ctx, "reword commit", err, "branch", branchName)
+	}
+	signature, err := r.signature()
+	if err != nil {
+		return "", goGitFailed(ctx, "reword commit", err)
+	}
+	if !strings.HasSuffix(message, "\n") {
+		message += "\n"
+	}
+	commitHash, err := r.store(&object.Commit{
+		Author:       *signature,
+		Committer:    *signature,
+		Message:      message,
+		TreeHash:     commit.TreeHash,
+		ParentHashes: commit.ParentHashes,
+	})
+	if err != nil {
+		return "", goGitFailed(ctx, "reword commit", err, "branch", branchName)
+	}
+	return commitHash.String(), r.resetBranch(ctx, branchName, commitHash.String())
+}
+
+// The upstream configuration of the branch is removed with it
+func (r *goGitRepo) deleteBranch(ctx context.Context, branchName string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	refName := plumbing.NewBranchReferenceName(branchName)
+	if _, err := r.repo.Reference(refName, false); err != nil {
+		return goGitFailed(ctx, "delete branch", err, "branch", branchName)
+	}
+	if err := r.repo.Storer.RemoveReference(refName); err != nil {
+		return goGitFailed(ctx, "delete branch", err, "branch", branchName)
+	}
+
+	cfg, err := r.repo.Config()
+	if err != nil {
+		return goGitFailed(ctx, "delete branch", err, "branch", branchName)
+	}
+	if _, ok := cfg.Branches[branchName]; ok {
+		delete(cfg.Branches, branchName)
+		if err := r.repo.SetConfig(cfg); err != nil {
+			return goGitFailed(ctx, "delete branch", err, "branch", branchName)
+		}
+	}
+	return nil
+}
+
+func (r *goGitRepo) deleteRemoteBranch(ctx context.Context, remote string, branchName string) error {
+	refSpec := fmt.Sprintf(":%s", plumbing.NewBranchReferenceName(branchName))
+	if err := r.push(ctx, remote, refSpec); err != nil {
+		return goGitFailed(ctx, "delete remote branch", err, "remote", remote, "branch", branchName)
+	}
+	return nil
+}
+
+func (r *goGitRepo) pushSetUpstream(ctx context.Context, remote string, branchName string) error {
+	refName := plumbing.NewBranchReferenceName(branchName)
+	if err := r.push(ctx, remote, fmt.Sprintf("%[1]s:%[1]s", refName)); err != nil {
+		return goGitFailed(ctx, "push", err, "remote", remote, "branch", branchName)
+	}
+	return r.setUpstream(ctx, remote, branchName)
+}
+
+func (r *goGitRepo) pushForce(ctx context.Context, remote string, branchName string) error {
+	refName := plumbing.NewBranchReferenceName(branchName)
+	if err := r.push(ctx, remote, fmt.Sprintf("+%[1]s:%[1]s", refName)); err != nil {
+		return goGitFailed(ctx, "force push", err, "remote", remote, "branch", branchName)
+	}
+	return r.setUpstream(ctx, remote, branchName)
+}
+
+// The rejection reason of the remote is part of the error returned by go-git
+func (r *goGitRepo) pushRef(ctx context.Context, remote string, commitSha string, ref string) error {
+	if err := r.push(ctx, remote, commitSha+":"+ref); err != nil {
+		return goGitFailed(ctx, "push", err, "remote", remote, "commit", commitSha, "ref", ref)
+	}
+	return nil
+}
+
+func (r *goGitRepo) push(ctx context.Context, remote string, refSpec string) error {
+	auth, err := r.auth(remote)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	err = r.repo.PushContext(ctx, &git.PushOptions{
+		RemoteName: remote,
+		RefSpecs:   []config.RefSpec{config.RefSpec(refSpec)},
+		Auth:       auth,
+	})
+	if errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return nil
+	}
+	return err
+}
+
+func (r *goGitRepo) setUpstream(ctx context.Context, remote string, branchName string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cfg, err := r.repo.Config()
+	if err != nil {
+		return goGitFailed(ctx, "set upstream", err, "branch", branchName)
+	}
+	cfg.Branches[branchName] = &config.Branch{
+		Name:   branchName,
+		Remote: remote,
+		Merge:  plumbing.NewBranchReferenceName(branchName),
+	}
+	if err := r.repo.SetConfig(cfg); err != nil {
+		return goGitFailed(ctx, "set upstream", err, "branch", branchName)
+	}
+	return nil
+}
+
+func (r *goGitRepo) fetch(ctx context.Context, remote string) error {
+	auth, err := r.auth(remote)
+	if err != nil {
+		return goGitFailed(ctx, "fetch", err, "remote", remote)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	err = r.repo.FetchContext(ctx, &git.FetchOptions{
+		RemoteName: remote,
+		Prune:      true,
+		Auth:       auth,
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return goGitFailed(ctx, "fetch", err, "remote", remote)
+	}
+	return nil
+}
+
+// First url of the remote, as `git remote get-url`
+func (r *goGitRepo) remoteUrl(ctx context.Context, remote string) (string, error) {
+	gitRemote, err := r.repo.Remote(remote)
+	if err != nil {
+		return "", goGitFailed(ctx, "remote url", err, "remote", remote)
+	}
+	urls := gitRemote.Config().URLs
+	if len(urls) == 0 {
+		return "", goGitFailed(ctx, "remote url", fmt.Errorf("remote without url"), "remote", remote)
+	}
+	return urls[0], nil
+}
+
+// No auth lets go-git use the ssh-agent for SSH remotes
+func (r *goGitRepo) auth(remote string) (transport.AuthMethod, error) {
+	gitRemote, err := r.repo.Remote(remote)
+	if err != nil {
+		return nil, err
+	}
+	urls := gitRemote.Config().URLs
+	username := os.Getenv("GIT_USERNAME")
+	if len(urls) == 0 || !strings.HasPrefix(urls[0], "http") || username == "" {
+		return nil, nil
+	}
+	return &http.BasicAuth{Username: username, Password: os.Getenv("GIT_PASSWORD")}, nil
+}
+
+// Output in the format of `git diff --name-status --find-renames --find-copies -z`,
+// only exact copies of modified files are detected as go-git has no copy detection.
+// Only the changes made on `to` since its merge base with `from` are listed
+func (r *goGitRepo) diffNameStatus(ctx context.Context, from string, to string) ([]byte, error) {
+	commits := make([]*object.Commit, 2)
+	for i, rev := range []string{from, to} {
+		commit, err := r.commit(rev)
+		if err != nil {
+			return nil, goGitFailed(ctx, "diff", err, "revision", rev)
+		}
+		commits[i] = commit
+	}
+	baseCommit, err := r.mergeBase(commits[0], commits[1])
+	if err != nil {
+		return nil, goGitFailed(ctx, "diff", err, "from", from, "to", to)
+	}
+	trees := make([]*object.Tree, 2)
+	for i, commit := range []*object.Commit{baseCommit, commits[1]} {
+		if trees[i], err = commit.Tree(); err != nil {
+			return nil, goGitFailed(ctx, "diff", err, "commit", commit.Hash.String())
+		}
+	}
+
+	// Same rename similarity threshold as the git CLI
+	changes, err := object.DiffTreeWithOptions(ctx, trees[0], trees[1], &object.DiffTreeOptions{DetectRenames: true, RenameScore: 50})
+	if err != nil {
+		return nil, goGitFailed(ctx, "diff", err, "from", from, "to", to)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changePath(changes[i]) < changePath(changes[j]) })
+
+	// As `--find-copies` the sources of the copies are the modified files
+	copySources := make(map[plumbing.Hash]string)
+	for _, change := range changes {
+		if change.From.Name != "" && change.From.Name == change.To.Name {
+			if _, found := copySources[change.From.TreeEntry.Hash]; !found {
+				copySources[change.From.TreeEntry.Hash] = change.From.Name
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	for _, change := range changes {
+		action, err := change.Action()
+		if err != nil {
+			return nil, goGitFailed(ctx, "diff", err, "from", from, "to", to)
+		}
+		if change.From.Name != "" && change.To.Name != "" && change.From.Name != change.To.Name {
+			fmt.Fprintf(&out, "R\x00%s\x00%s\x00", change.From.Name, change.To.Name)
+			continue
+		}
+		if source, found := copySources[change.To.TreeEntry.Hash]; found && action == merkletrie.Insert {
+			fmt.Fprintf(&out, "C\x00%s\x00%s\x00", source, change.To.Name)
+			continue
+		}
+		status := "M"
+		switch action {
+		case merkletrie.Insert:
+			status = "A"
+		case merkletrie.Delete:
+			status = "D"
+		}
+		fmt.Fprintf(&out, "%s\x00%s\x00", status, changePath(change))
+	}
+	return out.Bytes(), nil
+}
+
+func changePath(change *object.Change) string {
+	if change.To.Name != "" {
+		return change.To.Name
+	}
+	return change.From.Name
+}
+
+// Supports the `^{tree}` suffix on top of the revisions resolved by go-git
+func (r *goGitRepo) revParse(ctx context.Context, ref string) (string, error) {
+	rev, peelTree := strings.CutSuffix(ref, "^{tree}")
+	if !peelTree {
+		hash, err := r.repo.ResolveRevision(plumbing.Revision(rev))
+		if err != nil {
+			return "", goGitFailed(ctx, "rev-parse", err, "ref", ref)
+		}
+		return hash.String(), nil
+	}
+
+	commit, err := r.commit(rev)
+	if err != nil {
+		return "", goGitFailed(ctx, "rev-parse", err, "ref", ref)
+	}
+	return commit.TreeHash.String(), nil
+}
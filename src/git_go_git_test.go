@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/google/go-cmp/cmp"
+)
+
+// In-memory repository where the two commits of `origin/big` change d1/file and d2/file, adds d1/new
+// deletes the only file of d3, moves d5/old to d6/new and copies d8/source to d7/copy before changing it,
+// while `main` moved on and changed d2/file and added d4/file
+func fixtureGoGitRepo(t *testing.T) *goGitRepo {
+	repo, err := git.Init(memory.NewStorage(), memfs.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, _ := repo.Config()
+	cfg.User.Name = "BiT"
+	cfg.User.Email = "bit@example.com"
+	if err := repo.SetConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	worktree, _ := repo.Worktree()
+
+	commit := func(author string, files map[string]string, removed ...string) plumbing.Hash {
+		for file, content := range files {
+			if err := util.WriteFile(worktree.Filesystem, file, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := worktree.Add(file); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, file := range removed {
+			if _, err := worktree.Remove(file); err != nil {
+				t.Fatal(err)
+			}
+		}
+		hash, err := worktree.Commit("change by "+author, &git.CommitOptions{
+			Author: &object.Signature{Name: author, Email: author + "@example.com", When: time.Unix(1700000000, 0).UTC()},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+
+	baseHash := commit("Base", map[string]string{"d1/file": "main", "d2/file": "main", "d3/file": "main", "d5/old": "moved", "d8/source": "copied"})
+	commit("Alice", map[string]string{"d1/file": "big", "d1/new": "big", "d7/copy": "copied"})
+	bigHash := commit("Bob", map[string]string{"d2/file": "big", "d6/new": "moved", "d8/source": "changed"}, "d3/file", "d5/old")
+	if err := repo.Storer.SetReference(plumbing.NewHashReference("refs/remotes/origin/big", bigHash)); err != nil {
+		t.Fatal(err)
+	}
+	if err := worktree.Checkout(&git.CheckoutOptions{Hash: baseHash, Force: true}); err != nil {
+		t.Fatal(err)
+	}
+	mainHash := commit("Main", map[string]string{"d2/file": "main2", "d4/file": "main"})
+	if err := repo.Storer.SetReference(plumbing.NewHashReference("refs/heads/main", mainHash)); err != nil {
+		t.Fatal(err)
+	}
+	return &goGitRepo{repo: repo}
+}
+
+func goGitFiles(t *testing.T, repo *goGitRepo, rev string) map[string]string {
+	commit, err := repo.commit(rev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, _ := commit.Files()
+	contents := make(map[string]string)
+	files.ForEach(func(file *object.File) error {
+		contents[file.Name], _ = file.Contents()
+		return nil
+	})
+	return contents
+}
+
+var goGitCommitFilesTests = []struct {
+	description   string
+	files         []string
+	expectedFiles map[string]string
+	expectedErr   error
+}{
+	{
+		description:   "Only the given files are taken from the source",
+		files:         []string{"d1/file", "d1/new"},
+		expectedFiles: map[string]string{"d1/file": "big", "d1/new": "big", "d2/file": "main2", "d3/file": "main", "d4/file": "main", "d5/old": "moved", "d8/source": "copied"},
+	},
+	{
+		description:   "Files missing in the source are removed with their empty directory",
+		files:         []string{"d3/file"},
+		expectedFiles: map[string]string{"d1/file": "main", "d2/file": "main2", "d4/file": "main", "d5/old": "moved", "d8/source": "copied"},
+	},
+	{
+		description: "Files changed on both branches are conflicts",
+		files:       []string{"d1/file", "d2/file"},
+		expectedErr: &ConflictError{Files: []string{"d2/file"}},
+	},
+}
+
+func TestGoGitCommitFiles(t *testing.T) {
+	ctxWithSilentLogger := ContextWithSilentLogger(context.Background())
+
+	for _, tt := range goGitCommitFilesTests {
+		t.Run(tt.description, func(t *testing.T) {
+			repo := fixtureGoGitRepo(t)
+
+			commitSha, err := repo.commitFiles(ctxWithSilentLogger, "main", "origin/big", tt.files, "split")
+			if tt.expectedErr != nil {
+				diff := cmp.Diff(err, tt.expectedErr)
+				if diff != "" {
+					t.Errorf("%v", diff)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			diff := cmp.Diff(goGitFiles(t, repo, commitSha), tt.expectedFiles)
+			if diff != "" {
+				t.Errorf("%v", diff)
+			}
+			commit, _ := repo.commit(commitSha)
+			if commit.Message != "split\n" || commit.Author.Name != "BiT" {
+				t.Errorf("got message '%s' by '%s', want 'split\\n' by 'BiT'", commit.Message, commit.Author.Name)
+			}
+		})
+	}
+}
+
+var goGitReplayCommitsTests = []struct {
+	description     string
+	files           []string
+	expectedFiles   map[string]string
+	expectedAuthors []string
+	expectedErr     error
+}{
+	{
+		description:     "Commits without changes to the files are skipped",
+		files:           []string{"d1/file", "d1/new"},
+		expectedFiles:   map[string]string{"d1/file": "big", "d1/new": "big", "d2/file": "main2", "d3/file": "main", "d4/file": "main", "d5/old": "moved", "d8/source": "copied"},
+		expectedAuthors: []string{"Alice"},
+	},
+	{
+		description:     "Each commit is replayed with its author",
+		files:           []string{"d1/file", "d3/file"},
+		expectedFiles:   map[string]string{"d1/file": "big", "d2/file": "main2", "d4/file": "main", "d5/old": "moved", "d8/source": "copied"},
+		expectedAuthors: []string{"Bob", "Alice"},
+	},
+	{
+		description: "Files changed on both branches are conflicts of their commit",
+		files:       []string{"d1/file", "d2/file"},
+		expectedErr: &ConflictError{Files: []string{"d2/file"}, Commit: "origin/big"},
+	},
+	{
+		description: "Fail when no commit changes the files",
+		files:       []string{"d4/file"},
+		expectedErr: ErrNoChanges,
+	},
+}
+
+func TestGoGitReplayCommits(t *testing.T) {
+	ctxWithSilentLogger := ContextWithSilentLogger(context.Background())
+
+	for _, tt := range goGitReplayCommitsTests {
+		t.Run(tt.description, func(t *testing.T) {
+			repo := fixtureGoGitRepo(t)
+
+			tipSha, err := repo.replayCommits(ctxWithSilentLogger, "main", "origin/big", tt.files)
+			if errors.Is(tt.expectedErr, ErrNoChanges) {
+				if !errors.Is(err, ErrNoChanges) {
+					t.Errorf("got '%v', want '%v'", err, tt.expectedErr)
+				}
+				return
+			}
+			if tt.expectedErr != nil {
+				conflictErr, _ := tt.expectedErr.(*ConflictError)
+				expectedErr := *conflictErr
+				expectedErr.Commit, _ = repo.revParse(ctxWithSilentLogger, conflictErr.Commit)
+				diff := cmp.Diff(err, &expectedErr)
+				if diff != "" {
+					t.Errorf("%v", diff)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			diff := cmp.Diff(goGitFiles(t, repo, tipSha), tt.expectedFiles)
+			if diff != "" {
+				t.Errorf("%v", diff)
+			}
+			mainSha, _ := repo.revParse(ctxWithSilentLogger, "main")
+			var gotAuthors []string
+			for commit, _ := repo.commit(tipSha); commit.Hash.String() != mainSha; commit, _ = commit.Parent(0) {
+				if commit.Message != "change by "+commit.Author.Name || !commit.Author.When.Equal(time.Unix(1700000000, 0)) {
+					t.Errorf("got message '%s' at %v, want the original ones", commit.Message, commit.Author.When)
+				}
+				gotAuthors = append(gotAuthors, commit.Author.Name)
+			}
+			diff = cmp.Diff(gotAuthors, tt.expectedAuthors)
+			if diff != "" {
+				t.Errorf("%v", diff)
+			}
+		})
+	}
+}
+
+func TestGoGitDiffNameStatus(t *testing.T) {
+	ctxWithSilentLogger := ContextWithSilentLogger(context.Background())
+	repo := fixtureGoGitRepo(t)
+
+	gotDiff, err := repo.diffNameStatus(ctxWithSilentLogger, "main", "origin/big")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diff := cmp.Diff(string(gotDiff), "M\x00d1/file\x00A\x00d1/new\x00M\x00d2/file\x00D\x00d3/file\x00R\x00d5/old\x00d6/new\x00C\x00d8/source\x00d7/copy\x00M\x00d8/source\x00")
+	if diff != "" {
+		t.Errorf("%v", diff)
+	}
+}
+
+func TestGoGitRemoteUrl(t *testing.T) {
+	ctxWithSilentLogger := ContextWithSilentLogger(context.Background())
+	repo := fixtureGoGitRepo(t)
+
+	_, err := repo.repo.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{"git@github.com:o/r.git"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotUrl, err := repo.remoteUrl(ctxWithSilentLogger, "origin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotUrl != "git@github.com:o/r.git" {
+		t.Errorf("got '%s', want 'git@github.com:o/r.git'", gotUrl)
+	}
+	if _, err := repo.remoteUrl(ctxWithSilentLogger, "upstream"); err == nil {
+		t.Errorf("got no error for a missing remote")
+	}
+}
+
+func TestGoGitRewordCommit(t *testing.T) {
+	ctxWithSilentLogger := ContextWithSilentLogger(context.Background())
+	repo := fixtureGoGitRepo(t)
+
+	bigSha, _ := repo.revParse(ctxWithSilentLogger, "origin/big")
+	if err := repo.createBranch(ctxWithSilentLogger, "split", bigSha); err != nil {
+		t.Fatal(err)
+	}
+	gotSha, err := repo.rewordCommit(ctxWithSilentLogger, "split", "title\n\nChange-Id: I1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gotCommit, err := repo.commit("split")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bigCommit, _ := repo.commit("origin/big")
+	diff := cmp.Diff(
+		[]string{gotCommit.Hash.String(), gotCommit.Message, gotCommit.TreeHash.String(), gotCommit.ParentHashes[0].String()},
+		[]string{gotSha, "title\n\nChange-Id: I1\n", bigCommit.TreeHash.String(), bigCommit.ParentHashes[0].String()})
+	if diff != "" {
+		t.Errorf("%v", diff)
+	}
+}
+
+func TestGoGitBranches(t *testing.T) {
+	ctxWithSilentLogger := ContextWithSilentLogger(context.Background())
+	repo := fixtureGoGitRepo(t)
+
+	mainSha, _ := repo.revParse(ctxWithSilentLogger, "main")
+	bigSha, _ := repo.revParse(ctxWithSilentLogger, "origin/big")
+
+	if err := repo.createBranch(ctxWithSilentLogger, "split", mainSha); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.createBranch(ctxWithSilentLogger, "split", bigSha); err == nil {
+		t.Error("creating an existing branch should fail")
+	}
+	if err := repo.resetBranch(ctxWithSilentLogger, "split", bigSha); err != nil {
+		t.Fatal(err)
+	}
+	gotTree, _ := repo.revParse(ctxWithSilentLogger, "split^{tree}")
+	bigTree, _ := repo.revParse(ctxWithSilentLogger, "origin/big^{tree}")
+	if gotTree != bigTree {
+		t.Errorf("got tree '%s', want '%s'", gotTree, bigTree)
+	}
+
+	if err := repo.deleteBranch(ctxWithSilentLogger, "split"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.revParse(ctxWithSilentLogger, "split"); err == nil {
+		t.Error("the deleted branch should not resolve")
+	}
+}
@@ -2,25 +2,263 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
 )
 
-func gitCheckout(ctx context.Context, branchName string) error {
-	_, err := runCmd(ctx, "git", "checkout", branchName)
+// The pushes and deletions of remote branches are skipped for branchless platforms
+func newGitOps(ctx context.Context, backend GitBackend, platform Platform) (*GitOps, error) {
+	if backend == GitBackendGoGit {
+		return newGoGitOps(ctx, platform)
+	}
+	gitOps := &GitOps{
+		gitCommitFiles:        gitCommitFiles,
+		gitReplayCommits:      gitReplayCommits,
+		gitCreateBranch:       gitCreateBranch,
+		gitResetBranch:        gitResetBranch,
+		gitDeleteBranch:       gitDeleteBranch,
+		gitDeleteRemoteBranch: GetRemoteBranchOpForPlatform(platform, gitDeleteRemoteBranch),
+		gitDiffNameStatus:     gitDiffNameStatus,
+		gitPushSetUpstream:    GetRemoteBranchOpForPlatform(platform, gitPushSetUpstream),
+		gitPushForce:          GetRemoteBranchOpForPlatform(platform, gitPushForce),
+		gitFetch:              gitFetch,
+		gitRevParse:           gitRevParse,
+		gitRewordCommit:       gitRewordCommit,
+		gitPushRef:            gitPushRef,
+		gitRemoteUrl:          gitRemoteUrl,
+	}
+	gitOps.platform = platformWithGitOps(platform, gitOps)
+	return gitOps, nil
+}
+
+// Builds a commit on top of `parent` with the changes `source` made to `files` since
+// its merge base with `parent`, applied as a patch so the changes made on `parent`
+// in the meantime are kept. A temporary index is used so the working tree and the
+// index of the repository are never modified
+func gitCommitFiles(ctx context.Context, parent string, source string, files []string, message string) (string, error) {
+	mergeBase, err := gitMergeBase(ctx, parent, source)
+	if err != nil {
+		return "", err
+	}
+	env, cleanup, err := gitTempIndex(ctx, parent)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+
+	if err = gitApplyFiles(ctx, env, mergeBase, source, files); err != nil {
+		return "", err
+	}
+	tree, err := runCmdWithEnv(ctx, env, nil, "git", "write-tree")
+	if err != nil {
+		return "", err
+	}
+	commit, err := runCmdWithInput(ctx, nil, "git", "commit-tree", strings.TrimSpace(string(tree[:])), "-p", parent, "-m", message)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(commit[:])), nil
+}
+
+// Replays on top of `parent` each commit of the first-parent history of `source` since
+// its merge base with `parent`, restricted to `files`. Commits left empty are skipped,
+// the others keep their author, date and message. Returns the last replayed commit,
+// or ErrNoChanges when all of them were skipped
+func gitReplayCommits(ctx context.Context, parent string, source string, files []string) (string, error) {
+	mergeBase, err := gitMergeBase(ctx, parent, source)
+	if err != nil {
+		return "", err
+	}
+	revList, err := runCmdWithInput(ctx, nil, "git", "rev-list", "--reverse", "--first-parent", mergeBase+".."+source)
+	if err != nil {
+		return "", err
+	}
+	parentTip, err := gitRevParse(ctx, parent)
+	if err != nil {
+		return "", err
+	}
+	tip := parentTip
+	tipTree, err := gitRevParse(ctx, tip+"^{tree}")
+	if err != nil {
+		return "", err
+	}
+	env, cleanup, err := gitTempIndex(ctx, tip)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+
+	for _, commit := range strings.Fields(string(revList[:])) {
+		err = gitApplyFiles(ctx, env, commit+"^", commit, files)
+		var conflictErr *ConflictError
+		if errors.As(err, &conflictErr) {
+			conflictErr.Commit = commit
+		}
+		if err != nil {
+			return "", err
+		}
+		rawTree, err := runCmdWithEnv(ctx, env, nil, "git", "write-tree")
+		if err != nil {
+			return "", err
+		}
+		tree := strings.TrimSpace(string(rawTree[:]))
+		if tree == tipTree {
+			continue
+		}
+
+		// Fields are separated by NUL as the message can contain anything else
+		rawCommit, err := runCmdWithInput(ctx, nil, "git", "show", "-s", "--date=raw", "--format=%an%x00%ae%x00%ad%x00%B", commit)
+		if err != nil {
+			return "", err
+		}
+		fields := strings.SplitN(string(rawCommit[:]), "\x00", 4)
+		if len(fields) != 4 {
+			log := LoggerFromContext(ctx)
+			log.Error("invalid commit metadata", "commit", commit)
+			return "", fmt.Errorf("invalid commit metadata")
+		}
+		authorEnv := []string{"GIT_AUTHOR_NAME=" + fields[0], "GIT_AUTHOR_EMAIL=" + fields[1], "GIT_AUTHOR_DATE=" + fields[2]}
+		message := strings.TrimRight(fields[3], "\n") + "\n"
+		newCommit, err := runCmdWithEnv(ctx, authorEnv, []byte(message), "git", "commit-tree", tree, "-p", tip)
+		if err != nil {
+			return "", err
+		}
+		tip, tipTree = strings.TrimSpace(string(newCommit[:])), tree
+	}
+	if tip == parentTip {
+		return "", ErrNoChanges
+	}
+	return tip, nil
+}
+
+func gitMergeBase(ctx context.Context, a string, b string) (string, error) {
+	resp, err := runCmdWithInput(ctx, nil, "git", "merge-base", a, b)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(resp[:])), nil
+}
+
+// Returns the environment of a temporary index filled with `tree`, to remove with `cleanup`
+func gitTempIndex(ctx context.Context, tree string) (env []string, cleanup func(), err error) {
+	indexDir, err := os.MkdirTemp("", "bit-index-")
+	if err != nil {
+		log := LoggerFromContext(ctx)
+		log.Error("failed to create temporary index", "error", err)
+		return nil, nil, err
+	}
+	cleanup = func() { os.RemoveAll(indexDir) }
+	env = []string{"GIT_INDEX_FILE=" + filepath.Join(indexDir, "index")}
+
+	_, err = runCmdWithEnv(ctx, env, nil, "git", "read-tree", tree)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return env, cleanup, nil
+}
+
+// Applies on the index of `env` the diff of `files` between `from` and `to`
+func gitApplyFiles(ctx context.Context, env []string, from string, to string, files []string) error {
+	patch, err := runCmdWithInput(ctx, nil, "git", append([]string{"--literal-pathspecs", "diff",
+		"--binary", "--full-index", "--no-renames", "--no-color", "--no-ext-diff",
+		"--src-prefix=a/", "--dst-prefix=b/", from, to, "--"}, files...)...)
+	if err != nil {
+		return err
+	}
+	// The files may have been reverted, leaving nothing to apply
+	if len(patch) == 0 {
+		return nil
+	}
+
+	// The 3-way fallback merges the changes already on the index, as the ones brought
+	// by a merge of the main branch in the branch to split
+	_, err = runCmdWithEnv(ctx, env, patch, "git", "apply", "--cached", "--3way")
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if conflicts := parseApplyConflicts(exitErr.Stderr); len(conflicts) > 0 {
+			return &ConflictError{Files: conflicts}
+		}
+	}
+	return err
+}
+
+// The changes of the big change to these files do not apply on the main branch,
+// usually because the main branch changed them too since the big change was started
+type ConflictError struct {
+	Files []string
+	// Set when replaying the history, the commit whose changes do not apply
+	Commit string
+}
+
+func (e *ConflictError) Error() string {
+	if e.Commit != "" {
+		return fmt.Sprintf("changes of commit %s do not apply on the main branch for: %s", e.Commit, strings.Join(e.Files, ", "))
+	}
+	return fmt.Sprintf("changes do not apply on the main branch for: %s", strings.Join(e.Files, ", "))
+}
+
+// Returned when none of the replayed commits changes the files on the main branch,
+// their changes are all already there so there is nothing to review
+var ErrNoChanges = errors.New("no changes left to apply on the main branch")
+
+var applyConflictRegex = regexp.MustCompile(`(?m)^(?:error: (.+): (?:patch does not apply|does not exist in index|already exists in index|does not match index)|Applied patch to '(.+)' with conflicts\.)$`)
+
+// Files reported by `git apply` as not applying on the index, in order and without duplicates
+func parseApplyConflicts(stderr []byte) []string {
+	var files []string
+	seen := make(map[string]bool)
+	for _, match := range applyConflictRegex.FindAllSubmatch(stderr, -1) {
+		file := string(match[1]) + string(match[2])
+		if !seen[file] {
+			seen[file] = true
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// Fails when the branch already exists
+func gitCreateBranch(ctx context.Context, branchName string, commitSha string) error {
+	_, err := runCmd(ctx, "git", "update-ref", "refs/heads/"+branchName, commitSha, "")
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func gitCheckoutNewBranch(ctx context.Context, branchName string) error {
-	_, err := runCmd(ctx, "git", "checkout", "-b", branchName)
+// Creates the branch or moves it to the commit when it already exists
+func gitResetBranch(ctx context.Context, branchName string, commitSha string) error {
+	_, err := runCmd(ctx, "git", "update-ref", "refs/heads/"+branchName, commitSha)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// Replaces the last commit of the branch by one with the same tree and parent and `message`
+func gitRewordCommit(ctx context.Context, branchName string, message string) (string, error) {
+	tree, err := gitRevParse(ctx, branchName+"^{tree}")
+	if err != nil {
+		return "", err
+	}
+	parent, err := gitRevParse(ctx, branchName+"^")
+	if err != nil {
+		return "", err
+	}
+	resp, err := runCmdWithInput(ctx, []byte(message), "git", "commit-tree", tree, "-p", parent)
+	if err != nil {
+		return "", err
+	}
+	commitSha := strings.TrimSpace(string(resp[:]))
+	return commitSha, gitResetBranch(ctx, branchName, commitSha)
+}
+
 func gitDeleteBranch(ctx context.Context, branchName string) error {
 	_, err := runCmd(ctx, "git", "branch", "-D", branchName)
 	if err != nil {
@@ -37,56 +275,61 @@ func gitDeleteRemoteBranch(ctx context.Context, remote string, branchName string
 	return nil
 }
 
-func gitStatus(ctx context.Context) ([]byte, error) {
-	resp, err := runCmd(ctx, "git", "status", "--porcelain")
+// Only the changes made on `to` since its merge base with `from` are listed,
+// the standard error is only logged so it never ends up in the `-z` output
+func gitDiffNameStatus(ctx context.Context, from string, to string) ([]byte, error) {
+	resp, err := runCmdWithInput(ctx, nil, "git", "diff", "--name-status", "--find-renames", "--find-copies", "-z", from+"..."+to)
 	if err != nil {
 		return resp, err
 	}
 	return resp, nil
 }
 
-func gitAdd(ctx context.Context, pathToAdd string) error {
-	_, err := runCmd(ctx, "git", "add", pathToAdd)
+// The output of the push is kept in the error so the rejection reason of the remote can be checked
+func gitPushRef(ctx context.Context, remote string, commitSha string, ref string) error {
+	resp, err := runCmd(ctx, "git", "push", remote, commitSha+":"+ref)
 	if err != nil {
-		return err
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(resp[:])))
 	}
 	return nil
 }
 
-func gitCommit(ctx context.Context, message string) error {
-	_, err := runCmd(ctx, "git", "commit", "-m", message)
+func gitPushSetUpstream(ctx context.Context, remote string, branchName string) error {
+	_, err := runCmd(ctx, "git", "push", "--set-upstream", remote, fmt.Sprintf("%[1]s:%[1]s", branchName))
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func gitCheckoutFiles(ctx context.Context, remote string, branchName string, allowDeletions bool) error {
-	gitFlags := []string{"checkout"}
-	if allowDeletions {
-		gitFlags = append(gitFlags, "--no-overlay")
-	}
-	gitFlags = append(gitFlags, fmt.Sprintf("%s/%s", remote, branchName), "--", ".")
-
-	_, err := runCmd(ctx, "git", gitFlags...)
+func gitPushForce(ctx context.Context, remote string, branchName string) error {
+	_, err := runCmd(ctx, "git", "push", "--force", "--set-upstream", remote, fmt.Sprintf("%[1]s:%[1]s", branchName))
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func gitReset(ctx context.Context) error {
-	_, err := runCmd(ctx, "git", "reset")
+func gitFetch(ctx context.Context, remote string) error {
+	_, err := runCmd(ctx, "git", "fetch", "--prune", remote)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func gitPushSetUpstream(ctx context.Context, remote string, branchName string) error {
-	_, err := runCmd(ctx, "git", "push", "--set-upstream", remote, fmt.Sprintf("%[1]s:%[1]s", branchName))
+func gitRemoteUrl(ctx context.Context, remote string) (string, error) {
+	resp, err := runCmdWithInput(ctx, nil, "git", "remote", "get-url", remote)
 	if err != nil {
-		return err
+		return "", err
 	}
-	return nil
+	return strings.TrimSpace(string(resp[:])), nil
+}
+
+func gitRevParse(ctx context.Context, ref string) (string, error) {
+	resp, err := runCmd(ctx, "git", "rev-parse", "--verify", ref)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(resp[:])), nil
 }
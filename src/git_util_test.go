@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var parseApplyConflictsTests = []struct {
+	description string
+	stderr      string
+	expected    []string
+}{
+	{
+		description: "Patches not applying on changed, missing and existing files",
+		stderr: "error: patch failed: d1/file:1\n" +
+			"error: d1/file: patch does not apply\n" +
+			"error: d2/file: does not exist in index\n" +
+			"error: d3/new: already exists in index\n",
+		expected: []string{"d1/file", "d2/file", "d3/new"},
+	},
+	{
+		description: "Files are reported once",
+		stderr: "error: patch failed: d1/file:1\n" +
+			"error: d1/file: patch does not apply\n" +
+			"error: patch failed: d1/file:20\n" +
+			"error: d1/file: patch does not apply\n",
+		expected: []string{"d1/file"},
+	},
+	{
+		description: "Conflicts of the 3-way merge",
+		stderr: "Applied patch to 'd1/file' with conflicts.\n" +
+			"Applied patch to 'd2/file' cleanly.\n",
+		expected: []string{"d1/file"},
+	},
+	{
+		description: "Errors without files are not conflicts",
+		stderr:      "error: corrupt patch at line 3\n",
+		expected:    nil,
+	},
+}
+
+func TestParseApplyConflicts(t *testing.T) {
+	for _, tt := range parseApplyConflictsTests {
+		t.Run(tt.description, func(t *testing.T) {
+			diff := cmp.Diff(parseApplyConflicts([]byte(tt.stderr)), tt.expected)
+			if diff != "" {
+				t.Errorf("%v", diff)
+			}
+		})
+	}
+}
+
+// Repository in a temporary folder, made the working directory for the test, where the commits
+// of `origin/big` change line 2 of d1/file, add d1/new, change line 1 of d2/file, delete d3/file
+// and change d5/file, while `main` moved on, changed line 3 of d2/file, d5/file and added d4/file
+func fixtureGitRepo(t *testing.T) map[string]string {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	git := func(env []string, args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Env = append(os.Environ(), env...)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, output)
+		}
+		return strings.TrimSpace(string(output))
+	}
+	commit := func(author string, date string, message string, files map[string]string, removed ...string) string {
+		for file, content := range files {
+			if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if len(removed) > 0 {
+			git(nil, append([]string{"rm", "-q"}, removed...)...)
+		}
+		git(nil, "add", "-A")
+		authorEnv := []string{"GIT_AUTHOR_NAME=" + author, "GIT_AUTHOR_EMAIL=" + author + "@example.com", "GIT_AUTHOR_DATE=" + date}
+		git(authorEnv, "commit", "-q", "-m", message)
+		return git(nil, "rev-parse", "HEAD")
+	}
+
+	git(nil, "init", "-q", "-b", "main")
+	git(nil, "config", "user.name", "BiT")
+	git(nil, "config", "user.email", "bit@example.com")
+	commit("Base", "1700000000 +0000", "base", map[string]string{
+		"d1/file": "a\nb\nc\n", "d2/file": "a\nb\nc\n", "d3/file": "main\n", "d5/file": "main\n",
+	})
+	git(nil, "checkout", "-q", "-b", "big")
+	alice := commit("Alice", "1700000100 +0100", "change by Alice\n\nwith details", map[string]string{
+		"d1/file": "a\nbig\nc\n", "d1/new": "big\n",
+	})
+	bob := commit("Bob", "1700000200 +0000", "change by Bob", map[string]string{
+		"d2/file": "big\nb\nc\n", "d5/file": "big\n",
+	}, "d3/file")
+	git(nil, "update-ref", "refs/remotes/origin/big", bob)
+	git(nil, "checkout", "-q", "main")
+	git(nil, "branch", "-q", "-D", "big")
+	main := commit("Carol", "1700000300 +0000", "change on main", map[string]string{
+		"d2/file": "a\nb\nmain\n", "d4/file": "main\n", "d5/file": "main2\n",
+	})
+	return map[string]string{"alice": alice, "bob": bob, "main": main}
+}
+
+// Files and contents of the tree of `commit`
+func gitTreeFiles(t *testing.T, ctx context.Context, commit string) map[string]string {
+	rawFiles, err := runCmdWithInput(ctx, nil, "git", "ls-tree", "-r", "--name-only", commit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	for _, file := range strings.Fields(string(rawFiles)) {
+		content, err := runCmdWithInput(ctx, nil, "git", "show", commit+":"+file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[file] = string(content)
+	}
+	return files
+}
+
+var gitCommitFilesTests = []struct {
+	description   string
+	files         []string
+	expectedFiles map[string]string
+	expectedErr   *ConflictError
+}{
+	{
+		description: "Changes are merged with the ones made on the moved main branch",
+		files:       []string{"d1/file", "d1/new", "d2/file", "d3/file"},
+		expectedFiles: map[string]string{
+			"d1/file": "a\nbig\nc\n", "d1/new": "big\n", "d2/file": "big\nb\nmain\n", "d4/file": "main\n", "d5/file": "main2\n",
+		},
+	},
+	{
+		description: "Only the given files are taken from the source",
+		files:       []string{"d1/new"},
+		expectedFiles: map[string]string{
+			"d1/file": "a\nb\nc\n", "d1/new": "big\n", "d2/file": "a\nb\nmain\n", "d3/file": "main\n", "d4/file": "main\n", "d5/file": "main2\n",
+		},
+	},
+	{
+		description: "Fail on the same lines changed on both branches",
+		files:       []string{"d1/file", "d5/file"},
+		expectedErr: &ConflictError{Files: []string{"d5/file"}},
+	},
+}
+
+func TestGitCommitFiles(t *testing.T) {
+	ctxWithSilentLogger := ContextWithSilentLogger(context.Background())
+
+	for _, tt := range gitCommitFilesTests {
+		t.Run(tt.description, func(t *testing.T) {
+			commits := fixtureGitRepo(t)
+
+			gotSha, gotErr := gitCommitFiles(ctxWithSilentLogger, "main", "origin/big", tt.files, "split")
+			if tt.expectedErr != nil {
+				var conflictErr *ConflictError
+				if !errors.As(gotErr, &conflictErr) {
+					t.Fatalf("got '%v', want a ConflictError", gotErr)
+				}
+				if diff := cmp.Diff(conflictErr, tt.expectedErr); diff != "" {
+					t.Errorf("%v", diff)
+				}
+				return
+			}
+			if gotErr != nil {
+				t.Fatal(gotErr)
+			}
+
+			gotParent, _ := gitRevParse(ctxWithSilentLogger, gotSha+"^")
+			if gotParent != commits["main"] {
+				t.Errorf("got parent '%s', want '%s'", gotParent, commits["main"])
+			}
+			diff := cmp.Diff(gitTreeFiles(t, ctxWithSilentLogger, gotSha), tt.expectedFiles)
+			if diff != "" {
+				t.Errorf("%v", diff)
+			}
+		})
+	}
+}
+
+var gitReplayCommitsTests = []struct {
+	description     string
+	files           []string
+	expectedFiles   map[string]string
+	expectedCommits []string
+	// Name of the conflicting commit in the fixture
+	expectedConflict string
+	expectedErr      error
+}{
+	{
+		description: "Each commit is replayed with its author, date and message",
+		files:       []string{"d1/file", "d2/file", "d3/file"},
+		expectedFiles: map[string]string{
+			"d1/file": "a\nbig\nc\n", "d2/file": "big\nb\nmain\n", "d4/file": "main\n", "d5/file": "main2\n",
+		},
+		expectedCommits: []string{
+			"Bob <Bob@example.com> 1700000200 +0000\nchange by Bob\n",
+			"Alice <Alice@example.com> 1700000100 +0100\nchange by Alice\n\nwith details\n",
+		},
+	},
+	{
+		description: "Commits without changes to the files are skipped",
+		files:       []string{"d1/new"},
+		expectedFiles: map[string]string{
+			"d1/file": "a\nb\nc\n", "d1/new": "big\n", "d2/file": "a\nb\nmain\n", "d3/file": "main\n", "d4/file": "main\n", "d5/file": "main2\n",
+		},
+		expectedCommits: []string{
+			"Alice <Alice@example.com> 1700000100 +0100\nchange by Alice\n\nwith details\n",
+		},
+	},
+	{
+		description:      "Fail on the commit changing the same lines as the main branch",
+		files:            []string{"d1/file", "d5/file"},
+		expectedConflict: "bob",
+		expectedErr:      &ConflictError{Files: []string{"d5/file"}},
+	},
+	{
+		description: "Fail when no commit changes the files",
+		files:       []string{"d4/file"},
+		expectedErr: ErrNoChanges,
+	},
+}
+
+func TestGitReplayCommits(t *testing.T) {
+	ctxWithSilentLogger := ContextWithSilentLogger(context.Background())
+
+	for _, tt := range gitReplayCommitsTests {
+		t.Run(tt.description, func(t *testing.T) {
+			commits := fixtureGitRepo(t)
+
+			gotSha, gotErr := gitReplayCommits(ctxWithSilentLogger, "main", "origin/big", tt.files)
+			if errors.Is(tt.expectedErr, ErrNoChanges) {
+				if !errors.Is(gotErr, ErrNoChanges) {
+					t.Errorf("got '%v', want '%v'", gotErr, tt.expectedErr)
+				}
+				return
+			}
+			if tt.expectedErr != nil {
+				var conflictErr *ConflictError
+				if !errors.As(gotErr, &conflictErr) {
+					t.Fatalf("got '%v', want a ConflictError", gotErr)
+				}
+				expectedErr := *tt.expectedErr.(*ConflictError)
+				expectedErr.Commit = commits[tt.expectedConflict]
+				if diff := cmp.Diff(conflictErr, &expectedErr); diff != "" {
+					t.Errorf("%v", diff)
+				}
+				return
+			}
+			if gotErr != nil {
+				t.Fatal(gotErr)
+			}
+
+			rawCommits, err := runCmdWithInput(ctxWithSilentLogger, nil, "git", "log", "--date=raw", "--format=%an <%ae> %ad%n%B%x00", "main.."+gotSha)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var gotCommits []string
+			for _, rawCommit := range strings.Split(string(rawCommits), "\x00") {
+				if rawCommit = strings.TrimLeft(rawCommit, "\n"); rawCommit != "" {
+					gotCommits = append(gotCommits, rawCommit)
+				}
+			}
+			diff := cmp.Diff(gotCommits, tt.expectedCommits)
+			if diff != "" {
+				t.Errorf("%v", diff)
+			}
+			diff = cmp.Diff(gitTreeFiles(t, ctxWithSilentLogger, gotSha), tt.expectedFiles)
+			if diff != "" {
+				t.Errorf("%v", diff)
+			}
+		})
+	}
+}
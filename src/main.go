@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log/slog"
 	"os"
@@ -11,6 +12,8 @@ type BigChange struct {
 	Id       string    `json:"id"`
 	Domains  []*Domain `json:"domains"`
 	Settings *Settings `json:"settings"`
+	// Url of the tracking issue or umbrella PR, resolved at runtime
+	TrackingUrl string `json:"-"`
 }
 
 type Settings struct {
@@ -23,20 +26,125 @@ type Settings struct {
 	PrNameTemplate     string `json:"prNameTemplate"`
 	PrDescTemplate     string `json:"prDescTemplate"`
 	OutputTemplate     string `json:"outputTemplate"`
+	// Replays the commits of the branch to split on the domain branches, keeping their
+	// author, date and message, instead of a single commit from `commitMsgTemplate`
+	PreserveHistory bool `json:"preserveHistory"`
+	// Line of the tracking checklist, rendered for each domain with a PR
+	TrackingTemplate string `json:"trackingTemplate"`
+	// Lists the PRs of all the domains in a tracking issue or umbrella PR
+	Tracking *Tracking `json:"tracking"`
+	// Added to all the PRs, on top of the domain ones, placeholders are replaced
+	Labels []string `json:"labels"`
+	// Overridden by the domain milestone, only supported by GitHub
+	Milestone string `json:"milestone"`
+	// Ids of the work items linked to all the PRs, only supported by Azure DevOps
+	WorkItems []int `json:"workItems"`
+	// Reviewers of the domain teams must approve the PRs, only supported by azure-api
+	RequiredReviewers bool `json:"requiredReviewers"`
+	// Merges the PRs once approved and green, the platform auto-merge is enabled on creation
+	AutoMerge *AutoMerge `json:"autoMerge"`
+	// How files matching several domains are assigned: "first-match" (default) or "most-specific"
+	AssignmentStrategy AssignmentStrategy `json:"assignmentStrategy"`
+	// How renamed files are assigned: "split" (default) each path to its own domain,
+	// "destination" or "source" the whole rename to the domain of the new or old path
+	RenamePolicy RenamePolicy `json:"renamePolicy"`
+	// Builds the domains from a CODEOWNERS file instead of (or on top of) `domains`
+	CodeOwners *CodeOwners `json:"codeOwners"`
+	// Repository used by the bitbucket platform
+	Bitbucket *BitbucketSettings `json:"bitbucket"`
+	// Repository used by the gitea platform
+	Gitea *GiteaSettings `json:"gitea"`
+	// Repository used by the gerrit platform
+	Gerrit *GerritSettings `json:"gerrit"`
+	// Repository used by the github-api platform
+	GitHub *GitHubSettings `json:"github"`
+	// Repository used by the azure-api platform
+	Azure *AzureSettings `json:"azure"`
+	// Passed as is to the platform plugin
+	Plugin json.RawMessage `json:"plugin,omitempty"`
+}
+
+// `method` defaults to merge
+type AutoMerge struct {
+	Method       MergeMethod `json:"method"`
+	DeleteBranch bool        `json:"deleteBranch"`
+}
+
+// `type` defaults to issue, the umbrella PR is opened as draft from the branch to split
+type Tracking struct {
+	Type        TrackingType `json:"type"`
+	Title       string       `json:"title"`
+	Description string       `json:"description"`
+}
+
+type CodeOwners struct {
+	Path    string            `json:"path"`
+	GroupBy CodeOwnersGroupBy `json:"groupBy"`
+}
+
+// Bitbucket Cloud is used unless `server` is set, `project` is only used by Bitbucket Server
+type BitbucketSettings struct {
+	BaseUrl    string `json:"baseUrl"`
+	Server     bool   `json:"server"`
+	Workspace  string `json:"workspace"`
+	Project    string `json:"project"`
+	Repository string `json:"repository"`
+}
+
+// Also used for Forgejo, `baseUrl` is the instance url without the `/api/v1` suffix
+type GiteaSettings struct {
+	BaseUrl    string `json:"baseUrl"`
+	Owner      string `json:"owner"`
+	Repository string `json:"repository"`
+}
+
+// `topic` groups the changes of all the domains, it defaults to the big change id
+type GerritSettings struct {
+	BaseUrl string `json:"baseUrl"`
+	Project string `json:"project"`
+	Topic   string `json:"topic"`
+}
+
+// `owner` and `repository` default to the ones of the remote url, `baseUrl`
+// defaults to https://api.github.com (https://<host>/api/v3 for GitHub Enterprise)
+type GitHubSettings struct {
+	BaseUrl    string `json:"baseUrl"`
+	Owner      string `json:"owner"`
+	Repository string `json:"repository"`
+}
+
+// `baseUrl` defaults to https://dev.azure.com, set it to the collection url for Azure DevOps Server
+type AzureSettings struct {
+	BaseUrl      string `json:"baseUrl"`
+	Organization string `json:"organization"`
+	Project      string `json:"project"`
+	Repository   string `json:"repository"`
 }
 
 type Domain struct {
-	Name        string      `json:"name"`
-	Id          string      `json:"id"`
-	Path        string      `json:"path"`
-	Teams       []Team      `json:"teams"`
-	Branch      *Branch     `json:"branch"`
-	PullRequest PullRequest `json:"pullRequest"`
+	Name         string      `json:"name"`
+	Id           string      `json:"id"`
+	Path         string      `json:"path"`
+	Paths        []string    `json:"paths"`
+	ExcludePaths []string    `json:"excludePaths"`
+	Teams        []Team      `json:"teams"`
+	Labels       []string    `json:"labels"`
+	Milestone    string      `json:"milestone"`
+	WorkItems    []int       `json:"workItems"`
+	Branch       *Branch     `json:"branch"`
+	PullRequest  PullRequest `json:"pullRequest"`
+	// Changed files assigned to the domain, resolved at runtime
+	Files []string `json:"-"`
+	// Paths are exact doublestar globs, without the prefix and folder matching of
+	// the config paths, set for the domains imported from CODEOWNERS
+	ExactPaths bool `json:"-"`
 }
 
 type Team struct {
 	Name string `json:"name"`
 	Url  string `json:"url"`
+	// Users or teams requested to review the PRs of the domain
+	Reviewers []string `json:"reviewers"`
 }
 
 type Branch struct {
@@ -56,39 +164,53 @@ type ExportResultsFunc func(context.Context, *Flags, *BigChange) error
 type BigIsTiny struct {
 	flags         *Flags
 	exportResults ExportResultsFunc
+	exportPlan    ExportPlanFunc
+	exportStatus  ExportStatusFunc
 	gitOps        *GitOps
+	stateOps      *StateOps
 }
 
 type Flags struct {
-	Cleanup        bool
-	Verbose        bool
-	ConfigPath     string
-	Platform       Platform
+	Command    Command
+	Cleanup    bool
+	Resume     bool
+	Verbose    bool
+	ConfigPath string
+	// Name of the platform in the registry or of an external plugin
+	Platform       string
 	FileOut        string
+	Format         OutputFormat
 	AllowDeletions bool
+	GitBackend     GitBackend
 }
 
-type GitZeroArgsFunc func(context.Context) error
 type GitOneArgStringFunc func(context.Context, string) error
 type GitTwoArgsStringFunc func(context.Context, string, string) error
-type GitStatusFunc func(context.Context) ([]byte, error)
-type CreatePrFunc func(context.Context, *Settings, string, string, string) (string, error)
-type AbandonPrFunc func(context.Context, string) error
-type GitCheckoutFilesFunc func(context.Context, string, string, bool) error
+type GitDiffNameStatusFunc func(context.Context, string, string) ([]byte, error)
+type GitRevParseFunc func(context.Context, string) (string, error)
+type GitCommitFilesFunc func(context.Context, string, string, []string, string) (string, error)
+type GitReplayCommitsFunc func(context.Context, string, string, []string) (string, error)
+type GitRewordCommitFunc func(context.Context, string, string) (string, error)
+type GitPushRefFunc func(context.Context, string, string, string) error
+type GitRemoteUrlFunc func(context.Context, string) (string, error)
 
+// None of the operations modify the working tree or the index of the repository
 type GitOps struct {
-	gitCheckout           GitOneArgStringFunc
-	gitCheckoutNewBranch  GitOneArgStringFunc
+	gitCommitFiles        GitCommitFilesFunc
+	gitReplayCommits      GitReplayCommitsFunc
+	gitCreateBranch       GitTwoArgsStringFunc
+	gitResetBranch        GitTwoArgsStringFunc
 	gitDeleteBranch       GitOneArgStringFunc
 	gitDeleteRemoteBranch GitTwoArgsStringFunc
-	gitStatus             GitStatusFunc
-	gitAdd                GitOneArgStringFunc
-	gitCommit             GitOneArgStringFunc
-	gitCheckoutFiles      GitCheckoutFilesFunc
-	gitReset              GitZeroArgsFunc
+	gitDiffNameStatus     GitDiffNameStatusFunc
 	gitPushSetUpstream    GitTwoArgsStringFunc
-	createPr              CreatePrFunc
-	abandonPr             AbandonPrFunc
+	gitPushForce          GitTwoArgsStringFunc
+	gitFetch              GitOneArgStringFunc
+	gitRevParse           GitRevParseFunc
+	gitRewordCommit       GitRewordCommitFunc
+	gitPushRef            GitPushRefFunc
+	gitRemoteUrl          GitRemoteUrlFunc
+	platform              Platform
 }
 
 func main() {
@@ -109,32 +231,48 @@ func main() {
 		os.Exit(2)
 	}
 
-	bigChange, err := setupConfig(ctx, jsonConfig)
+	platform, err := GetPlatform(flags.Platform)
+	if err != nil {
+		log.Error("failed to get the platform", "error", err)
+		os.Exit(1)
+	}
+
+	bigChange, err := setupConfig(ctx, jsonConfig, platform)
 	if err != nil {
 		os.Exit(3)
 	}
+	if bigChange.Settings.CodeOwners != nil {
+		err = importCodeOwners(ctx, bigChange)
+		if err != nil {
+			os.Exit(3)
+		}
+	}
 	log.Debug("config extracted from config file", "bigChange", bigChange)
 
+	gitOps, err := newGitOps(ctx, flags.GitBackend, platform)
+	if err != nil {
+		os.Exit(1)
+	}
+
 	bigIsTiny := BigIsTiny{
 		flags:         flags,
 		exportResults: exportResults,
-		gitOps: &GitOps{
-			gitCheckout:           gitCheckout,
-			gitCheckoutNewBranch:  gitCheckoutNewBranch,
-			gitDeleteBranch:       gitDeleteBranch,
-			gitDeleteRemoteBranch: gitDeleteRemoteBranch,
-			gitStatus:             gitStatus,
-			gitAdd:                gitAdd,
-			gitCommit:             gitCommit,
-			gitCheckoutFiles:      gitCheckoutFiles,
-			gitReset:              gitReset,
-			gitPushSetUpstream:    gitPushSetUpstream,
-			createPr:              GetCreatePrForPlatform(flags.Platform),
-			abandonPr:             GetAbandonPrForPlatform(flags.Platform),
-		},
+		exportPlan:    exportPlan,
+		exportStatus:  exportStatus,
+		gitOps:        gitOps,
+		stateOps:      newFileStateOps(defaultStateDir),
 	}
 
-	err = bigIsTiny.run(ctx, bigChange)
+	switch flags.Command {
+	case PlanCommand:
+		err = bigIsTiny.plan(ctx, bigChange)
+	case SyncCommand:
+		err = bigIsTiny.sync(ctx, bigChange)
+	case StatusCommand:
+		err = bigIsTiny.status(ctx, bigChange)
+	default:
+		err = bigIsTiny.run(ctx, bigChange)
+	}
 	if err != nil {
 		os.Exit(4)
 	}
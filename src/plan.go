@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+type Plan struct {
+	Domains    []*DomainPlan `json:"domains"`
+	Overlaps   []FileOverlap `json:"overlaps"`
+	Unassigned []string      `json:"unassigned"`
+}
+
+type DomainPlan struct {
+	Name      string   `json:"name"`
+	Id        string   `json:"id"`
+	Branch    string   `json:"branch"`
+	CommitMsg string   `json:"commitMsg"`
+	PrTitle   string   `json:"prTitle"`
+	PrBody    string   `json:"prBody"`
+	Files     []string `json:"files"`
+}
+
+type ExportPlanFunc func(context.Context, *Flags, *Plan) error
+
+// The plan only reads the git history, no checkout, commit, push or PR creation is done
+func (bit *BigIsTiny) plan(ctx context.Context, config *BigChange) error {
+	for _, domain := range config.Domains {
+		domain.initDomain(config)
+	}
+
+	changes, err := bit.listChangedFilesFromDiff(ctx, config.Settings)
+	if err != nil {
+		return err
+	}
+
+	assignment := bit.assignFiles(ctx, config, changes)
+
+	plan := &Plan{
+		Domains:    make([]*DomainPlan, 0, len(config.Domains)),
+		Overlaps:   assignment.Overlaps,
+		Unassigned: assignment.Unassigned,
+	}
+	for _, domain := range config.Domains {
+		if len(domain.Files) == 0 {
+			continue
+		}
+		plan.Domains = append(plan.Domains, &DomainPlan{
+			Name:      domain.Name,
+			Id:        domain.Id,
+			Branch:    domain.Branch.Name,
+			CommitMsg: config.generateFromTemplate(domain, config.Settings.CommitMsgTemplate),
+			PrTitle:   domain.PullRequest.Title,
+			PrBody:    domain.PullRequest.Body,
+			Files:     domain.Files,
+		})
+	}
+
+	return bit.exportPlan(ctx, bit.flags, plan)
+}
+
+func exportPlan(ctx context.Context, flags *Flags, plan *Plan) (err error) {
+	var fdOut *os.File
+	if flags.FileOut == "" {
+		fdOut = os.Stdout
+	} else {
+		fdOut, err = os.OpenFile(flags.FileOut, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+		if err != nil {
+			log := LoggerFromContext(ctx)
+			log.Error("failed to create plan file", "path", flags.FileOut, "error", err)
+			return err
+		}
+		defer fdOut.Close()
+	}
+
+	jsonFormattedPlan, err := json.MarshalIndent(plan, "", "    ")
+	if err != nil {
+		log := LoggerFromContext(ctx)
+		log.Error("failed to marshal plan", "error", err)
+		return err
+	}
+	fmt.Fprintln(fdOut, string(jsonFormattedPlan))
+
+	return nil
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type givenPlan struct {
+	flags  *Flags
+	gitOps *GitOps
+	config *BigChange
+}
+
+func checkExportPlan(expectedPlan *Plan) ExportPlanFunc {
+	return func(ctx context.Context, flags *Flags, plan *Plan) error {
+		diff := cmp.Diff(expectedPlan, plan)
+		if diff != "" {
+			return fmt.Errorf("%v", diff)
+		}
+		return nil
+	}
+}
+
+// Any call to an operation changing the repository or the platform fails the plan
+func fixtureReadOnlyGitOps(mods ...func(*GitOps)) *GitOps {
+	return fixtureGitOps(append([]func(*GitOps){func(g *GitOps) {
+		g.gitCommitFiles = func(ctx context.Context, s1, s2 string, files []string, s3 string) (string, error) {
+			return "", fmt.Errorf("gitCommitFiles should not be called")
+		}
+		g.gitReplayCommits = func(ctx context.Context, s1, s2 string, files []string) (string, error) {
+			return "", fmt.Errorf("gitReplayCommits should not be called")
+		}
+		g.gitCreateBranch = func(ctx context.Context, s1, s2 string) error {
+			return fmt.Errorf("gitCreateBranch should not be called")
+		}
+		g.gitPushSetUpstream = func(ctx context.Context, s1, s2 string) error {
+			return fmt.Errorf("gitPushSetUpstream should not be called")
+		}
+		platformOf(g).createPr = func(ctx context.Context, s1 *Settings, s2, s3, s4 string) (string, error) {
+			return "", fmt.Errorf("createPr should not be called")
+		}
+	}}, mods...)...)
+}
+
+var planTests = []struct {
+	description  string
+	given        givenPlan
+	expectedPlan *Plan
+	expectedErr  error
+}{
+	{
+		description: "Happy path",
+		given: givenPlan{
+			flags:  fixtureFlags(),
+			gitOps: fixtureReadOnlyGitOps(),
+			config: fixtureBigChange(),
+		},
+		expectedPlan: &Plan{
+			Domains: []*DomainPlan{
+				{
+					Name:      "dom1",
+					Id:        "AA",
+					Branch:    "bit-dom1-big-change-split",
+					CommitMsg: "implement new feature for dom1 at First Team AA(https://example_1.com) and {{team_name_2}}({{team_url_2}})",
+					PrTitle:   "AA dom1: Big change split",
+					PrBody:    "This change refers to this refactor for domain AA dom1: https://example.com",
+					Files:     []string{"domains/dom1/file1"},
+				},
+				{
+					Name:      "dom2",
+					Id:        "BB",
+					Branch:    "bit-dom2-big-change-split",
+					CommitMsg: "implement new feature for dom2 at Team BB 1(https://example_2.com) and Team BB 2(https://example_2_bis.com)",
+					PrTitle:   "BB dom2: Big change split",
+					PrBody:    "This change refers to this refactor for domain BB dom2: https://example.com",
+					Files:     []string{"domains/dom2/file2"},
+				},
+			},
+		},
+	},
+	{
+		description: "Deletions are planned when allowed",
+		given: givenPlan{
+			flags: fixtureFlags(func(f *Flags) {
+				f.AllowDeletions = true
+			}),
+			gitOps: fixtureReadOnlyGitOps(),
+			config: fixtureBigChange(func(bc *BigChange) {
+				bc.Domains = bc.Domains[2:]
+				bc.Settings.PrDescTemplate = ""
+			}),
+		},
+		expectedPlan: &Plan{
+			Domains: []*DomainPlan{
+				{
+					Name:      "dom3",
+					Id:        "CC",
+					Branch:    "bit-dom3-big-change-split",
+					CommitMsg: "implement new feature for dom3 at Team CC 1(https://example_2.com) and Team CC 2(https://example_2_bis.com)",
+					PrTitle:   "CC dom3: Big change split",
+					Files:     []string{"domains/dom3/file3"},
+				},
+			},
+			Unassigned: []string{"domains/dom1/file1", "domains/dom2/file2"},
+		},
+	},
+	{
+		description: "Renames keep their old path when deletions are not allowed",
+		given: givenPlan{
+			flags: fixtureFlags(),
+			gitOps: fixtureReadOnlyGitOps(func(g *GitOps) {
+				g.gitDiffNameStatus = func(ctx context.Context, s1, s2 string) ([]byte, error) {
+					return []byte("R087\x00domains/dom1/old\x00domains/dom2/new\x00D\x00domains/dom3/file3\x00"), nil
+				}
+			}),
+			config: fixtureBigChange(func(bc *BigChange) {
+				bc.Domains = bc.Domains[:2]
+				bc.Settings.PrDescTemplate = ""
+			}),
+		},
+		expectedPlan: &Plan{
+			Domains: []*DomainPlan{
+				{
+					Name:      "dom1",
+					Id:        "AA",
+					Branch:    "bit-dom1-big-change-split",
+					CommitMsg: "implement new feature for dom1 at First Team AA(https://example_1.com) and {{team_name_2}}({{team_url_2}})",
+					PrTitle:   "AA dom1: Big change split",
+					Files:     []string{"domains/dom1/old"},
+				},
+				{
+					Name:      "dom2",
+					Id:        "BB",
+					Branch:    "bit-dom2-big-change-split",
+					CommitMsg: "implement new feature for dom2 at Team BB 1(https://example_2.com) and Team BB 2(https://example_2_bis.com)",
+					PrTitle:   "BB dom2: Big change split",
+					Files:     []string{"domains/dom2/new"},
+				},
+			},
+		},
+	},
+	{
+		description: "Fail on a rename without its new path",
+		given: givenPlan{
+			flags: fixtureFlags(),
+			gitOps: fixtureReadOnlyGitOps(func(g *GitOps) {
+				g.gitDiffNameStatus = func(ctx context.Context, s1, s2 string) ([]byte, error) {
+					return []byte("R100\x00domains/dom1/old\x00"), nil
+				}
+			}),
+			config: fixtureBigChange(),
+		},
+		expectedErr: fmt.Errorf("invalid diff entry"),
+	},
+	{
+		description: "Fail on gitDiffNameStatus",
+		given: givenPlan{
+			flags: fixtureFlags(),
+			gitOps: fixtureReadOnlyGitOps(func(g *GitOps) {
+				g.gitDiffNameStatus = func(ctx context.Context, s1, s2 string) ([]byte, error) {
+					return nil, fmt.Errorf("gitDiffNameStatus failed")
+				}
+			}),
+			config: fixtureBigChange(),
+		},
+		expectedErr: fmt.Errorf("gitDiffNameStatus failed"),
+	},
+}
+
+func TestPlan(t *testing.T) {
+	ctxWithSilentLogger := ContextWithSilentLogger(context.Background())
+
+	for _, tt := range planTests {
+		t.Run(tt.description, func(t *testing.T) {
+			bit := &BigIsTiny{
+				exportPlan: checkExportPlan(tt.expectedPlan),
+				flags:      tt.given.flags,
+				gitOps:     tt.given.gitOps,
+			}
+			gotErr := bit.plan(ctxWithSilentLogger, tt.given.config)
+
+			// We get an error when we don't expect it or we don't get one when we expect it
+			if tt.expectedErr != nil != (gotErr != nil) {
+				t.Errorf("got '%v', want '%v'", gotErr, tt.expectedErr)
+			}
+			// We get a different error of what's expected
+			if tt.expectedErr != nil && gotErr != nil &&
+				tt.expectedErr.Error() != gotErr.Error() {
+				t.Errorf("got '%v', want '%v'", gotErr, tt.expectedErr)
+			}
+		})
+	}
+}
@@ -2,50 +2,208 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"os/exec"
 )
 
-type Platform int
+// Review platform hosting the PRs of the split branches, `head` is always the
+// name of the split branch
+type Platform interface {
+	// Returns the url of the created PR
+	CreatePr(ctx context.Context, settings *Settings, head, title, body string) (string, error)
+	// Returns an empty url when the branch has no open PR
+	FindPr(ctx context.Context, settings *Settings, head string) (string, error)
+	UpdatePr(ctx context.Context, settings *Settings, head, title, body string) error
+	// Closing a branch without PR is not an error
+	ClosePr(ctx context.Context, settings *Settings, head string) error
+	// Returns noPrStatus() when the branch has no PR
+	PrStatus(ctx context.Context, settings *Settings, head string) (*PrStatus, error)
+	AddReviewers(ctx context.Context, settings *Settings, head string, reviewers []string) error
+	AddLabels(ctx context.Context, settings *Settings, head string, labels []string) error
+	// `milestone` is the title of an open milestone
+	SetMilestone(ctx context.Context, settings *Settings, head string, milestone string) error
+	LinkWorkItems(ctx context.Context, settings *Settings, head string, workItems []int) error
+	// The PR is merged by the platform once approved and with passing checks
+	EnableAutoMerge(ctx context.Context, settings *Settings, head string, autoMerge *AutoMerge) error
+	// Returns the url of the created issue
+	CreateIssue(ctx context.Context, settings *Settings, title, body string) (string, error)
+	// Returns an empty url when no open issue has exactly this title
+	FindIssue(ctx context.Context, settings *Settings, title string) (string, error)
+	UpdateIssue(ctx context.Context, settings *Settings, issueUrl, title, body string) error
+}
+
+type MergeMethod string
 
 const (
-	Azure Platform = iota
-	GitHub
+	MergeMethodMerge  MergeMethod = "merge"
+	MergeMethodSquash MergeMethod = "squash"
+	MergeMethodRebase MergeMethod = "rebase"
 )
 
-func GetCreatePrForPlatform(p Platform) func(context.Context, *Settings, string, string, string) (string, error) {
-	switch p {
-	case Platform(GitHub):
-		return GitHubCreatePr
-	case Platform(Azure):
-		return AzureCreatePr
-	default:
-		panic("unreachable")
+// Implemented by the platforms reviewing changes without remote branches,
+// the pushes and deletions of the split branches are skipped for them
+type BranchlessPlatform interface {
+	Branchless() bool
+}
+
+// Implemented by the platforms only able to require the reviews when creating the PR,
+// the reviewers are then not added again when setting up the PR
+type RequiredReviewersPlatform interface {
+	CreatePrWithRequiredReviewers(ctx context.Context, settings *Settings, head, title, body string, reviewers []string) (string, error)
+}
+
+// Plugins receive the settings and decide themselves
+func supportsRequiredReviewers(platform Platform) bool {
+	switch platform.(type) {
+	case AzureApi, PlatformPlugin, RequiredReviewersPlatform:
+		return true
+	}
+	return false
+}
+
+// Platforms embedding unsupportedFeatures for AddLabels do not support labels
+func supportsLabels(platform Platform) bool {
+	switch platform.(type) {
+	case GitHub, GitHubApi, Azure, AzureApi, PlatformPlugin:
+		return true
+	}
+	return false
+}
+
+func supportsMilestone(platform Platform) bool {
+	switch platform.(type) {
+	case GitHub, GitHubApi, PlatformPlugin:
+		return true
+	}
+	return false
+}
+
+func supportsWorkItems(platform Platform) bool {
+	switch platform.(type) {
+	case Azure, AzureApi, PlatformPlugin:
+		return true
+	}
+	return false
+}
+
+func supportsAutoMerge(platform Platform) bool {
+	switch platform.(type) {
+	case GitHub, GitHubApi, Azure, AzureApi, PlatformPlugin:
+		return true
+	}
+	return false
+}
+
+// The Azure CLI can only squash or merge the PRs
+func supportsMergeMethod(platform Platform, method MergeMethod) bool {
+	if _, ok := platform.(Azure); ok {
+		return method != MergeMethodRebase
 	}
+	return supportsAutoMerge(platform)
 }
 
-func GetAbandonPrForPlatform(p Platform) func(context.Context, string) error {
-	switch p {
-	case Platform(GitHub):
-		return GitHubAbandonPr
-	case Platform(Azure):
-		return AzureAbandonPr
-	default:
-		panic("unreachable")
+// Returns the platform when the reviewers are required while creating the PR
+func requiredReviewersOnCreation(settings *Settings, platform Platform) (RequiredReviewersPlatform, bool) {
+	creator, ok := platform.(RequiredReviewersPlatform)
+	return creator, ok && settings.RequiredReviewers
+}
+
+// Implemented by the platforms running git commands themselves, so they go through
+// the git backend selected with `-git`
+type GitPlatform interface {
+	withGitOps(gitOps *GitOps) Platform
+}
+
+func platformWithGitOps(platform Platform, gitOps *GitOps) Platform {
+	if gitPlatform, ok := platform.(GitPlatform); ok {
+		return gitPlatform.withGitOps(gitOps)
 	}
+	return platform
+}
+
+type UnsupportedFeatureError struct {
+	Feature string
+}
+
+func (e *UnsupportedFeatureError) Error() string {
+	return fmt.Sprintf("%s not supported by the platform", e.Feature)
+}
+
+// Embedded by the platforms so the features they do not implement fail with an UnsupportedFeatureError
+type unsupportedFeatures struct{}
+
+func (unsupportedFeatures) AddReviewers(_ context.Context, _ *Settings, _ string, _ []string) error {
+	return &UnsupportedFeatureError{Feature: "reviewers"}
+}
+
+func (unsupportedFeatures) AddLabels(_ context.Context, _ *Settings, _ string, _ []string) error {
+	return &UnsupportedFeatureError{Feature: "labels"}
+}
+
+func (unsupportedFeatures) SetMilestone(_ context.Context, _ *Settings, _ string, _ string) error {
+	return &UnsupportedFeatureError{Feature: "milestone"}
+}
+
+func (unsupportedFeatures) LinkWorkItems(_ context.Context, _ *Settings, _ string, _ []int) error {
+	return &UnsupportedFeatureError{Feature: "work items"}
+}
+
+func (unsupportedFeatures) EnableAutoMerge(_ context.Context, _ *Settings, _ string, _ *AutoMerge) error {
+	return &UnsupportedFeatureError{Feature: "auto-merge"}
+}
+
+func (unsupportedFeatures) CreateIssue(_ context.Context, _ *Settings, _, _ string) (string, error) {
+	return "", &UnsupportedFeatureError{Feature: "issues"}
+}
+
+func (unsupportedFeatures) FindIssue(_ context.Context, _ *Settings, _ string) (string, error) {
+	return "", &UnsupportedFeatureError{Feature: "issues"}
+}
+
+func (unsupportedFeatures) UpdateIssue(_ context.Context, _ *Settings, _, _, _ string) error {
+	return &UnsupportedFeatureError{Feature: "issues"}
+}
+
+const DefaultPlatform = "github"
+
+// Built-in platforms, keyed by the name used with the `-p` flag
+var platforms = map[string]Platform{
+	"github":     GitHub{},
+	"github-api": GitHubApi{},
+	"azure":      Azure{},
+	"azure-api":  AzureApi{},
+	"gitlab":     GitLab{},
+	"bitbucket":  Bitbucket{},
+	"gitea":      Gitea{},
+	"forgejo":    Gitea{},
+	"gerrit":     Gerrit{},
+}
+
+// Names that are not built-in are looked up as external plugins in the PATH
+func GetPlatform(name string) (Platform, error) {
+	if platform, ok := platforms[name]; ok {
+		return platform, nil
+	}
+	if _, err := exec.LookPath(pluginExecutable(name)); err == nil {
+		return PlatformPlugin{Name: name}, nil
+	}
+	return nil, fmt.Errorf("platform '%s' is not supported", name)
+}
+
+func isBranchless(platform Platform) bool {
+	branchless, ok := platform.(BranchlessPlatform)
+	return ok && branchless.Branchless()
 }
 
-func (e Platform) String() string {
-	switch e {
-	case Azure:
-		return "Azure"
-	case GitHub:
-		return "GitHub"
-	default:
-		return fmt.Sprintf("%d", int(e))
+func GetRemoteBranchOpForPlatform(platform Platform, gitOp GitTwoArgsStringFunc) GitTwoArgsStringFunc {
+	if isBranchless(platform) {
+		return skipBranchPush
 	}
+	return gitOp
 }
 
-func (s Platform) MarshalJSON() ([]byte, error) {
-	return json.Marshal(s.String())
+func skipBranchPush(ctx context.Context, _ string, branchName string) error {
+	log := LoggerFromContext(ctx)
+	log.Debug("branch push skipped for branchless platform", "branch", branchName)
+	return nil
 }
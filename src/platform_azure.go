@@ -3,15 +3,45 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"os"
 	"strconv"
 )
 
 type AzurePr struct {
 	BaseUrl      string `json:"baseUrl"`
 	CodeReviewId int    `json:"codeReviewId"`
+	// Only listed by azureFindActivePr, used for the REST calls without `az repos` command
+	RepositoryId string `json:"repositoryId"`
+	Project      string `json:"project"`
 }
 
-func AzureCreatePr(ctx context.Context, settings *Settings, head, title, description string) (string, error) {
+type azurePrStatus struct {
+	AzurePr
+	Status      string `json:"status"`
+	IsDraft     bool   `json:"isDraft"`
+	MergeStatus string `json:"mergeStatus"`
+	Votes       []int  `json:"votes"`
+}
+
+type azurePolicy struct {
+	Status string `json:"status"`
+}
+
+type Azure struct {
+	unsupportedFeatures
+}
+
+func (Azure) CreatePr(ctx context.Context, settings *Settings, head, title, description string) (string, error) {
+	return azureCreatePr(ctx, settings, head, title, description, nil)
+}
+
+// The CLI cannot mark existing reviewers as required so they are given when creating the PR
+func (Azure) CreatePrWithRequiredReviewers(ctx context.Context, settings *Settings, head, title, description string, reviewers []string) (string, error) {
+	return azureCreatePr(ctx, settings, head, title, description, reviewers)
+}
+
+func azureCreatePr(ctx context.Context, settings *Settings, head, title, description string, requiredReviewers []string) (string, error) {
 	prFlags := []string{
 		"repos", "pr", "create",
 		"--source-branch", head,
@@ -24,6 +54,9 @@ func AzureCreatePr(ctx context.Context, settings *Settings, head, title, descrip
 	if settings.IsDraftPrs {
 		prFlags = append(prFlags, "--draft")
 	}
+	if len(requiredReviewers) > 0 {
+		prFlags = append(append(prFlags, "--required-reviewers"), requiredReviewers...)
+	}
 
 	resp, err := runCmd(ctx, "az", prFlags...)
 	if err != nil {
@@ -37,36 +70,293 @@ func AzureCreatePr(ctx context.Context, settings *Settings, head, title, descrip
 		return "", err
 	}
 
-	fullUrl := string(pr.BaseUrl) + "/pullrequest/" + strconv.Itoa(pr.CodeReviewId)
-	return fullUrl, nil
+	return pr.url(), nil
+}
+
+func (Azure) ClosePr(ctx context.Context, _ *Settings, sourceBranch string) error {
+	activePr, err := azureFindActivePr(ctx, sourceBranch)
+	if err != nil || activePr == nil {
+		return err
+	}
+
+	_, err = runCmd(ctx, "az", "repos", "pr", "update", "--id", strconv.Itoa(activePr.CodeReviewId), "--status", "abandoned")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Returns an empty url when the branch has no active PR
+func (Azure) FindPr(ctx context.Context, _ *Settings, sourceBranch string) (string, error) {
+	activePr, err := azureFindActivePr(ctx, sourceBranch)
+	if err != nil || activePr == nil {
+		return "", err
+	}
+	return activePr.url(), nil
+}
+
+func (Azure) UpdatePr(ctx context.Context, _ *Settings, sourceBranch, title, description string) error {
+	activePr, err := azureFindActivePr(ctx, sourceBranch)
+	if err != nil {
+		return err
+	}
+	if activePr == nil {
+		return fmt.Errorf("no active PR for branch '%s'", sourceBranch)
+	}
+
+	_, err = runCmd(ctx, "az", "repos", "pr", "update",
+		"--id", strconv.Itoa(activePr.CodeReviewId),
+		"--title", title,
+		"--description", description)
+	if err != nil {
+		return err
+	}
+
+	return nil
 }
 
-func AzureAbandonPr(ctx context.Context, sourceBranch string) error {
+func (Azure) PrStatus(ctx context.Context, _ *Settings, sourceBranch string) (*PrStatus, error) {
+	log := LoggerFromContext(ctx)
+
 	resp, err := runCmd(ctx, "az", "repos", "pr", "list",
 		"--top", "1",
-		"--status", "active",
+		"--status", "all",
 		"--source-branch", sourceBranch,
 		"--output", "json",
-		"--query", "[].{codeReviewId:codeReviewId}")
+		"--query", "[].{baseUrl:repository.webUrl, codeReviewId:codeReviewId, status:status, isDraft:isDraft, mergeStatus:mergeStatus, votes:reviewers[].vote}")
+	if err != nil {
+		return nil, err
+	}
+
+	var prs []azurePrStatus
+	if err := json.Unmarshal(resp, &prs); err != nil {
+		log.Error("failed to unmarshal the PR status", "error", err)
+		return nil, err
+	}
+	if len(prs) < 1 {
+		return noPrStatus(), nil
+	}
+
+	resp, err = runCmd(ctx, "az", "repos", "pr", "policy", "list",
+		"--id", strconv.Itoa(prs[0].CodeReviewId),
+		"--output", "json",
+		"--query", "[].{status:status}")
+	if err != nil {
+		return nil, err
+	}
+
+	var policies []azurePolicy
+	if err := json.Unmarshal(resp, &policies); err != nil {
+		log.Error("failed to unmarshal the PR policies", "error", err)
+		return nil, err
+	}
+	return prs[0].toPrStatus(policies), nil
+}
+
+func (pr azurePrStatus) toPrStatus(policies []azurePolicy) *PrStatus {
+	status := noPrStatus()
+	status.Url = pr.url()
+
+	switch {
+	case pr.Status == "completed":
+		status.State = PrStateMerged
+	case pr.Status == "abandoned":
+		status.State = PrStateAbandoned
+	case pr.IsDraft:
+		status.State = PrStateDraft
+	default:
+		status.State = PrStateOpen
+	}
+
+	// Votes: 10 approved, 5 approved with suggestions, -5 waiting for author, -10 rejected
+	status.Review = ReviewRequired
+	approved := false
+	for _, vote := range pr.Votes {
+		if vote < 0 {
+			status.Review = ReviewChangesRequested
+			break
+		}
+		if vote > 0 {
+			approved = true
+		}
+	}
+	if approved && status.Review != ReviewChangesRequested {
+		status.Review = ReviewApproved
+	}
+
+	switch pr.MergeStatus {
+	case "succeeded":
+		status.Mergeable = MergeableYes
+	case "conflicts":
+		status.Mergeable = MergeableConflicting
+	}
+
+	for _, policy := range policies {
+		switch {
+		case policy.Status == "rejected" || policy.Status == "broken":
+			status.Checks = ChecksFailure
+		case status.Checks == ChecksFailure:
+		case policy.Status == "running" || policy.Status == "queued":
+			status.Checks = ChecksPending
+		case status.Checks == ChecksNone && policy.Status == "approved":
+			status.Checks = ChecksSuccess
+		}
+	}
+	return status
+}
+
+func (Azure) AddReviewers(ctx context.Context, settings *Settings, sourceBranch string, reviewers []string) error {
+	// The CLI cannot mark existing reviewers as required, see CreatePrWithRequiredReviewers
+	if settings.RequiredReviewers {
+		return &UnsupportedFeatureError{Feature: "required reviewers"}
+	}
+	activePr, err := azureFindActivePr(ctx, sourceBranch)
 	if err != nil {
 		return err
 	}
+	if activePr == nil {
+		return fmt.Errorf("no active PR for branch '%s'", sourceBranch)
+	}
 
-	var activePrsOnSourceBranch []AzurePr
-	if err := json.Unmarshal(resp, &activePrsOnSourceBranch); err != nil {
-		log := LoggerFromContext(ctx)
-		log.Error("failed to unmarshal the PR id", "error", err)
+	reviewerFlags := []string{"repos", "pr", "reviewer", "add", "--id", strconv.Itoa(activePr.CodeReviewId), "--reviewers"}
+	_, err = runCmd(ctx, "az", append(reviewerFlags, reviewers...)...)
+	if err != nil {
 		return err
 	}
+	return nil
+}
 
-	if len(activePrsOnSourceBranch) < 1 {
-		return nil
+// `az repos pr` has no labels command, they are added one by one through `az devops invoke` as with AzureApi
+func (Azure) AddLabels(ctx context.Context, _ *Settings, sourceBranch string, labels []string) error {
+	log := LoggerFromContext(ctx)
+
+	activePr, err := azureFindActivePr(ctx, sourceBranch)
+	if err != nil {
+		return err
+	}
+	if activePr == nil {
+		return fmt.Errorf("no active PR for branch '%s'", sourceBranch)
+	}
+
+	for _, label := range labels {
+		reqBody, err := json.Marshal(map[string]string{"name": label})
+		if err != nil {
+			log.Error("failed to marshal the label", "label", label, "error", err)
+			return err
+		}
+		err = azureInvoke(ctx, reqBody,
+			"--area", "git",
+			"--resource", "pullRequestLabels",
+			"--route-parameters",
+			"project="+activePr.Project,
+			"repositoryId="+activePr.RepositoryId,
+			"pullRequestId="+strconv.Itoa(activePr.CodeReviewId),
+			"--http-method", "POST",
+			"--api-version", "7.0")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (Azure) LinkWorkItems(ctx context.Context, _ *Settings, sourceBranch string, workItems []int) error {
+	activePr, err := azureFindActivePr(ctx, sourceBranch)
+	if err != nil {
+		return err
+	}
+	if activePr == nil {
+		return fmt.Errorf("no active PR for branch '%s'", sourceBranch)
 	}
 
-	_, err = runCmd(ctx, "az", "repos", "pr", "update", "--id", strconv.Itoa(activePrsOnSourceBranch[0].CodeReviewId), "--status", "abandoned")
+	workItemFlags := []string{"repos", "pr", "work-item", "add", "--id", strconv.Itoa(activePr.CodeReviewId), "--work-items"}
+	for _, workItem := range workItems {
+		workItemFlags = append(workItemFlags, strconv.Itoa(workItem))
+	}
+	_, err = runCmd(ctx, "az", workItemFlags...)
 	if err != nil {
 		return err
 	}
+	return nil
+}
 
+// The CLI can only squash or merge the PRs
+func (Azure) EnableAutoMerge(ctx context.Context, _ *Settings, sourceBranch string, autoMerge *AutoMerge) error {
+	if autoMerge.Method == MergeMethodRebase {
+		return &UnsupportedFeatureError{Feature: "rebase auto-merge"}
+	}
+	activePr, err := azureFindActivePr(ctx, sourceBranch)
+	if err != nil {
+		return err
+	}
+	if activePr == nil {
+		return fmt.Errorf("no active PR for branch '%s'", sourceBranch)
+	}
+
+	_, err = runCmd(ctx, "az", "repos", "pr", "update",
+		"--id", strconv.Itoa(activePr.CodeReviewId),
+		"--auto-complete", "true",
+		"--squash", strconv.FormatBool(autoMerge.Method == MergeMethodSquash),
+		"--delete-source-branch", strconv.FormatBool(autoMerge.DeleteBranch))
+	if err != nil {
+		return err
+	}
 	return nil
 }
+
+// `az devops invoke` only reads the request body from a file
+func azureInvoke(ctx context.Context, reqBody []byte, args ...string) error {
+	log := LoggerFromContext(ctx)
+
+	inFile, err := os.CreateTemp("", "bit-azure-*.json")
+	if err != nil {
+		log.Error("failed to create the request file", "error", err)
+		return err
+	}
+	defer os.Remove(inFile.Name())
+
+	_, err = inFile.Write(reqBody)
+	if closeErr := inFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Error("failed to write the request file", "path", inFile.Name(), "error", err)
+		return err
+	}
+
+	invokeFlags := append([]string{"devops", "invoke", "--in-file", inFile.Name(), "--output", "none"}, args...)
+	_, err = runCmd(ctx, "az", invokeFlags...)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func azureFindActivePr(ctx context.Context, sourceBranch string) (*AzurePr, error) {
+	resp, err := runCmd(ctx, "az", "repos", "pr", "list",
+		"--top", "1",
+		"--status", "active",
+		"--source-branch", sourceBranch,
+		"--output", "json",
+		"--query", "[].{baseUrl:repository.webUrl, codeReviewId:codeReviewId, repositoryId:repository.id, project:repository.project.name}")
+	if err != nil {
+		return nil, err
+	}
+
+	var activePrsOnSourceBranch []AzurePr
+	if err := json.Unmarshal(resp, &activePrsOnSourceBranch); err != nil {
+		log := LoggerFromContext(ctx)
+		log.Error("failed to unmarshal the PR id", "error", err)
+		return nil, err
+	}
+
+	if len(activePrsOnSourceBranch) < 1 {
+		return nil, nil
+	}
+	return &activePrsOnSourceBranch[0], nil
+}
+
+func (pr AzurePr) url() string {
+	return pr.BaseUrl + "/pullrequest/" + strconv.Itoa(pr.CodeReviewId)
+}
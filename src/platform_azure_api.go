@@ -0,0 +1,390 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const azureApiBaseUrl = "https://dev.azure.com"
+
+const azureApiVersion = "7.1"
+
+// Identities of dev.azure.com organizations are served by a dedicated host
+const azureIdentitiesBaseUrl = "https://vssps.dev.azure.com"
+
+type azureApiPr struct {
+	PullRequestId int    `json:"pullRequestId"`
+	CodeReviewId  int    `json:"codeReviewId"`
+	Status        string `json:"status"`
+	IsDraft       bool   `json:"isDraft"`
+	MergeStatus   string `json:"mergeStatus"`
+	Repository    struct {
+		Id      string `json:"id"`
+		WebUrl  string `json:"webUrl"`
+		Project struct {
+			Id string `json:"id"`
+		} `json:"project"`
+	} `json:"repository"`
+	Reviewers []struct {
+		Vote int `json:"vote"`
+	} `json:"reviewers"`
+	CreatedBy azureIdentity `json:"createdBy"`
+}
+
+var azureMergeStrategies = map[MergeMethod]string{
+	MergeMethodMerge:  "noFastForward",
+	MergeMethodSquash: "squash",
+	MergeMethodRebase: "rebase",
+}
+
+type azureApiList[T any] struct {
+	Value []T `json:"value"`
+}
+
+type azureIdentity struct {
+	Id string `json:"id"`
+}
+
+type azureRepo struct {
+	settings   *AzureSettings
+	client     *restClient
+	identities *restClient
+}
+
+type AzureApi struct {
+	unsupportedFeatures
+}
+
+func (AzureApi) CreatePr(ctx context.Context, settings *Settings, head, title, description string) (string, error) {
+	repo, err := newAzureRepo(ctx, settings)
+	if err != nil {
+		return "", err
+	}
+
+	reqBody := map[string]any{
+		"sourceRefName": "refs/heads/" + head,
+		"targetRefName": "refs/heads/" + settings.MainBranch,
+		"title":         title,
+		"description":   description,
+		"isDraft":       settings.IsDraftPrs,
+	}
+	var pr azureApiPr
+	err = repo.client.do(ctx, http.MethodPost, repo.path("/pullrequests", nil), reqBody, &pr)
+	if err != nil {
+		return "", err
+	}
+	return pr.toAzurePr().url(), nil
+}
+
+func (AzureApi) ClosePr(ctx context.Context, settings *Settings, sourceBranch string) error {
+	repo, err := newAzureRepo(ctx, settings)
+	if err != nil {
+		return err
+	}
+
+	activePr, err := repo.findPr(ctx, sourceBranch, "active")
+	if err != nil || activePr == nil {
+		return err
+	}
+	return repo.updatePr(ctx, activePr, map[string]string{"status": "abandoned"})
+}
+
+// Returns an empty url when the branch has no active PR
+func (AzureApi) FindPr(ctx context.Context, settings *Settings, sourceBranch string) (string, error) {
+	repo, err := newAzureRepo(ctx, settings)
+	if err != nil {
+		return "", err
+	}
+
+	activePr, err := repo.findPr(ctx, sourceBranch, "active")
+	if err != nil || activePr == nil {
+		return "", err
+	}
+	return activePr.toAzurePr().url(), nil
+}
+
+func (AzureApi) UpdatePr(ctx context.Context, settings *Settings, sourceBranch, title, description string) error {
+	repo, err := newAzureRepo(ctx, settings)
+	if err != nil {
+		return err
+	}
+
+	activePr, err := repo.findActivePr(ctx, sourceBranch)
+	if err != nil {
+		return err
+	}
+	return repo.updatePr(ctx, activePr, map[string]string{
+		"title":       title,
+		"description": description,
+	})
+}
+
+// Reviewers are the unique names of users (usually their email) or of groups
+// like `[project]\team`, they are marked as required when `requiredReviewers` is set
+func (AzureApi) AddReviewers(ctx context.Context, settings *Settings, sourceBranch string, reviewers []string) error {
+	repo, err := newAzureRepo(ctx, settings)
+	if err != nil {
+		return err
+	}
+
+	activePr, err := repo.findActivePr(ctx, sourceBranch)
+	if err != nil {
+		return err
+	}
+
+	for _, reviewer := range reviewers {
+		identity, err := repo.findIdentity(ctx, reviewer)
+		if err != nil {
+			return err
+		}
+		reqBody := map[string]any{
+			"vote":       0,
+			"isRequired": settings.RequiredReviewers,
+		}
+		subPath := fmt.Sprintf("/pullrequests/%d/reviewers/%s", activePr.PullRequestId, url.PathEscape(identity.Id))
+		err = repo.client.do(ctx, http.MethodPut, repo.path(subPath, nil), reqBody, nil)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (AzureApi) AddLabels(ctx context.Context, settings *Settings, sourceBranch string, labels []string) error {
+	repo, err := newAzureRepo(ctx, settings)
+	if err != nil {
+		return err
+	}
+
+	activePr, err := repo.findActivePr(ctx, sourceBranch)
+	if err != nil {
+		return err
+	}
+	for _, label := range labels {
+		reqBody := map[string]string{"name": label}
+		err = repo.client.do(ctx, http.MethodPost, repo.path(fmt.Sprintf("/pullrequests/%d/labels", activePr.PullRequestId), nil), reqBody, nil)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The PR is added as an artifact link to the relations of each work item
+func (AzureApi) LinkWorkItems(ctx context.Context, settings *Settings, sourceBranch string, workItems []int) error {
+	repo, err := newAzureRepo(ctx, settings)
+	if err != nil {
+		return err
+	}
+
+	activePr, err := repo.findActivePr(ctx, sourceBranch)
+	if err != nil {
+		return err
+	}
+	prArtifact := fmt.Sprintf("vstfs:///Git/PullRequestId/%s", url.PathEscape(
+		fmt.Sprintf("%s/%s/%d", activePr.Repository.Project.Id, activePr.Repository.Id, activePr.PullRequestId)))
+
+	// Work items are only updated with JSON patch documents
+	patchClient := *repo.client
+	patchClient.headers = map[string]string{"Content-Type": "application/json-patch+json"}
+	for _, workItem := range workItems {
+		reqBody := []map[string]any{
+			{
+				"op":   "add",
+				"path": "/relations/-",
+				"value": map[string]any{
+					"rel":        "ArtifactLink",
+					"url":        prArtifact,
+					"attributes": map[string]string{"name": "Pull Request"},
+				},
+			},
+		}
+		path := fmt.Sprintf("%s/_apis/wit/workitems/%d?api-version=%s", repo.projectPath(), workItem, azureApiVersion)
+		err = patchClient.do(ctx, http.MethodPatch, path, reqBody, nil)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Auto-complete is set on behalf of the PR creator, that is the owner of the token
+func (AzureApi) EnableAutoMerge(ctx context.Context, settings *Settings, sourceBranch string, autoMerge *AutoMerge) error {
+	repo, err := newAzureRepo(ctx, settings)
+	if err != nil {
+		return err
+	}
+
+	activePr, err := repo.findActivePr(ctx, sourceBranch)
+	if err != nil {
+		return err
+	}
+	return repo.updatePr(ctx, activePr, map[string]any{
+		"autoCompleteSetBy": activePr.CreatedBy,
+		"completionOptions": map[string]any{
+			"mergeStrategy":      azureMergeStrategies[autoMerge.Method],
+			"deleteSourceBranch": autoMerge.DeleteBranch,
+		},
+	})
+}
+
+func (AzureApi) PrStatus(ctx context.Context, settings *Settings, sourceBranch string) (*PrStatus, error) {
+	repo, err := newAzureRepo(ctx, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	pr, err := repo.findPr(ctx, sourceBranch, "all")
+	if err != nil {
+		return nil, err
+	}
+	if pr == nil {
+		return noPrStatus(), nil
+	}
+
+	query := url.Values{}
+	query.Set("artifactId", fmt.Sprintf("vstfs:///CodeReview/CodeReviewId/%s/%d", pr.Repository.Project.Id, pr.CodeReviewId))
+	query.Set("api-version", azureApiVersion+"-preview.1")
+	var policies azureApiList[azurePolicy]
+	err = repo.client.do(ctx, http.MethodGet, repo.projectPath()+"/_apis/policy/evaluations?"+query.Encode(), nil, &policies)
+	if err != nil {
+		return nil, err
+	}
+
+	return pr.toAzurePrStatus().toPrStatus(policies.Value), nil
+}
+
+// Same output as the queries of the CLI based functions, so the same mapping is used
+func (pr azureApiPr) toAzurePr() AzurePr {
+	return AzurePr{
+		BaseUrl:      pr.Repository.WebUrl,
+		CodeReviewId: pr.CodeReviewId,
+	}
+}
+
+func (pr azureApiPr) toAzurePrStatus() azurePrStatus {
+	votes := []int{}
+	for _, reviewer := range pr.Reviewers {
+		votes = append(votes, reviewer.Vote)
+	}
+	return azurePrStatus{
+		AzurePr:     pr.toAzurePr(),
+		Status:      pr.Status,
+		IsDraft:     pr.IsDraft,
+		MergeStatus: pr.MergeStatus,
+		Votes:       votes,
+	}
+}
+
+// The personal access token is taken from AZURE_DEVOPS_EXT_PAT, as for the az CLI
+func newAzureRepo(ctx context.Context, settings *Settings) (*azureRepo, error) {
+	log := LoggerFromContext(ctx)
+
+	azureSettings := settings.Azure
+	if azureSettings == nil {
+		log.Error("missing or empty config field", "field", "BigChange.Settings.Azure")
+		return nil, fmt.Errorf("missing or empty config field")
+	}
+	if azureSettings.Organization == "" {
+		log.Error("missing or empty config field", "field", "BigChange.Settings.Azure.Organization")
+		return nil, fmt.Errorf("missing or empty config field")
+	}
+	if azureSettings.Project == "" {
+		log.Error("missing or empty config field", "field", "BigChange.Settings.Azure.Project")
+		return nil, fmt.Errorf("missing or empty config field")
+	}
+	if azureSettings.Repository == "" {
+		log.Error("missing or empty config field", "field", "BigChange.Settings.Azure.Repository")
+		return nil, fmt.Errorf("missing or empty config field")
+	}
+
+	token := os.Getenv("AZURE_DEVOPS_EXT_PAT")
+	if token == "" {
+		log.Error("missing Azure DevOps credentials, set AZURE_DEVOPS_EXT_PAT")
+		return nil, fmt.Errorf("missing Azure DevOps credentials")
+	}
+
+	baseUrl := strings.TrimSuffix(azureSettings.BaseUrl, "/")
+	identitiesBaseUrl := baseUrl
+	if baseUrl == "" {
+		baseUrl = azureApiBaseUrl
+		identitiesBaseUrl = azureIdentitiesBaseUrl
+	}
+	organization := "/" + url.PathEscape(azureSettings.Organization)
+	return &azureRepo{
+		settings:   azureSettings,
+		client:     newRestClient(baseUrl+organization, basicAuth("", token)),
+		identities: newRestClient(identitiesBaseUrl+organization, basicAuth("", token)),
+	}, nil
+}
+
+func (repo *azureRepo) projectPath() string {
+	return "/" + url.PathEscape(repo.settings.Project)
+}
+
+func (repo *azureRepo) path(subPath string, query url.Values) string {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("api-version", azureApiVersion)
+	return fmt.Sprintf("%s/_apis/git/repositories/%s%s?%s",
+		repo.projectPath(), url.PathEscape(repo.settings.Repository), subPath, query.Encode())
+}
+
+// `status` is one of active, abandoned, completed or all
+func (repo *azureRepo) findPr(ctx context.Context, sourceBranch string, status string) (*azureApiPr, error) {
+	query := url.Values{}
+	query.Set("searchCriteria.sourceRefName", "refs/heads/"+sourceBranch)
+	query.Set("searchCriteria.status", status)
+	query.Set("$top", "1")
+
+	var prs azureApiList[azureApiPr]
+	err := repo.client.do(ctx, http.MethodGet, repo.path("/pullrequests", query), nil, &prs)
+	if err != nil {
+		return nil, err
+	}
+	if len(prs.Value) < 1 {
+		return nil, nil
+	}
+	return &prs.Value[0], nil
+}
+
+// Same as findPr but a missing active PR is an error
+func (repo *azureRepo) findActivePr(ctx context.Context, sourceBranch string) (*azureApiPr, error) {
+	activePr, err := repo.findPr(ctx, sourceBranch, "active")
+	if err != nil {
+		return nil, err
+	}
+	if activePr == nil {
+		return nil, fmt.Errorf("no active PR for branch '%s'", sourceBranch)
+	}
+	return activePr, nil
+}
+
+func (repo *azureRepo) updatePr(ctx context.Context, pr *azureApiPr, changes any) error {
+	return repo.client.do(ctx, http.MethodPatch, repo.path(fmt.Sprintf("/pullrequests/%d", pr.PullRequestId), nil), changes, nil)
+}
+
+func (repo *azureRepo) findIdentity(ctx context.Context, name string) (*azureIdentity, error) {
+	query := url.Values{}
+	query.Set("searchFilter", "General")
+	query.Set("filterValue", name)
+	query.Set("queryMembership", "None")
+	query.Set("api-version", azureApiVersion)
+
+	var identities azureApiList[azureIdentity]
+	err := repo.identities.do(ctx, http.MethodGet, "/_apis/identities?"+query.Encode(), nil, &identities)
+	if err != nil {
+		return nil, err
+	}
+	if len(identities.Value) < 1 {
+		log := LoggerFromContext(ctx)
+		log.Error("unknown Azure DevOps identity", "name", name)
+		return nil, fmt.Errorf("unknown Azure DevOps identity '%s'", name)
+	}
+	return &identities.Value[0], nil
+}
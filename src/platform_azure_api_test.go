@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const azureApiPrsPath = "/org/proj/_apis/git/repositories/repo/pullrequests"
+
+const azureApiActivePrQuery = azureApiPrsPath +
+	"?%24top=1&api-version=7.1&searchCriteria.sourceRefName=refs%2Fheads%2Fbit-dom1&searchCriteria.status=active"
+
+const azureApiBasicAuth = "Basic OnRva2Vu"
+
+var azureApiTests = []struct {
+	description      string
+	responses        map[string]string
+	call             func(ctx context.Context, settings *Settings) (string, error)
+	expectedResult   string
+	expectedRequests []apiRequest
+	expectedErr      error
+}{
+	{
+		description: "Create PR",
+		responses: map[string]string{
+			"POST " + azureApiPrsPath: `{"pullRequestId": 7, "codeReviewId": 7,
+				"repository": {"webUrl": "https://dev.azure.com/org/proj/_git/repo"}}`,
+		},
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			return AzureApi{}.CreatePr(ctx, settings, "bit-dom1", "title", "body")
+		},
+		expectedResult: "https://dev.azure.com/org/proj/_git/repo/pullrequest/7",
+		expectedRequests: []apiRequest{
+			{
+				Method: "POST",
+				Url:    azureApiPrsPath + "?api-version=7.1",
+				Auth:   azureApiBasicAuth,
+				Body:   `{"description":"body","isDraft":false,"sourceRefName":"refs/heads/bit-dom1","targetRefName":"refs/heads/main","title":"title"}`,
+			},
+		},
+	},
+	{
+		description: "Find active PR by source branch",
+		responses: map[string]string{
+			"GET " + azureApiPrsPath: `{"value": [{"pullRequestId": 7, "codeReviewId": 7,
+				"repository": {"webUrl": "https://dev.azure.com/org/proj/_git/repo"}}]}`,
+		},
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			return AzureApi{}.FindPr(ctx, settings, "bit-dom1")
+		},
+		expectedResult: "https://dev.azure.com/org/proj/_git/repo/pullrequest/7",
+		expectedRequests: []apiRequest{
+			{Method: "GET", Url: azureApiActivePrQuery, Auth: azureApiBasicAuth},
+		},
+	},
+	{
+		description: "Abandon PR",
+		responses: map[string]string{
+			"GET " + azureApiPrsPath:          `{"value": [{"pullRequestId": 7, "codeReviewId": 7}]}`,
+			"PATCH " + azureApiPrsPath + "/7": `{"pullRequestId": 7}`,
+		},
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			return "", AzureApi{}.ClosePr(ctx, settings, "bit-dom1")
+		},
+		expectedRequests: []apiRequest{
+			{Method: "GET", Url: azureApiActivePrQuery, Auth: azureApiBasicAuth},
+			{Method: "PATCH", Url: azureApiPrsPath + "/7?api-version=7.1", Auth: azureApiBasicAuth, Body: `{"status":"abandoned"}`},
+		},
+	},
+	{
+		description: "No PR to abandon",
+		responses: map[string]string{
+			"GET " + azureApiPrsPath: `{"value": []}`,
+		},
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			return "", AzureApi{}.ClosePr(ctx, settings, "bit-dom1")
+		},
+		expectedRequests: []apiRequest{
+			{Method: "GET", Url: azureApiActivePrQuery, Auth: azureApiBasicAuth},
+		},
+	},
+	{
+		description: "Add labels",
+		responses: map[string]string{
+			"GET " + azureApiPrsPath:                `{"value": [{"pullRequestId": 7, "codeReviewId": 7}]}`,
+			"POST " + azureApiPrsPath + "/7/labels": `{"name": "label"}`,
+		},
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			return "", AzureApi{}.AddLabels(ctx, settings, "bit-dom1", []string{"big-change", "dom1"})
+		},
+		expectedRequests: []apiRequest{
+			{Method: "GET", Url: azureApiActivePrQuery, Auth: azureApiBasicAuth},
+			{Method: "POST", Url: azureApiPrsPath + "/7/labels?api-version=7.1", Auth: azureApiBasicAuth, Body: `{"name":"big-change"}`},
+			{Method: "POST", Url: azureApiPrsPath + "/7/labels?api-version=7.1", Auth: azureApiBasicAuth, Body: `{"name":"dom1"}`},
+		},
+	},
+	{
+		description: "Link work items",
+		responses: map[string]string{
+			"GET " + azureApiPrsPath: `{"value": [{"pullRequestId": 7, "codeReviewId": 7,
+				"repository": {"id": "r-id", "project": {"id": "p-id"}}}]}`,
+			"PATCH /org/proj/_apis/wit/workitems/42": `{"id": 42}`,
+		},
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			return "", AzureApi{}.LinkWorkItems(ctx, settings, "bit-dom1", []int{42})
+		},
+		expectedRequests: []apiRequest{
+			{Method: "GET", Url: azureApiActivePrQuery, Auth: azureApiBasicAuth},
+			{
+				Method: "PATCH",
+				Url:    "/org/proj/_apis/wit/workitems/42?api-version=7.1",
+				Auth:   azureApiBasicAuth,
+				Body: `[{"op":"add","path":"/relations/-","value":{"attributes":{"name":"Pull Request"},` +
+					`"rel":"ArtifactLink","url":"vstfs:///Git/PullRequestId/p-id%2Fr-id%2F7"}}]`,
+			},
+		},
+	},
+	{
+		description: "Fail on missing active PR",
+		responses: map[string]string{
+			"GET " + azureApiPrsPath: `{"value": []}`,
+		},
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			return "", AzureApi{}.LinkWorkItems(ctx, settings, "bit-dom1", []int{42})
+		},
+		expectedRequests: []apiRequest{
+			{Method: "GET", Url: azureApiActivePrQuery, Auth: azureApiBasicAuth},
+		},
+		expectedErr: fmt.Errorf("no active PR for branch 'bit-dom1'"),
+	},
+	{
+		description: "Enable auto-complete",
+		responses: map[string]string{
+			"GET " + azureApiPrsPath:          `{"value": [{"pullRequestId": 7, "codeReviewId": 7, "createdBy": {"id": "me-id"}}]}`,
+			"PATCH " + azureApiPrsPath + "/7": `{"pullRequestId": 7}`,
+		},
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			return "", AzureApi{}.EnableAutoMerge(ctx, settings, "bit-dom1", &AutoMerge{Method: MergeMethodSquash, DeleteBranch: true})
+		},
+		expectedRequests: []apiRequest{
+			{Method: "GET", Url: azureApiActivePrQuery, Auth: azureApiBasicAuth},
+			{
+				Method: "PATCH",
+				Url:    azureApiPrsPath + "/7?api-version=7.1",
+				Auth:   azureApiBasicAuth,
+				Body:   `{"autoCompleteSetBy":{"id":"me-id"},"completionOptions":{"deleteSourceBranch":true,"mergeStrategy":"squash"}}`,
+			},
+		},
+	},
+	{
+		description: "Add required reviewers",
+		responses: map[string]string{
+			"GET " + azureApiPrsPath:                           `{"value": [{"pullRequestId": 7, "codeReviewId": 7}]}`,
+			"GET /org/_apis/identities":                        `{"count": 1, "value": [{"id": "alice-id"}]}`,
+			"PUT " + azureApiPrsPath + "/7/reviewers/alice-id": `{"id": "alice-id"}`,
+		},
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			settings.RequiredReviewers = true
+			return "", AzureApi{}.AddReviewers(ctx, settings, "bit-dom1", []string{"alice@example.com"})
+		},
+		expectedRequests: []apiRequest{
+			{Method: "GET", Url: azureApiActivePrQuery, Auth: azureApiBasicAuth},
+			{
+				Method: "GET",
+				Url:    "/org/_apis/identities?api-version=7.1&filterValue=alice%40example.com&queryMembership=None&searchFilter=General",
+				Auth:   azureApiBasicAuth,
+			},
+			{
+				Method: "PUT",
+				Url:    azureApiPrsPath + "/7/reviewers/alice-id?api-version=7.1",
+				Auth:   azureApiBasicAuth,
+				Body:   `{"isRequired":true,"vote":0}`,
+			},
+		},
+	},
+	{
+		description: "Fail on unknown reviewer",
+		responses: map[string]string{
+			"GET " + azureApiPrsPath:    `{"value": [{"pullRequestId": 7, "codeReviewId": 7}]}`,
+			"GET /org/_apis/identities": `{"count": 0, "value": []}`,
+		},
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			return "", AzureApi{}.AddReviewers(ctx, settings, "bit-dom1", []string{"nobody"})
+		},
+		expectedRequests: []apiRequest{
+			{Method: "GET", Url: azureApiActivePrQuery, Auth: azureApiBasicAuth},
+			{
+				Method: "GET",
+				Url:    "/org/_apis/identities?api-version=7.1&filterValue=nobody&queryMembership=None&searchFilter=General",
+				Auth:   azureApiBasicAuth,
+			},
+		},
+		expectedErr: fmt.Errorf("unknown Azure DevOps identity 'nobody'"),
+	},
+	{
+		description: "PR status with policies",
+		responses: map[string]string{
+			"GET " + azureApiPrsPath: `{"value": [{"pullRequestId": 7, "codeReviewId": 7, "status": "active",
+				"isDraft": true, "mergeStatus": "conflicts", "reviewers": [{"vote": 10}],
+				"repository": {"webUrl": "https://dev.azure.com/org/proj/_git/repo", "project": {"id": "p-id"}}}]}`,
+			"GET /org/proj/_apis/policy/evaluations": `{"value": [{"status": "approved"}, {"status": "running"}]}`,
+		},
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			status, err := AzureApi{}.PrStatus(ctx, settings, "bit-dom1")
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("%s %s %s %s %s", status.Url, status.State, status.Review, status.Checks, status.Mergeable), nil
+		},
+		expectedResult: "https://dev.azure.com/org/proj/_git/repo/pullrequest/7 draft approved pending conflicting",
+		expectedRequests: []apiRequest{
+			{
+				Method: "GET",
+				Url:    azureApiPrsPath + "?%24top=1&api-version=7.1&searchCriteria.sourceRefName=refs%2Fheads%2Fbit-dom1&searchCriteria.status=all",
+				Auth:   azureApiBasicAuth,
+			},
+			{
+				Method: "GET",
+				Url:    "/org/proj/_apis/policy/evaluations?api-version=7.1-preview.1&artifactId=vstfs%3A%2F%2F%2FCodeReview%2FCodeReviewId%2Fp-id%2F7",
+				Auth:   azureApiBasicAuth,
+			},
+		},
+	},
+	{
+		description: "Fail on update without active PR",
+		responses: map[string]string{
+			"GET " + azureApiPrsPath: `{"value": []}`,
+		},
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			return "", AzureApi{}.UpdatePr(ctx, settings, "bit-dom1", "title", "body")
+		},
+		expectedRequests: []apiRequest{
+			{Method: "GET", Url: azureApiActivePrQuery, Auth: azureApiBasicAuth},
+		},
+		expectedErr: fmt.Errorf("no active PR for branch 'bit-dom1'"),
+	},
+}
+
+func TestAzureApi(t *testing.T) {
+	ctxWithSilentLogger := ContextWithSilentLogger(context.Background())
+	t.Setenv("AZURE_DEVOPS_EXT_PAT", "token")
+
+	for _, tt := range azureApiTests {
+		t.Run(tt.description, func(t *testing.T) {
+			server, gotRequests := fixtureApi(t, tt.responses)
+			settings := fixtureBigChange().Settings
+			settings.Azure = &AzureSettings{BaseUrl: server.URL, Organization: "org", Project: "proj", Repository: "repo"}
+
+			gotResult, gotErr := tt.call(ctxWithSilentLogger, settings)
+
+			// We get an error when we don't expect it or we don't get one when we expect it
+			if tt.expectedErr != nil != (gotErr != nil) {
+				t.Errorf("got '%v', want '%v'", gotErr, tt.expectedErr)
+			}
+			// We get a different error of what's expected
+			if tt.expectedErr != nil && gotErr != nil &&
+				tt.expectedErr.Error() != gotErr.Error() {
+				t.Errorf("got '%v', want '%v'", gotErr, tt.expectedErr)
+			}
+
+			diff := cmp.Diff(gotResult, tt.expectedResult)
+			if diff != "" {
+				t.Errorf("%v", diff)
+			}
+			diff = cmp.Diff(*gotRequests, tt.expectedRequests)
+			if diff != "" {
+				t.Errorf("%v", diff)
+			}
+		})
+	}
+}
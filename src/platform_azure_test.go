@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var azurePrStatusTests = []struct {
+	description    string
+	rawPr          string
+	rawPolicies    string
+	expectedStatus *PrStatus
+}{
+	{
+		description: "Active PR approved with passing policies",
+		rawPr: `{"baseUrl": "https://dev.azure.com/o/p/_git/r", "codeReviewId": 7, "status": "active",
+			"isDraft": false, "mergeStatus": "succeeded", "votes": [10, 0]}`,
+		rawPolicies: `[{"status": "approved"}, {"status": "notApplicable"}]`,
+		expectedStatus: &PrStatus{
+			Url:       "https://dev.azure.com/o/p/_git/r/pullrequest/7",
+			State:     PrStateOpen,
+			Review:    ReviewApproved,
+			Checks:    ChecksSuccess,
+			Mergeable: MergeableYes,
+		},
+	},
+	{
+		description: "Draft PR rejected with running policies",
+		rawPr: `{"baseUrl": "https://dev.azure.com/o/p/_git/r", "codeReviewId": 8, "status": "active",
+			"isDraft": true, "mergeStatus": "conflicts", "votes": [10, -10]}`,
+		rawPolicies: `[{"status": "approved"}, {"status": "running"}]`,
+		expectedStatus: &PrStatus{
+			Url:       "https://dev.azure.com/o/p/_git/r/pullrequest/8",
+			State:     PrStateDraft,
+			Review:    ReviewChangesRequested,
+			Checks:    ChecksPending,
+			Mergeable: MergeableConflicting,
+		},
+	},
+	{
+		description: "Abandoned PR without reviewers",
+		rawPr: `{"baseUrl": "https://dev.azure.com/o/p/_git/r", "codeReviewId": 9, "status": "abandoned",
+			"mergeStatus": "notSet", "votes": []}`,
+		rawPolicies: `[{"status": "rejected"}, {"status": "approved"}]`,
+		expectedStatus: &PrStatus{
+			Url:       "https://dev.azure.com/o/p/_git/r/pullrequest/9",
+			State:     PrStateAbandoned,
+			Review:    ReviewRequired,
+			Checks:    ChecksFailure,
+			Mergeable: MergeableUnknown,
+		},
+	},
+}
+
+func TestAzurePrStatus(t *testing.T) {
+	for _, tt := range azurePrStatusTests {
+		t.Run(tt.description, func(t *testing.T) {
+			var pr azurePrStatus
+			if err := json.Unmarshal([]byte(tt.rawPr), &pr); err != nil {
+				t.Fatal(err)
+			}
+			var policies []azurePolicy
+			if err := json.Unmarshal([]byte(tt.rawPolicies), &policies); err != nil {
+				t.Fatal(err)
+			}
+
+			diff := cmp.Diff(pr.toPrStatus(policies), tt.expectedStatus)
+			if diff != "" {
+				t.Errorf("%v", diff)
+			}
+		})
+	}
+}
+
+// Answers the PR lookups and records the arguments and request files next to itself
+const fixtureAzScript = `#!/bin/sh
+echo "$*" >> "$0.calls"
+case "$*" in
+  "repos pr list"*) echo '[{"baseUrl": "https://dev.azure.com/org/project/_git/repo", "codeReviewId": 7, "repositoryId": "repo-id", "project": "project"}]' ;;
+  "devops invoke --in-file "*) cat "$4" >> "$0.calls"; echo >> "$0.calls" ;;
+  *) exit 1 ;;
+esac
+`
+
+// Installs a fake `az` in a temporary folder of the PATH
+func fixtureAz(t *testing.T) string {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "az")
+	if err := os.WriteFile(path, []byte(fixtureAzScript), 0755); err != nil {
+		t.Fatalf("failed to write the fake az: %v", err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return path
+}
+
+func TestAzureAddLabels(t *testing.T) {
+	ctxWithSilentLogger := ContextWithSilentLogger(context.Background())
+	azPath := fixtureAz(t)
+
+	err := Azure{}.AddLabels(ctxWithSilentLogger, fixtureBigChange().Settings, "bit-dom1", []string{"big-change", "dom1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rawCalls, err := os.ReadFile(azPath + ".calls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gotCalls []string
+	for _, call := range strings.Split(strings.TrimSpace(string(rawCalls)), "\n") {
+		// The request file has a random name
+		if strings.HasPrefix(call, "devops invoke --in-file ") {
+			call = "devops invoke" + call[strings.Index(call, " --output"):]
+		}
+		gotCalls = append(gotCalls, call)
+	}
+
+	invoke := "devops invoke --output none --area git --resource pullRequestLabels --route-parameters project=project repositoryId=repo-id pullRequestId=7 --http-method POST --api-version 7.0"
+	diff := cmp.Diff(gotCalls, []string{
+		"repos pr list --top 1 --status active --source-branch bit-dom1 --output json --query [].{baseUrl:repository.webUrl, codeReviewId:codeReviewId, repositoryId:repository.id, project:repository.project.name}",
+		invoke,
+		`{"name":"big-change"}`,
+		invoke,
+		`{"name":"dom1"}`,
+	})
+	if diff != "" {
+		t.Errorf("%v", diff)
+	}
+}
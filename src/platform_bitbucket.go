@@ -0,0 +1,461 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+const bitbucketCloudBaseUrl = "https://api.bitbucket.org/2.0"
+
+// Common view of Bitbucket Cloud and Bitbucket Server pull requests
+type bitbucketPr struct {
+	Id      int
+	Version int
+	Url     string
+	// OPEN, MERGED, DECLINED or SUPERSEDED
+	State  string
+	Draft  bool
+	Commit string
+	// APPROVED, NEEDS_WORK or UNAPPROVED for each reviewer
+	Reviews    []string
+	Conflicted *bool
+}
+
+type bitbucketCloudPr struct {
+	Id    int    `json:"id"`
+	State string `json:"state"`
+	Draft bool   `json:"draft"`
+	Links struct {
+		Html struct {
+			Href string `json:"href"`
+		} `json:"html"`
+	} `json:"links"`
+	Source struct {
+		Commit struct {
+			Hash string `json:"hash"`
+		} `json:"commit"`
+	} `json:"source"`
+	Participants []struct {
+		Role     string `json:"role"`
+		Approved bool   `json:"approved"`
+		State    string `json:"state"`
+	} `json:"participants"`
+}
+
+type bitbucketServerPr struct {
+	Id      int    `json:"id"`
+	Version int    `json:"version"`
+	State   string `json:"state"`
+	Draft   bool   `json:"draft"`
+	FromRef struct {
+		LatestCommit string `json:"latestCommit"`
+	} `json:"fromRef"`
+	Reviewers []struct {
+		Status string `json:"status"`
+	} `json:"reviewers"`
+	Links struct {
+		Self []struct {
+			Href string `json:"href"`
+		} `json:"self"`
+	} `json:"links"`
+}
+
+type bitbucketPage[T any] struct {
+	Values []T `json:"values"`
+}
+
+type bitbucketBuildStatus struct {
+	State string `json:"state"`
+}
+
+type bitbucketRepo struct {
+	settings *BitbucketSettings
+	client   *restClient
+}
+
+type Bitbucket struct {
+	unsupportedFeatures
+}
+
+func (Bitbucket) CreatePr(ctx context.Context, settings *Settings, head, title, description string) (string, error) {
+	repo, err := newBitbucketRepo(ctx, settings)
+	if err != nil {
+		return "", err
+	}
+
+	pr, err := repo.createPr(ctx, settings.MainBranch, head, title, description, settings.IsDraftPrs)
+	if err != nil {
+		return "", err
+	}
+	return pr.Url, nil
+}
+
+// Declines the open PR of the branch, if any
+func (Bitbucket) ClosePr(ctx context.Context, settings *Settings, sourceBranch string) error {
+	repo, err := newBitbucketRepo(ctx, settings)
+	if err != nil {
+		return err
+	}
+
+	pr, err := repo.findPr(ctx, sourceBranch, false)
+	if err != nil || pr == nil {
+		return err
+	}
+	return repo.declinePr(ctx, pr)
+}
+
+// Returns an empty url when the branch has no open PR
+func (Bitbucket) FindPr(ctx context.Context, settings *Settings, sourceBranch string) (string, error) {
+	repo, err := newBitbucketRepo(ctx, settings)
+	if err != nil {
+		return "", err
+	}
+
+	pr, err := repo.findPr(ctx, sourceBranch, false)
+	if err != nil || pr == nil {
+		return "", err
+	}
+	return pr.Url, nil
+}
+
+func (Bitbucket) UpdatePr(ctx context.Context, settings *Settings, sourceBranch, title, description string) error {
+	repo, err := newBitbucketRepo(ctx, settings)
+	if err != nil {
+		return err
+	}
+
+	pr, err := repo.findPr(ctx, sourceBranch, false)
+	if err != nil {
+		return err
+	}
+	if pr == nil {
+		return fmt.Errorf("no open PR for branch '%s'", sourceBranch)
+	}
+	return repo.updatePr(ctx, pr, title, description)
+}
+
+func (Bitbucket) PrStatus(ctx context.Context, settings *Settings, sourceBranch string) (*PrStatus, error) {
+	repo, err := newBitbucketRepo(ctx, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	pr, err := repo.findPr(ctx, sourceBranch, true)
+	if err != nil {
+		return nil, err
+	}
+	if pr == nil {
+		return noPrStatus(), nil
+	}
+
+	buildStates, err := repo.buildStates(ctx, pr)
+	if err != nil {
+		return nil, err
+	}
+	if pr.State == "OPEN" {
+		err = repo.loadConflicted(ctx, pr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return pr.toPrStatus(buildStates), nil
+}
+
+func (pr *bitbucketPr) toPrStatus(buildStates []string) *PrStatus {
+	status := noPrStatus()
+	status.Url = pr.Url
+
+	switch {
+	case pr.State == "MERGED":
+		status.State = PrStateMerged
+	case pr.State == "DECLINED" || pr.State == "SUPERSEDED":
+		status.State = PrStateAbandoned
+	case pr.Draft:
+		status.State = PrStateDraft
+	default:
+		status.State = PrStateOpen
+	}
+
+	if len(pr.Reviews) > 0 {
+		status.Review = ReviewRequired
+	}
+	approved := false
+	for _, review := range pr.Reviews {
+		switch review {
+		case "NEEDS_WORK":
+			status.Review = ReviewChangesRequested
+		case "APPROVED":
+			approved = true
+		}
+	}
+	if approved && status.Review != ReviewChangesRequested {
+		status.Review = ReviewApproved
+	}
+
+	for _, state := range buildStates {
+		switch state {
+		case "FAILED", "STOPPED", "CANCELLED":
+			status.Checks = ChecksFailure
+		case "SUCCESSFUL":
+			if status.Checks == ChecksNone {
+				status.Checks = ChecksSuccess
+			}
+		default:
+			if status.Checks != ChecksFailure {
+				status.Checks = ChecksPending
+			}
+		}
+	}
+
+	if pr.Conflicted != nil {
+		if *pr.Conflicted {
+			status.Mergeable = MergeableConflicting
+		} else {
+			status.Mergeable = MergeableYes
+		}
+	}
+	return status
+}
+
+// Credentials are taken from BITBUCKET_TOKEN or from BITBUCKET_USERNAME and BITBUCKET_APP_PASSWORD
+func newBitbucketRepo(ctx context.Context, settings *Settings) (*bitbucketRepo, error) {
+	log := LoggerFromContext(ctx)
+
+	bbSettings := settings.Bitbucket
+	if bbSettings == nil {
+		log.Error("missing or empty config field", "field", "BigChange.Settings.Bitbucket")
+		return nil, fmt.Errorf("missing or empty config field")
+	}
+	if bbSettings.Repository == "" {
+		log.Error("missing or empty config field", "field", "BigChange.Settings.Bitbucket.Repository")
+		return nil, fmt.Errorf("missing or empty config field")
+	}
+
+	baseUrl := bbSettings.BaseUrl
+	if bbSettings.Server {
+		if baseUrl == "" {
+			log.Error("missing or empty config field", "field", "BigChange.Settings.Bitbucket.BaseUrl")
+			return nil, fmt.Errorf("missing or empty config field")
+		}
+		if bbSettings.Project == "" {
+			log.Error("missing or empty config field", "field", "BigChange.Settings.Bitbucket.Project")
+			return nil, fmt.Errorf("missing or empty config field")
+		}
+	} else {
+		if baseUrl == "" {
+			baseUrl = bitbucketCloudBaseUrl
+		}
+		if bbSettings.Workspace == "" {
+			log.Error("missing or empty config field", "field", "BigChange.Settings.Bitbucket.Workspace")
+			return nil, fmt.Errorf("missing or empty config field")
+		}
+	}
+
+	var authorize func(*http.Request)
+	if token := os.Getenv("BITBUCKET_TOKEN"); token != "" {
+		authorize = bearerAuth(token)
+	} else if username := os.Getenv("BITBUCKET_USERNAME"); username != "" {
+		authorize = basicAuth(username, os.Getenv("BITBUCKET_APP_PASSWORD"))
+	} else {
+		log.Error("missing Bitbucket credentials, set BITBUCKET_TOKEN or BITBUCKET_USERNAME and BITBUCKET_APP_PASSWORD")
+		return nil, fmt.Errorf("missing Bitbucket credentials")
+	}
+
+	return &bitbucketRepo{
+		settings: bbSettings,
+		client:   newRestClient(baseUrl, authorize),
+	}, nil
+}
+
+func (repo *bitbucketRepo) prsPath() string {
+	if repo.settings.Server {
+		return fmt.Sprintf("/rest/api/1.0/projects/%s/repos/%s/pull-requests",
+			url.PathEscape(repo.settings.Project), url.PathEscape(repo.settings.Repository))
+	}
+	return fmt.Sprintf("/repositories/%s/%s/pullrequests",
+		url.PathEscape(repo.settings.Workspace), url.PathEscape(repo.settings.Repository))
+}
+
+func (repo *bitbucketRepo) createPr(ctx context.Context, target, source, title, description string, draft bool) (*bitbucketPr, error) {
+	if repo.settings.Server {
+		body := map[string]any{
+			"title":       title,
+			"description": description,
+			"draft":       draft,
+			"fromRef":     map[string]string{"id": "refs/heads/" + source},
+			"toRef":       map[string]string{"id": "refs/heads/" + target},
+		}
+		var pr bitbucketServerPr
+		err := repo.client.do(ctx, http.MethodPost, repo.prsPath(), body, &pr)
+		if err != nil {
+			return nil, err
+		}
+		return pr.toBitbucketPr(), nil
+	}
+
+	body := map[string]any{
+		"title":       title,
+		"description": description,
+		"draft":       draft,
+		"source":      map[string]any{"branch": map[string]string{"name": source}},
+		"destination": map[string]any{"branch": map[string]string{"name": target}},
+	}
+	var pr bitbucketCloudPr
+	err := repo.client.do(ctx, http.MethodPost, repo.prsPath(), body, &pr)
+	if err != nil {
+		return nil, err
+	}
+	return pr.toBitbucketPr(), nil
+}
+
+// Only open PRs are returned unless `all` is set
+func (repo *bitbucketRepo) findPr(ctx context.Context, sourceBranch string, all bool) (*bitbucketPr, error) {
+	query := url.Values{}
+	if repo.settings.Server {
+		query.Set("at", "refs/heads/"+sourceBranch)
+		query.Set("direction", "OUTGOING")
+		query.Set("order", "NEWEST")
+		query.Set("limit", "1")
+		if all {
+			query.Set("state", "ALL")
+		} else {
+			query.Set("state", "OPEN")
+		}
+
+		var page bitbucketPage[bitbucketServerPr]
+		err := repo.client.do(ctx, http.MethodGet, repo.prsPath()+"?"+query.Encode(), nil, &page)
+		if err != nil || len(page.Values) < 1 {
+			return nil, err
+		}
+		return page.Values[0].toBitbucketPr(), nil
+	}
+
+	query.Set("q", fmt.Sprintf("source.branch.name=%q", sourceBranch))
+	query.Set("sort", "-created_on")
+	query.Set("pagelen", "1")
+	query.Add("state", "OPEN")
+	if all {
+		query.Add("state", "MERGED")
+		query.Add("state", "DECLINED")
+		query.Add("state", "SUPERSEDED")
+	}
+
+	var page bitbucketPage[bitbucketCloudPr]
+	err := repo.client.do(ctx, http.MethodGet, repo.prsPath()+"?"+query.Encode(), nil, &page)
+	if err != nil || len(page.Values) < 1 {
+		return nil, err
+	}
+	return page.Values[0].toBitbucketPr(), nil
+}
+
+func (repo *bitbucketRepo) updatePr(ctx context.Context, pr *bitbucketPr, title, description string) error {
+	body := map[string]any{
+		"title":       title,
+		"description": description,
+	}
+	if repo.settings.Server {
+		// Bitbucket Server rejects updates of outdated versions of the PR
+		body["version"] = pr.Version
+	}
+	return repo.client.do(ctx, http.MethodPut, fmt.Sprintf("%s/%d", repo.prsPath(), pr.Id), body, nil)
+}
+
+func (repo *bitbucketRepo) declinePr(ctx context.Context, pr *bitbucketPr) error {
+	path := fmt.Sprintf("%s/%d/decline", repo.prsPath(), pr.Id)
+	if repo.settings.Server {
+		path = fmt.Sprintf("%s?version=%d", path, pr.Version)
+	}
+	return repo.client.do(ctx, http.MethodPost, path, map[string]any{}, nil)
+}
+
+func (repo *bitbucketRepo) buildStates(ctx context.Context, pr *bitbucketPr) ([]string, error) {
+	var path string
+	if repo.settings.Server {
+		if pr.Commit == "" {
+			return nil, nil
+		}
+		path = fmt.Sprintf("/rest/build-status/1.0/commits/%s", url.PathEscape(pr.Commit))
+	} else {
+		path = fmt.Sprintf("%s/%d/statuses", repo.prsPath(), pr.Id)
+	}
+
+	var page bitbucketPage[bitbucketBuildStatus]
+	err := repo.client.do(ctx, http.MethodGet, path, nil, &page)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]string, 0, len(page.Values))
+	for _, buildStatus := range page.Values {
+		states = append(states, buildStatus.State)
+	}
+	return states, nil
+}
+
+// Bitbucket Cloud does not report conflicts through its API
+func (repo *bitbucketRepo) loadConflicted(ctx context.Context, pr *bitbucketPr) error {
+	if !repo.settings.Server {
+		return nil
+	}
+
+	var mergeCheck struct {
+		Conflicted bool `json:"conflicted"`
+	}
+	err := repo.client.do(ctx, http.MethodGet, fmt.Sprintf("%s/%d/merge", repo.prsPath(), pr.Id), nil, &mergeCheck)
+	if err != nil {
+		return err
+	}
+	pr.Conflicted = &mergeCheck.Conflicted
+	return nil
+}
+
+func (pr bitbucketCloudPr) toBitbucketPr() *bitbucketPr {
+	reviews := []string{}
+	for _, participant := range pr.Participants {
+		if participant.Role != "REVIEWER" && !participant.Approved && participant.State == "" {
+			continue
+		}
+		switch {
+		case participant.State == "changes_requested":
+			reviews = append(reviews, "NEEDS_WORK")
+		case participant.Approved:
+			reviews = append(reviews, "APPROVED")
+		default:
+			reviews = append(reviews, "UNAPPROVED")
+		}
+	}
+
+	return &bitbucketPr{
+		Id:      pr.Id,
+		Url:     pr.Links.Html.Href,
+		State:   pr.State,
+		Draft:   pr.Draft,
+		Commit:  pr.Source.Commit.Hash,
+		Reviews: reviews,
+	}
+}
+
+func (pr bitbucketServerPr) toBitbucketPr() *bitbucketPr {
+	reviews := []string{}
+	for _, reviewer := range pr.Reviewers {
+		reviews = append(reviews, reviewer.Status)
+	}
+
+	var prUrl string
+	if len(pr.Links.Self) > 0 {
+		prUrl = pr.Links.Self[0].Href
+	}
+
+	return &bitbucketPr{
+		Id:      pr.Id,
+		Version: pr.Version,
+		Url:     prUrl,
+		State:   pr.State,
+		Draft:   pr.Draft,
+		Commit:  pr.FromRef.LatestCommit,
+		Reviews: reviews,
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var bitbucketPrStatusTests = []struct {
+	description    string
+	server         bool
+	rawPr          string
+	buildStates    []string
+	conflicted     *bool
+	expectedStatus *PrStatus
+}{
+	{
+		description: "Cloud - open PR approved with a passing build",
+		rawPr: `{"id": 1, "state": "OPEN", "draft": false,
+			"links": {"html": {"href": "https://bitbucket.org/ws/repo/pull-requests/1"}},
+			"participants": [{"role": "REVIEWER", "approved": true, "state": "approved"}]}`,
+		buildStates: []string{"SUCCESSFUL"},
+		expectedStatus: &PrStatus{
+			Url:       "https://bitbucket.org/ws/repo/pull-requests/1",
+			State:     PrStateOpen,
+			Review:    ReviewApproved,
+			Checks:    ChecksSuccess,
+			Mergeable: MergeableUnknown,
+		},
+	},
+	{
+		description: "Cloud - draft PR with requested changes and a running build",
+		rawPr: `{"id": 2, "state": "OPEN", "draft": true,
+			"links": {"html": {"href": "https://bitbucket.org/ws/repo/pull-requests/2"}},
+			"participants": [
+				{"role": "REVIEWER", "approved": true, "state": "approved"},
+				{"role": "REVIEWER", "approved": false, "state": "changes_requested"},
+				{"role": "PARTICIPANT", "approved": false}
+			]}`,
+		buildStates: []string{"SUCCESSFUL", "INPROGRESS"},
+		expectedStatus: &PrStatus{
+			Url:       "https://bitbucket.org/ws/repo/pull-requests/2",
+			State:     PrStateDraft,
+			Review:    ReviewChangesRequested,
+			Checks:    ChecksPending,
+			Mergeable: MergeableUnknown,
+		},
+	},
+	{
+		description: "Server - open PR waiting for review with conflicts and a failed build",
+		server:      true,
+		rawPr: `{"id": 3, "version": 2, "state": "OPEN",
+			"links": {"self": [{"href": "https://bitbucket.example.com/projects/P/repos/repo/pull-requests/3"}]},
+			"reviewers": [{"status": "UNAPPROVED"}]}`,
+		buildStates: []string{"FAILED", "INPROGRESS"},
+		conflicted:  boolPtr(true),
+		expectedStatus: &PrStatus{
+			Url:       "https://bitbucket.example.com/projects/P/repos/repo/pull-requests/3",
+			State:     PrStateOpen,
+			Review:    ReviewRequired,
+			Checks:    ChecksFailure,
+			Mergeable: MergeableConflicting,
+		},
+	},
+	{
+		description: "Server - declined PR without reviewers",
+		server:      true,
+		rawPr: `{"id": 4, "version": 5, "state": "DECLINED",
+			"links": {"self": [{"href": "https://bitbucket.example.com/projects/P/repos/repo/pull-requests/4"}]}}`,
+		expectedStatus: &PrStatus{
+			Url:       "https://bitbucket.example.com/projects/P/repos/repo/pull-requests/4",
+			State:     PrStateAbandoned,
+			Review:    ReviewNone,
+			Checks:    ChecksNone,
+			Mergeable: MergeableUnknown,
+		},
+	},
+}
+
+func TestBitbucketPrStatus(t *testing.T) {
+	for _, tt := range bitbucketPrStatusTests {
+		t.Run(tt.description, func(t *testing.T) {
+			var pr *bitbucketPr
+			if tt.server {
+				var serverPr bitbucketServerPr
+				if err := json.Unmarshal([]byte(tt.rawPr), &serverPr); err != nil {
+					t.Fatalf("invalid test PR: %v", err)
+				}
+				pr = serverPr.toBitbucketPr()
+			} else {
+				var cloudPr bitbucketCloudPr
+				if err := json.Unmarshal([]byte(tt.rawPr), &cloudPr); err != nil {
+					t.Fatalf("invalid test PR: %v", err)
+				}
+				pr = cloudPr.toBitbucketPr()
+			}
+			pr.Conflicted = tt.conflicted
+
+			diff := cmp.Diff(pr.toPrStatus(tt.buildStates), tt.expectedStatus)
+			if diff != "" {
+				t.Errorf("%v", diff)
+			}
+		})
+	}
+}
+
+var bitbucketApiTests = []struct {
+	description      string
+	bitbucket        func(baseUrl string) *BitbucketSettings
+	responses        map[string]string
+	call             func(ctx context.Context, settings *Settings) (string, error)
+	expectedResult   string
+	expectedRequests []apiRequest
+	expectedErr      error
+}{
+	{
+		description: "Cloud - create PR",
+		bitbucket: func(baseUrl string) *BitbucketSettings {
+			return &BitbucketSettings{BaseUrl: baseUrl, Workspace: "ws", Repository: "repo"}
+		},
+		responses: map[string]string{
+			"POST /repositories/ws/repo/pullrequests": `{"id": 1, "links": {"html": {"href": "https://bitbucket.org/ws/repo/pull-requests/1"}}}`,
+		},
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			return Bitbucket{}.CreatePr(ctx, settings, "bit-dom1", "title", "body")
+		},
+		expectedResult: "https://bitbucket.org/ws/repo/pull-requests/1",
+		expectedRequests: []apiRequest{
+			{
+				Method: "POST",
+				Url:    "/repositories/ws/repo/pullrequests",
+				Auth:   "Bearer token",
+				Body:   `{"description":"body","destination":{"branch":{"name":"main"}},"draft":false,"source":{"branch":{"name":"bit-dom1"}},"title":"title"}`,
+			},
+		},
+	},
+	{
+		description: "Cloud - find PR",
+		bitbucket: func(baseUrl string) *BitbucketSettings {
+			return &BitbucketSettings{BaseUrl: baseUrl, Workspace: "ws", Repository: "repo"}
+		},
+		responses: map[string]string{
+			"GET /repositories/ws/repo/pullrequests": `{"values": [{"id": 1, "links": {"html": {"href": "https://bitbucket.org/ws/repo/pull-requests/1"}}}]}`,
+		},
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			return Bitbucket{}.FindPr(ctx, settings, "bit-dom1")
+		},
+		expectedResult: "https://bitbucket.org/ws/repo/pull-requests/1",
+		expectedRequests: []apiRequest{
+			{
+				Method: "GET",
+				Url:    "/repositories/ws/repo/pullrequests?pagelen=1&q=source.branch.name%3D%22bit-dom1%22&sort=-created_on&state=OPEN",
+				Auth:   "Bearer token",
+			},
+		},
+	},
+	{
+		description: "Server - decline PR",
+		bitbucket: func(baseUrl string) *BitbucketSettings {
+			return &BitbucketSettings{BaseUrl: baseUrl, Server: true, Project: "P", Repository: "repo"}
+		},
+		responses: map[string]string{
+			"GET /rest/api/1.0/projects/P/repos/repo/pull-requests":            `{"values": [{"id": 3, "version": 2, "state": "OPEN"}]}`,
+			"POST /rest/api/1.0/projects/P/repos/repo/pull-requests/3/decline": `{}`,
+		},
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			return "", Bitbucket{}.ClosePr(ctx, settings, "bit-dom1")
+		},
+		expectedRequests: []apiRequest{
+			{
+				Method: "GET",
+				Url:    "/rest/api/1.0/projects/P/repos/repo/pull-requests?at=refs%2Fheads%2Fbit-dom1&direction=OUTGOING&limit=1&order=NEWEST&state=OPEN",
+				Auth:   "Bearer token",
+			},
+			{
+				Method: "POST",
+				Url:    "/rest/api/1.0/projects/P/repos/repo/pull-requests/3/decline?version=2",
+				Auth:   "Bearer token",
+				Body:   `{}`,
+			},
+		},
+	},
+	{
+		description: "Server - no PR to decline",
+		bitbucket: func(baseUrl string) *BitbucketSettings {
+			return &BitbucketSettings{BaseUrl: baseUrl, Server: true, Project: "P", Repository: "repo"}
+		},
+		responses: map[string]string{
+			"GET /rest/api/1.0/projects/P/repos/repo/pull-requests": `{"values": []}`,
+		},
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			return "", Bitbucket{}.ClosePr(ctx, settings, "bit-dom1")
+		},
+		expectedRequests: []apiRequest{
+			{
+				Method: "GET",
+				Url:    "/rest/api/1.0/projects/P/repos/repo/pull-requests?at=refs%2Fheads%2Fbit-dom1&direction=OUTGOING&limit=1&order=NEWEST&state=OPEN",
+				Auth:   "Bearer token",
+			},
+		},
+	},
+	{
+		description: "Fail on API error",
+		bitbucket: func(baseUrl string) *BitbucketSettings {
+			return &BitbucketSettings{BaseUrl: baseUrl, Workspace: "ws", Repository: "repo"}
+		},
+		responses: map[string]string{},
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			return Bitbucket{}.FindPr(ctx, settings, "bit-dom1")
+		},
+		expectedRequests: []apiRequest{
+			{
+				Method: "GET",
+				Url:    "/repositories/ws/repo/pullrequests?pagelen=1&q=source.branch.name%3D%22bit-dom1%22&sort=-created_on&state=OPEN",
+				Auth:   "Bearer token",
+			},
+		},
+		expectedErr: fmt.Errorf("status 404"),
+	},
+	{
+		description: "Fail on missing workspace",
+		bitbucket: func(baseUrl string) *BitbucketSettings {
+			return &BitbucketSettings{BaseUrl: baseUrl, Repository: "repo"}
+		},
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			return Bitbucket{}.FindPr(ctx, settings, "bit-dom1")
+		},
+		expectedRequests: []apiRequest{},
+		expectedErr:      fmt.Errorf("missing or empty config field"),
+	},
+}
+
+func TestBitbucketApi(t *testing.T) {
+	ctxWithSilentLogger := ContextWithSilentLogger(context.Background())
+	t.Setenv("BITBUCKET_TOKEN", "token")
+
+	for _, tt := range bitbucketApiTests {
+		t.Run(tt.description, func(t *testing.T) {
+			server, gotRequests := fixtureApi(t, tt.responses)
+			settings := fixtureBigChange().Settings
+			settings.Bitbucket = tt.bitbucket(server.URL)
+
+			gotResult, gotErr := tt.call(ctxWithSilentLogger, settings)
+
+			// We get an error when we don't expect it or we don't get one when we expect it
+			if tt.expectedErr != nil != (gotErr != nil) {
+				t.Errorf("got '%v', want '%v'", gotErr, tt.expectedErr)
+			}
+
+			diff := cmp.Diff(gotResult, tt.expectedResult)
+			if diff != "" {
+				t.Errorf("%v", diff)
+			}
+			diff = cmp.Diff(*gotRequests, tt.expectedRequests)
+			if diff != "" {
+				t.Errorf("%v", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Prepended by Gerrit to every JSON response
+const gerritXssiPrefix = ")]}'"
+
+type GerritChange struct {
+	Number          int                    `json:"_number"`
+	ChangeId        string                 `json:"change_id"`
+	Project         string                 `json:"project"`
+	Status          string                 `json:"status"`
+	WorkInProgress  bool                   `json:"work_in_progress"`
+	Mergeable       *bool                  `json:"mergeable"`
+	Labels          map[string]gerritLabel `json:"labels"`
+	CurrentRevision string                 `json:"current_revision"`
+	Revisions       map[string]struct {
+		Commit struct {
+			Message string `json:"message"`
+		} `json:"commit"`
+	} `json:"revisions"`
+}
+
+type gerritLabel struct {
+	Approved *struct{} `json:"approved"`
+	Rejected *struct{} `json:"rejected"`
+}
+
+type gerritRepo struct {
+	settings *GerritSettings
+	client   *restClient
+}
+
+type Gerrit struct {
+	unsupportedFeatures
+	gitOps *GitOps
+}
+
+func (gerrit Gerrit) withGitOps(gitOps *GitOps) Platform {
+	gerrit.gitOps = gitOps
+	return gerrit
+}
+
+// Each domain becomes a change pushed to `refs/for/<mainBranch>`, the split
+// branch is only kept locally
+func (gerrit Gerrit) CreatePr(ctx context.Context, settings *Settings, head, title, description string) (string, error) {
+	repo, err := newGerritRepo(ctx, settings)
+	if err != nil {
+		return "", err
+	}
+
+	changeId := gerritChangeId(settings.Gerrit.Topic, head)
+	err = gerritPushChange(ctx, gerrit.gitOps, settings, head, changeId, title, description)
+	if err != nil {
+		return "", err
+	}
+
+	change, err := repo.findChange(ctx, changeId, false)
+	if err != nil {
+		return "", err
+	}
+	if change == nil {
+		return "", fmt.Errorf("pushed change not found for branch '%s'", head)
+	}
+	return repo.changeUrl(change), nil
+}
+
+func (Gerrit) ClosePr(ctx context.Context, settings *Settings, sourceBranch string) error {
+	repo, err := newGerritRepo(ctx, settings)
+	if err != nil {
+		return err
+	}
+
+	change, err := repo.findChange(ctx, gerritChangeId(settings.Gerrit.Topic, sourceBranch), false)
+	if err != nil || change == nil {
+		return err
+	}
+	return repo.client.do(ctx, http.MethodPost, fmt.Sprintf("/changes/%d/abandon", change.Number), map[string]any{}, nil)
+}
+
+// Returns an empty url when the branch has no open change
+func (Gerrit) FindPr(ctx context.Context, settings *Settings, sourceBranch string) (string, error) {
+	repo, err := newGerritRepo(ctx, settings)
+	if err != nil {
+		return "", err
+	}
+
+	change, err := repo.findChange(ctx, gerritChangeId(settings.Gerrit.Topic, sourceBranch), false)
+	if err != nil || change == nil {
+		return "", err
+	}
+	return repo.changeUrl(change), nil
+}
+
+// Uploads the rebuilt split branch as a new patch set of the existing change
+func (gerrit Gerrit) UpdatePr(ctx context.Context, settings *Settings, sourceBranch, title, description string) error {
+	repo, err := newGerritRepo(ctx, settings)
+	if err != nil {
+		return err
+	}
+
+	changeId := gerritChangeId(settings.Gerrit.Topic, sourceBranch)
+	change, err := repo.findChange(ctx, changeId, false)
+	if err != nil {
+		return err
+	}
+	if change == nil {
+		return fmt.Errorf("no open change for branch '%s'", sourceBranch)
+	}
+
+	// A new patch set with the same content would reset the votes on some setups
+	if gerritSamePatchSet(ctx, gerrit.gitOps, change, sourceBranch, gerritCommitMsg(changeId, title, description)) {
+		log := LoggerFromContext(ctx)
+		log.Debug("change already up to date", "branch", sourceBranch, "change", change.Number)
+		return nil
+	}
+	return gerritPushChange(ctx, gerrit.gitOps, settings, sourceBranch, changeId, title, description)
+}
+
+func (Gerrit) PrStatus(ctx context.Context, settings *Settings, sourceBranch string) (*PrStatus, error) {
+	repo, err := newGerritRepo(ctx, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	change, err := repo.findChange(ctx, gerritChangeId(settings.Gerrit.Topic, sourceBranch), true)
+	if err != nil {
+		return nil, err
+	}
+	if change == nil {
+		return noPrStatus(), nil
+	}
+	return change.toPrStatus(repo.changeUrl(change)), nil
+}
+
+// Gerrit has no branches to push nor delete, changes are uploaded by CreatePr
+func (Gerrit) Branchless() bool {
+	return true
+}
+
+func (change *GerritChange) toPrStatus(changeUrl string) *PrStatus {
+	status := noPrStatus()
+	status.Url = changeUrl
+
+	switch {
+	case change.Status == "MERGED":
+		status.State = PrStateMerged
+	case change.Status == "ABANDONED":
+		status.State = PrStateAbandoned
+	case change.WorkInProgress:
+		status.State = PrStateDraft
+	default:
+		status.State = PrStateOpen
+	}
+
+	if codeReview, ok := change.Labels["Code-Review"]; ok {
+		switch {
+		case codeReview.Rejected != nil:
+			status.Review = ReviewChangesRequested
+		case codeReview.Approved != nil:
+			status.Review = ReviewApproved
+		default:
+			status.Review = ReviewRequired
+		}
+	}
+
+	if verified, ok := change.Labels["Verified"]; ok {
+		switch {
+		case verified.Rejected != nil:
+			status.Checks = ChecksFailure
+		case verified.Approved != nil:
+			status.Checks = ChecksSuccess
+		default:
+			status.Checks = ChecksPending
+		}
+	}
+
+	if change.Status == "NEW" && change.Mergeable != nil {
+		if *change.Mergeable {
+			status.Mergeable = MergeableYes
+		} else {
+			status.Mergeable = MergeableConflicting
+		}
+	}
+	return status
+}
+
+// The Change-Id is derived from the topic and the branch so the same domain
+// always maps to the same change, across runs, syncs and machines
+func gerritChangeId(topic string, branch string) string {
+	return fmt.Sprintf("I%x", sha1.Sum([]byte(topic+"/"+branch)))
+}
+
+func gerritCommitMsg(changeId, title, description string) string {
+	msg := title + "\n\n"
+	if description != "" {
+		msg += description + "\n\n"
+	}
+	return msg + "Change-Id: " + changeId + "\n"
+}
+
+// Rewrites the split branch commit with the PR title and body as message
+// and the Change-Id trailer, then uploads it for review
+func gerritPushChange(ctx context.Context, gitOps *GitOps, settings *Settings, branch, changeId, title, description string) error {
+	commitSha, err := gitOps.gitRewordCommit(ctx, branch, gerritCommitMsg(changeId, title, description))
+	if err != nil {
+		return err
+	}
+
+	err = gitOps.gitPushRef(ctx, settings.Remote, commitSha, gerritRefSpec(settings))
+	// Uploading an unchanged commit again is not a failure
+	if err != nil && !strings.Contains(err.Error(), "no new changes") {
+		return err
+	}
+	return nil
+}
+
+func gerritRefSpec(settings *Settings) string {
+	var options []string
+	if settings.Gerrit.Topic != "" {
+		options = append(options, "topic="+url.QueryEscape(settings.Gerrit.Topic))
+	}
+	if settings.IsDraftPrs {
+		options = append(options, "wip")
+	}
+
+	refSpec := "refs/for/" + settings.MainBranch
+	if len(options) > 0 {
+		refSpec += "%" + strings.Join(options, ",")
+	}
+	return refSpec
+}
+
+// Only reliable when the current patch set was uploaded from this repository,
+// otherwise its commit is unknown locally and a new patch set is pushed
+func gerritSamePatchSet(ctx context.Context, gitOps *GitOps, change *GerritChange, branch string, commitMsg string) bool {
+	revision, ok := change.Revisions[change.CurrentRevision]
+	if !ok || strings.TrimSpace(revision.Commit.Message) != strings.TrimSpace(commitMsg) {
+		return false
+	}
+
+	tree, err := gitOps.gitRevParse(ctx, branch+"^{tree}")
+	if err != nil {
+		return false
+	}
+	currentTree, err := gitOps.gitRevParse(ctx, change.CurrentRevision+"^{tree}")
+	if err != nil {
+		return false
+	}
+	return tree == currentTree
+}
+
+// Credentials are taken from GERRIT_USERNAME and GERRIT_PASSWORD (the HTTP
+// password of the account), anonymous access is used otherwise
+func newGerritRepo(ctx context.Context, settings *Settings) (*gerritRepo, error) {
+	log := LoggerFromContext(ctx)
+
+	gerritSettings := settings.Gerrit
+	if gerritSettings == nil {
+		log.Error("missing or empty config field", "field", "BigChange.Settings.Gerrit")
+		return nil, fmt.Errorf("missing or empty config field")
+	}
+	if gerritSettings.BaseUrl == "" {
+		log.Error("missing or empty config field", "field", "BigChange.Settings.Gerrit.BaseUrl")
+		return nil, fmt.Errorf("missing or empty config field")
+	}
+	if gerritSettings.Project == "" {
+		log.Error("missing or empty config field", "field", "BigChange.Settings.Gerrit.Project")
+		return nil, fmt.Errorf("missing or empty config field")
+	}
+
+	baseUrl := strings.TrimSuffix(gerritSettings.BaseUrl, "/")
+	var authorize func(*http.Request)
+	if username := os.Getenv("GERRIT_USERNAME"); username != "" {
+		// Authenticated endpoints are prefixed by `/a`
+		baseUrl += "/a"
+		authorize = basicAuth(username, os.Getenv("GERRIT_PASSWORD"))
+	}
+
+	client := newRestClient(baseUrl, authorize)
+	client.responsePrefix = gerritXssiPrefix
+	return &gerritRepo{
+		settings: gerritSettings,
+		client:   client,
+	}, nil
+}
+
+func (repo *gerritRepo) changeUrl(change *GerritChange) string {
+	return fmt.Sprintf("%s/c/%s/+/%d", strings.TrimSuffix(repo.settings.BaseUrl, "/"), repo.settings.Project, change.Number)
+}
+
+// Only open changes are returned unless `all` is set
+func (repo *gerritRepo) findChange(ctx context.Context, changeId string, all bool) (*GerritChange, error) {
+	search := fmt.Sprintf("change:%s project:%s", changeId, repo.settings.Project)
+	if !all {
+		search += " status:open"
+	}
+
+	query := url.Values{}
+	query.Set("q", search)
+	query.Set("n", "1")
+	query.Add("o", "LABELS")
+	query.Add("o", "CURRENT_REVISION")
+	query.Add("o", "CURRENT_COMMIT")
+
+	var changes []GerritChange
+	err := repo.client.do(ctx, http.MethodGet, "/changes/?"+query.Encode(), nil, &changes)
+	if err != nil {
+		return nil, err
+	}
+	if len(changes) < 1 {
+		return nil, nil
+	}
+	return &changes[0], nil
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var gerritPrStatusTests = []struct {
+	description    string
+	rawChange      string
+	expectedStatus *PrStatus
+}{
+	{
+		description: "Open change approved and verified",
+		rawChange: `{"_number": 1, "status": "NEW", "mergeable": true,
+			"labels": {"Code-Review": {"approved": {"_account_id": 1}}, "Verified": {"approved": {"_account_id": 2}}}}`,
+		expectedStatus: &PrStatus{
+			Url:       "https://gerrit.example.com/c/repo/+/1",
+			State:     PrStateOpen,
+			Review:    ReviewApproved,
+			Checks:    ChecksSuccess,
+			Mergeable: MergeableYes,
+		},
+	},
+	{
+		description: "Work in progress change rejected, failing verification and conflicting",
+		rawChange: `{"_number": 1, "status": "NEW", "work_in_progress": true, "mergeable": false,
+			"labels": {"Code-Review": {"rejected": {"_account_id": 1}}, "Verified": {"rejected": {"_account_id": 2}}}}`,
+		expectedStatus: &PrStatus{
+			Url:       "https://gerrit.example.com/c/repo/+/1",
+			State:     PrStateDraft,
+			Review:    ReviewChangesRequested,
+			Checks:    ChecksFailure,
+			Mergeable: MergeableConflicting,
+		},
+	},
+	{
+		description: "Open change waiting for review and verification",
+		rawChange:   `{"_number": 1, "status": "NEW", "labels": {"Code-Review": {}, "Verified": {}}}`,
+		expectedStatus: &PrStatus{
+			Url:       "https://gerrit.example.com/c/repo/+/1",
+			State:     PrStateOpen,
+			Review:    ReviewRequired,
+			Checks:    ChecksPending,
+			Mergeable: MergeableUnknown,
+		},
+	},
+	{
+		description: "Abandoned change",
+		rawChange:   `{"_number": 1, "status": "ABANDONED", "mergeable": true}`,
+		expectedStatus: &PrStatus{
+			Url:       "https://gerrit.example.com/c/repo/+/1",
+			State:     PrStateAbandoned,
+			Review:    ReviewNone,
+			Checks:    ChecksNone,
+			Mergeable: MergeableUnknown,
+		},
+	},
+}
+
+func TestGerritPrStatus(t *testing.T) {
+	for _, tt := range gerritPrStatusTests {
+		t.Run(tt.description, func(t *testing.T) {
+			var change GerritChange
+			if err := json.Unmarshal([]byte(tt.rawChange), &change); err != nil {
+				t.Fatalf("invalid test change: %v", err)
+			}
+
+			diff := cmp.Diff(change.toPrStatus("https://gerrit.example.com/c/repo/+/1"), tt.expectedStatus)
+			if diff != "" {
+				t.Errorf("%v", diff)
+			}
+		})
+	}
+}
+
+func TestGerritChangeId(t *testing.T) {
+	changeId := gerritChangeId("big-change", "bit-dom1")
+
+	if len(changeId) != 41 || changeId[0] != 'I' {
+		t.Errorf("got '%s', want 'I' followed by 40 hexadecimal characters", changeId)
+	}
+	if changeId != gerritChangeId("big-change", "bit-dom1") {
+		t.Errorf("got different Change-Ids for the same topic and branch")
+	}
+	if changeId == gerritChangeId("big-change", "bit-dom2") {
+		t.Errorf("got the same Change-Id for different branches")
+	}
+}
+
+var gerritRefSpecTests = []struct {
+	description     string
+	settings        *Settings
+	expectedRefSpec string
+}{
+	{
+		description:     "Topic",
+		settings:        &Settings{MainBranch: "main", Gerrit: &GerritSettings{Topic: "big change"}},
+		expectedRefSpec: "refs/for/main%topic=big+change",
+	},
+	{
+		description:     "Topic and work in progress",
+		settings:        &Settings{MainBranch: "main", IsDraftPrs: true, Gerrit: &GerritSettings{Topic: "big-change"}},
+		expectedRefSpec: "refs/for/main%topic=big-change,wip",
+	},
+	{
+		description:     "No options",
+		settings:        &Settings{MainBranch: "main", Gerrit: &GerritSettings{}},
+		expectedRefSpec: "refs/for/main",
+	},
+}
+
+func TestGerritRefSpec(t *testing.T) {
+	for _, tt := range gerritRefSpecTests {
+		t.Run(tt.description, func(t *testing.T) {
+			diff := cmp.Diff(gerritRefSpec(tt.settings), tt.expectedRefSpec)
+			if diff != "" {
+				t.Errorf("%v", diff)
+			}
+		})
+	}
+}
+
+var gerritPushChangeTests = []struct {
+	description     string
+	pushErr         error
+	expectedMessage string
+	expectedPush    string
+	expectedErr     error
+}{
+	{
+		description:     "Push the reworded commit for review",
+		expectedMessage: "title\n\nbody\n\nChange-Id: I1\n",
+		expectedPush:    "origin bit-dom1-reworded-sha refs/for/main%topic=big-change",
+	},
+	{
+		description:     "Unchanged commit pushed again",
+		pushErr:         fmt.Errorf("exit status 1: ! [remote rejected] HEAD -> refs/for/main (no new changes)"),
+		expectedMessage: "title\n\nbody\n\nChange-Id: I1\n",
+		expectedPush:    "origin bit-dom1-reworded-sha refs/for/main%topic=big-change",
+	},
+	{
+		description:     "Fail on rejected push",
+		pushErr:         fmt.Errorf("exit status 1: ! [remote rejected] HEAD -> refs/for/main (prohibited by Gerrit)"),
+		expectedMessage: "title\n\nbody\n\nChange-Id: I1\n",
+		expectedPush:    "origin bit-dom1-reworded-sha refs/for/main%topic=big-change",
+		expectedErr:     fmt.Errorf("exit status 1: ! [remote rejected] HEAD -> refs/for/main (prohibited by Gerrit)"),
+	},
+}
+
+func TestGerritPushChange(t *testing.T) {
+	ctxWithSilentLogger := ContextWithSilentLogger(context.Background())
+
+	for _, tt := range gerritPushChangeTests {
+		t.Run(tt.description, func(t *testing.T) {
+			var gotMessage, gotPush string
+			gitOps := fixtureGitOps(func(g *GitOps) {
+				g.gitRewordCommit = func(ctx context.Context, s1, s2 string) (string, error) {
+					gotMessage = s2
+					return s1 + "-reworded-sha", nil
+				}
+				g.gitPushRef = func(ctx context.Context, s1, s2, s3 string) error {
+					gotPush = strings.Join([]string{s1, s2, s3}, " ")
+					return tt.pushErr
+				}
+			})
+			settings := fixtureBigChange().Settings
+			settings.Gerrit = &GerritSettings{Topic: "big-change"}
+
+			gotErr := gerritPushChange(ctxWithSilentLogger, gitOps, settings, "bit-dom1", "I1", "title", "body")
+
+			// We get an error when we don't expect it or we don't get one when we expect it
+			if tt.expectedErr != nil != (gotErr != nil) {
+				t.Errorf("got '%v', want '%v'", gotErr, tt.expectedErr)
+			}
+			diff := cmp.Diff(gotMessage, tt.expectedMessage)
+			if diff != "" {
+				t.Errorf("%v", diff)
+			}
+			diff = cmp.Diff(gotPush, tt.expectedPush)
+			if diff != "" {
+				t.Errorf("%v", diff)
+			}
+		})
+	}
+}
+
+var gerritChangeQuery = "/a/changes/?n=1&o=LABELS&o=CURRENT_REVISION&o=CURRENT_COMMIT&q=change%3A" +
+	gerritChangeId("big-change", "bit-dom1") + "+project%3Arepo+status%3Aopen"
+
+var gerritApiTests = []struct {
+	description string
+	responses   map[string]string
+	call        func(ctx context.Context, settings *Settings) (string, error)
+	// Relative to the fake server url
+	expectedResult   string
+	expectedRequests []apiRequest
+	expectedErr      error
+}{
+	{
+		description: "Find change",
+		responses: map[string]string{
+			"GET /a/changes/": ")]}'\n[{\"_number\": 12, \"status\": \"NEW\"}]",
+		},
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			return Gerrit{}.FindPr(ctx, settings, "bit-dom1")
+		},
+		expectedResult: "/c/repo/+/12",
+		expectedRequests: []apiRequest{
+			{Method: "GET", Url: gerritChangeQuery, Auth: "Basic dXNlcjpwYXNzd29yZA=="},
+		},
+	},
+	{
+		description: "Abandon change",
+		responses: map[string]string{
+			"GET /a/changes/":            ")]}'\n[{\"_number\": 12, \"status\": \"NEW\"}]",
+			"POST /a/changes/12/abandon": ")]}'\n{\"_number\": 12, \"status\": \"ABANDONED\"}",
+		},
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			return "", Gerrit{}.ClosePr(ctx, settings, "bit-dom1")
+		},
+		expectedRequests: []apiRequest{
+			{Method: "GET", Url: gerritChangeQuery, Auth: "Basic dXNlcjpwYXNzd29yZA=="},
+			{Method: "POST", Url: "/a/changes/12/abandon", Auth: "Basic dXNlcjpwYXNzd29yZA==", Body: `{}`},
+		},
+	},
+	{
+		description: "No change to abandon",
+		responses: map[string]string{
+			"GET /a/changes/": ")]}'\n[]",
+		},
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			return "", Gerrit{}.ClosePr(ctx, settings, "bit-dom1")
+		},
+		expectedRequests: []apiRequest{
+			{Method: "GET", Url: gerritChangeQuery, Auth: "Basic dXNlcjpwYXNzd29yZA=="},
+		},
+	},
+	{
+		description: "Fail on update without open change",
+		responses: map[string]string{
+			"GET /a/changes/": ")]}'\n[]",
+		},
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			return "", Gerrit{}.UpdatePr(ctx, settings, "bit-dom1", "title", "body")
+		},
+		expectedRequests: []apiRequest{
+			{Method: "GET", Url: gerritChangeQuery, Auth: "Basic dXNlcjpwYXNzd29yZA=="},
+		},
+		expectedErr: fmt.Errorf("no open change for branch 'bit-dom1'"),
+	},
+}
+
+func TestGerritApi(t *testing.T) {
+	ctxWithSilentLogger := ContextWithSilentLogger(context.Background())
+	t.Setenv("GERRIT_USERNAME", "user")
+	t.Setenv("GERRIT_PASSWORD", "password")
+
+	for _, tt := range gerritApiTests {
+		t.Run(tt.description, func(t *testing.T) {
+			server, gotRequests := fixtureApi(t, tt.responses)
+			settings := fixtureBigChange().Settings
+			settings.Gerrit = &GerritSettings{BaseUrl: server.URL, Project: "repo", Topic: "big-change"}
+
+			gotResult, gotErr := tt.call(ctxWithSilentLogger, settings)
+
+			// We get an error when we don't expect it or we don't get one when we expect it
+			if tt.expectedErr != nil != (gotErr != nil) {
+				t.Errorf("got '%v', want '%v'", gotErr, tt.expectedErr)
+			}
+			// We get a different error of what's expected
+			if tt.expectedErr != nil && gotErr != nil &&
+				tt.expectedErr.Error() != gotErr.Error() {
+				t.Errorf("got '%v', want '%v'", gotErr, tt.expectedErr)
+			}
+
+			expectedResult := tt.expectedResult
+			if expectedResult != "" {
+				expectedResult = server.URL + expectedResult
+			}
+			diff := cmp.Diff(gotResult, expectedResult)
+			if diff != "" {
+				t.Errorf("%v", diff)
+			}
+			diff = cmp.Diff(*gotRequests, tt.expectedRequests)
+			if diff != "" {
+				t.Errorf("%v", diff)
+			}
+		})
+	}
+}
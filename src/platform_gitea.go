@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Gitea has no draft flag, PRs with this title prefix are treated as work in progress
+const giteaWipPrefix = "WIP: "
+
+// Max page size accepted by default by Gitea
+const giteaPageSize = 50
+
+type GiteaPr struct {
+	Number    int    `json:"number"`
+	HtmlUrl   string `json:"html_url"`
+	Title     string `json:"title"`
+	State     string `json:"state"`
+	Merged    bool   `json:"merged"`
+	Mergeable bool   `json:"mergeable"`
+	Head      struct {
+		Ref string `json:"ref"`
+		Sha string `json:"sha"`
+	} `json:"head"`
+}
+
+type giteaReview struct {
+	State     string `json:"state"`
+	Dismissed bool   `json:"dismissed"`
+	Stale     bool   `json:"stale"`
+}
+
+type giteaCombinedStatus struct {
+	State string `json:"state"`
+}
+
+type giteaRepo struct {
+	settings *GiteaSettings
+	client   *restClient
+}
+
+type Gitea struct {
+	unsupportedFeatures
+}
+
+func (Gitea) CreatePr(ctx context.Context, settings *Settings, head, title, description string) (string, error) {
+	repo, err := newGiteaRepo(ctx, settings)
+	if err != nil {
+		return "", err
+	}
+
+	if settings.IsDraftPrs {
+		title = giteaWipPrefix + title
+	}
+	body := map[string]string{
+		"head":  head,
+		"base":  settings.MainBranch,
+		"title": title,
+		"body":  description,
+	}
+	var pr GiteaPr
+	err = repo.client.do(ctx, http.MethodPost, repo.path("/pulls"), body, &pr)
+	if err != nil {
+		return "", err
+	}
+	return pr.HtmlUrl, nil
+}
+
+// Closes the open PR of the branch, if any
+func (Gitea) ClosePr(ctx context.Context, settings *Settings, sourceBranch string) error {
+	repo, err := newGiteaRepo(ctx, settings)
+	if err != nil {
+		return err
+	}
+
+	pr, err := repo.findPr(ctx, sourceBranch, false)
+	if err != nil || pr == nil {
+		return err
+	}
+	body := map[string]string{"state": "closed"}
+	return repo.client.do(ctx, http.MethodPatch, repo.path(fmt.Sprintf("/pulls/%d", pr.Number)), body, nil)
+}
+
+// Returns an empty url when the branch has no open PR
+func (Gitea) FindPr(ctx context.Context, settings *Settings, sourceBranch string) (string, error) {
+	repo, err := newGiteaRepo(ctx, settings)
+	if err != nil {
+		return "", err
+	}
+
+	pr, err := repo.findPr(ctx, sourceBranch, false)
+	if err != nil || pr == nil {
+		return "", err
+	}
+	return pr.HtmlUrl, nil
+}
+
+func (Gitea) UpdatePr(ctx context.Context, settings *Settings, sourceBranch, title, description string) error {
+	repo, err := newGiteaRepo(ctx, settings)
+	if err != nil {
+		return err
+	}
+
+	pr, err := repo.findPr(ctx, sourceBranch, false)
+	if err != nil {
+		return err
+	}
+	if pr == nil {
+		return fmt.Errorf("no open PR for branch '%s'", sourceBranch)
+	}
+
+	// PRs marked as ready by the reviewers are not turned back into drafts
+	if pr.isWip() {
+		title = giteaWipPrefix + title
+	}
+	body := map[string]string{
+		"title": title,
+		"body":  description,
+	}
+	return repo.client.do(ctx, http.MethodPatch, repo.path(fmt.Sprintf("/pulls/%d", pr.Number)), body, nil)
+}
+
+func (Gitea) PrStatus(ctx context.Context, settings *Settings, sourceBranch string) (*PrStatus, error) {
+	repo, err := newGiteaRepo(ctx, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	pr, err := repo.findPr(ctx, sourceBranch, true)
+	if err != nil {
+		return nil, err
+	}
+	if pr == nil {
+		return noPrStatus(), nil
+	}
+
+	var reviews []giteaReview
+	err = repo.client.do(ctx, http.MethodGet, repo.path(fmt.Sprintf("/pulls/%d/reviews", pr.Number)), nil, &reviews)
+	if err != nil {
+		return nil, err
+	}
+
+	var checks giteaCombinedStatus
+	if pr.Head.Sha != "" {
+		err = repo.client.do(ctx, http.MethodGet, repo.path(fmt.Sprintf("/commits/%s/status", url.PathEscape(pr.Head.Sha))), nil, &checks)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return pr.toPrStatus(reviews, checks), nil
+}
+
+func (pr GiteaPr) isWip() bool {
+	upperTitle := strings.ToUpper(pr.Title)
+	return strings.HasPrefix(upperTitle, "WIP:") || strings.HasPrefix(upperTitle, "[WIP]")
+}
+
+func (pr GiteaPr) toPrStatus(reviews []giteaReview, checks giteaCombinedStatus) *PrStatus {
+	status := noPrStatus()
+	status.Url = pr.HtmlUrl
+
+	switch {
+	case pr.Merged:
+		status.State = PrStateMerged
+	case pr.State == "closed":
+		status.State = PrStateAbandoned
+	case pr.isWip():
+		status.State = PrStateDraft
+	default:
+		status.State = PrStateOpen
+	}
+
+	for _, review := range reviews {
+		if review.Dismissed || review.Stale {
+			continue
+		}
+		switch review.State {
+		case "REQUEST_CHANGES":
+			status.Review = ReviewChangesRequested
+		case "APPROVED":
+			if status.Review != ReviewChangesRequested {
+				status.Review = ReviewApproved
+			}
+		case "REQUEST_REVIEW", "PENDING":
+			if status.Review == ReviewNone {
+				status.Review = ReviewRequired
+			}
+		}
+	}
+
+	switch checks.State {
+	case "success":
+		status.Checks = ChecksSuccess
+	case "failure", "error":
+		status.Checks = ChecksFailure
+	case "pending", "warning":
+		status.Checks = ChecksPending
+	}
+
+	if pr.State == "open" {
+		if pr.Mergeable {
+			status.Mergeable = MergeableYes
+		} else {
+			status.Mergeable = MergeableConflicting
+		}
+	}
+	return status
+}
+
+// The token is taken from GITEA_TOKEN
+func newGiteaRepo(ctx context.Context, settings *Settings) (*giteaRepo, error) {
+	log := LoggerFromContext(ctx)
+
+	giteaSettings := settings.Gitea
+	if giteaSettings == nil {
+		log.Error("missing or empty config field", "field", "BigChange.Settings.Gitea")
+		return nil, fmt.Errorf("missing or empty config field")
+	}
+	if giteaSettings.BaseUrl == "" {
+		log.Error("missing or empty config field", "field", "BigChange.Settings.Gitea.BaseUrl")
+		return nil, fmt.Errorf("missing or empty config field")
+	}
+	if giteaSettings.Owner == "" {
+		log.Error("missing or empty config field", "field", "BigChange.Settings.Gitea.Owner")
+		return nil, fmt.Errorf("missing or empty config field")
+	}
+	if giteaSettings.Repository == "" {
+		log.Error("missing or empty config field", "field", "BigChange.Settings.Gitea.Repository")
+		return nil, fmt.Errorf("missing or empty config field")
+	}
+
+	token := os.Getenv("GITEA_TOKEN")
+	if token == "" {
+		log.Error("missing Gitea credentials, set GITEA_TOKEN")
+		return nil, fmt.Errorf("missing Gitea credentials")
+	}
+
+	authorize := func(req *http.Request) {
+		req.Header.Set("Authorization", "token "+token)
+	}
+	return &giteaRepo{
+		settings: giteaSettings,
+		client:   newRestClient(strings.TrimSuffix(giteaSettings.BaseUrl, "/")+"/api/v1", authorize),
+	}, nil
+}
+
+func (repo *giteaRepo) path(subPath string) string {
+	return fmt.Sprintf("/repos/%s/%s%s",
+		url.PathEscape(repo.settings.Owner), url.PathEscape(repo.settings.Repository), subPath)
+}
+
+// Gitea cannot filter PRs by head branch so the PRs are scanned page by page,
+// only open PRs are returned unless `all` is set, the most recently updated closed
+// or merged PR is then returned when the branch has no open PR
+func (repo *giteaRepo) findPr(ctx context.Context, sourceBranch string, all bool) (*GiteaPr, error) {
+	pr, err := repo.findPrWithState(ctx, sourceBranch, "open")
+	if err != nil || pr != nil || !all {
+		return pr, err
+	}
+	return repo.findPrWithState(ctx, sourceBranch, "closed")
+}
+
+func (repo *giteaRepo) findPrWithState(ctx context.Context, sourceBranch string, state string) (*GiteaPr, error) {
+	for page := 1; ; page++ {
+		query := url.Values{}
+		query.Set("state", state)
+		query.Set("sort", "recentupdate")
+		query.Set("limit", fmt.Sprintf("%d", giteaPageSize))
+		query.Set("page", fmt.Sprintf("%d", page))
+
+		var prs []GiteaPr
+		err := repo.client.do(ctx, http.MethodGet, repo.path("/pulls?"+query.Encode()), nil, &prs)
+		if err != nil {
+			return nil, err
+		}
+		for _, pr := range prs {
+			if pr.Head.Ref == sourceBranch {
+				return &pr, nil
+			}
+		}
+		if len(prs) < giteaPageSize {
+			return nil, nil
+		}
+	}
+}
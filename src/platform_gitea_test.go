@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var giteaPrStatusTests = []struct {
+	description    string
+	rawPr          string
+	rawReviews     string
+	rawChecks      string
+	expectedStatus *PrStatus
+}{
+	{
+		description:    "Open PR approved with passing checks",
+		rawPr:          `{"number": 1, "html_url": "https://gitea.example.com/o/r/pulls/1", "title": "title", "state": "open", "mergeable": true}`,
+		rawReviews:     `[{"state": "APPROVED"}, {"state": "COMMENT"}]`,
+		rawChecks:      `{"state": "success"}`,
+		expectedStatus: &PrStatus{Url: "https://gitea.example.com/o/r/pulls/1", State: PrStateOpen, Review: ReviewApproved, Checks: ChecksSuccess, Mergeable: MergeableYes},
+	},
+	{
+		description:    "WIP PR with requested changes, conflicts and pending checks",
+		rawPr:          `{"number": 2, "html_url": "https://gitea.example.com/o/r/pulls/2", "title": "WIP: title", "state": "open", "mergeable": false}`,
+		rawReviews:     `[{"state": "REQUEST_CHANGES"}, {"state": "APPROVED"}]`,
+		rawChecks:      `{"state": "pending"}`,
+		expectedStatus: &PrStatus{Url: "https://gitea.example.com/o/r/pulls/2", State: PrStateDraft, Review: ReviewChangesRequested, Checks: ChecksPending, Mergeable: MergeableConflicting},
+	},
+	{
+		description:    "Open PR waiting for review, dismissed reviews are ignored",
+		rawPr:          `{"number": 3, "html_url": "https://gitea.example.com/o/r/pulls/3", "title": "[WIP] title", "state": "open", "mergeable": true}`,
+		rawReviews:     `[{"state": "REQUEST_CHANGES", "dismissed": true}, {"state": "REQUEST_REVIEW"}]`,
+		rawChecks:      `{"state": "failure"}`,
+		expectedStatus: &PrStatus{Url: "https://gitea.example.com/o/r/pulls/3", State: PrStateDraft, Review: ReviewRequired, Checks: ChecksFailure, Mergeable: MergeableYes},
+	},
+	{
+		description:    "Merged PR",
+		rawPr:          `{"number": 4, "html_url": "https://gitea.example.com/o/r/pulls/4", "title": "title", "state": "closed", "merged": true}`,
+		rawReviews:     `[]`,
+		rawChecks:      `{}`,
+		expectedStatus: &PrStatus{Url: "https://gitea.example.com/o/r/pulls/4", State: PrStateMerged, Review: ReviewNone, Checks: ChecksNone, Mergeable: MergeableUnknown},
+	},
+	{
+		description:    "Closed PR",
+		rawPr:          `{"number": 5, "html_url": "https://gitea.example.com/o/r/pulls/5", "title": "title", "state": "closed"}`,
+		rawReviews:     `[]`,
+		rawChecks:      `{}`,
+		expectedStatus: &PrStatus{Url: "https://gitea.example.com/o/r/pulls/5", State: PrStateAbandoned, Review: ReviewNone, Checks: ChecksNone, Mergeable: MergeableUnknown},
+	},
+}
+
+func TestGiteaPrStatus(t *testing.T) {
+	for _, tt := range giteaPrStatusTests {
+		t.Run(tt.description, func(t *testing.T) {
+			var pr GiteaPr
+			if err := json.Unmarshal([]byte(tt.rawPr), &pr); err != nil {
+				t.Fatalf("invalid test PR: %v", err)
+			}
+			var reviews []giteaReview
+			if err := json.Unmarshal([]byte(tt.rawReviews), &reviews); err != nil {
+				t.Fatalf("invalid test reviews: %v", err)
+			}
+			var checks giteaCombinedStatus
+			if err := json.Unmarshal([]byte(tt.rawChecks), &checks); err != nil {
+				t.Fatalf("invalid test checks: %v", err)
+			}
+
+			diff := cmp.Diff(pr.toPrStatus(reviews, checks), tt.expectedStatus)
+			if diff != "" {
+				t.Errorf("%v", diff)
+			}
+		})
+	}
+}
+
+const giteaPullsQuery = "/api/v1/repos/o/r/pulls?limit=50&page=1&sort=recentupdate&state=open"
+
+var giteaApiTests = []struct {
+	description      string
+	mods             []func(*Settings)
+	responses        map[string]string
+	call             func(ctx context.Context, settings *Settings) (string, error)
+	expectedResult   string
+	expectedRequests []apiRequest
+	expectedErr      error
+}{
+	{
+		description: "Create draft PR with WIP prefix",
+		mods:        []func(*Settings){func(s *Settings) { s.IsDraftPrs = true }},
+		responses: map[string]string{
+			"POST /api/v1/repos/o/r/pulls": `{"number": 1, "html_url": "https://gitea.example.com/o/r/pulls/1"}`,
+		},
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			return Gitea{}.CreatePr(ctx, settings, "bit-dom1", "title", "body")
+		},
+		expectedResult: "https://gitea.example.com/o/r/pulls/1",
+		expectedRequests: []apiRequest{
+			{
+				Method: "POST",
+				Url:    "/api/v1/repos/o/r/pulls",
+				Auth:   "token token",
+				Body:   `{"base":"main","body":"body","head":"bit-dom1","title":"WIP: title"}`,
+			},
+		},
+	},
+	{
+		description: "Find PR by head branch",
+		responses: map[string]string{
+			"GET /api/v1/repos/o/r/pulls": `[
+				{"number": 2, "html_url": "https://gitea.example.com/o/r/pulls/2", "head": {"ref": "bit-dom2"}},
+				{"number": 1, "html_url": "https://gitea.example.com/o/r/pulls/1", "head": {"ref": "bit-dom1"}}
+			]`,
+		},
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			return Gitea{}.FindPr(ctx, settings, "bit-dom1")
+		},
+		expectedResult: "https://gitea.example.com/o/r/pulls/1",
+		expectedRequests: []apiRequest{
+			{Method: "GET", Url: giteaPullsQuery, Auth: "token token"},
+		},
+	},
+	{
+		description: "Close PR",
+		responses: map[string]string{
+			"GET /api/v1/repos/o/r/pulls":     `[{"number": 1, "html_url": "https://gitea.example.com/o/r/pulls/1", "head": {"ref": "bit-dom1"}}]`,
+			"PATCH /api/v1/repos/o/r/pulls/1": `{"number": 1}`,
+		},
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			return "", Gitea{}.ClosePr(ctx, settings, "bit-dom1")
+		},
+		expectedRequests: []apiRequest{
+			{Method: "GET", Url: giteaPullsQuery, Auth: "token token"},
+			{Method: "PATCH", Url: "/api/v1/repos/o/r/pulls/1", Auth: "token token", Body: `{"state":"closed"}`},
+		},
+	},
+	{
+		description: "Update keeps the WIP prefix of draft PRs",
+		responses: map[string]string{
+			"GET /api/v1/repos/o/r/pulls":     `[{"number": 1, "title": "WIP: old title", "head": {"ref": "bit-dom1"}}]`,
+			"PATCH /api/v1/repos/o/r/pulls/1": `{"number": 1}`,
+		},
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			return "", Gitea{}.UpdatePr(ctx, settings, "bit-dom1", "title", "body")
+		},
+		expectedRequests: []apiRequest{
+			{Method: "GET", Url: giteaPullsQuery, Auth: "token token"},
+			{Method: "PATCH", Url: "/api/v1/repos/o/r/pulls/1", Auth: "token token", Body: `{"body":"body","title":"WIP: title"}`},
+		},
+	},
+	{
+		description: "Fail on update without open PR",
+		responses: map[string]string{
+			"GET /api/v1/repos/o/r/pulls": `[]`,
+		},
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			return "", Gitea{}.UpdatePr(ctx, settings, "bit-dom1", "title", "body")
+		},
+		expectedRequests: []apiRequest{
+			{Method: "GET", Url: giteaPullsQuery, Auth: "token token"},
+		},
+		expectedErr: fmt.Errorf("no open PR for branch 'bit-dom1'"),
+	},
+	{
+		description: "PR status only looks for closed PRs without open one",
+		responses: map[string]string{
+			"GET /api/v1/repos/o/r/pulls": `[]`,
+		},
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			status, err := Gitea{}.PrStatus(ctx, settings, "bit-dom1")
+			return string(status.State), err
+		},
+		expectedResult: string(PrStateNone),
+		expectedRequests: []apiRequest{
+			{Method: "GET", Url: giteaPullsQuery, Auth: "token token"},
+			{Method: "GET", Url: "/api/v1/repos/o/r/pulls?limit=50&page=1&sort=recentupdate&state=closed", Auth: "token token"},
+		},
+	},
+	{
+		description: "PR status of the open PR",
+		responses: map[string]string{
+			"GET /api/v1/repos/o/r/pulls":           `[{"number": 1, "html_url": "https://gitea.example.com/o/r/pulls/1", "state": "open", "head": {"ref": "bit-dom1"}}]`,
+			"GET /api/v1/repos/o/r/pulls/1/reviews": `[]`,
+		},
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			status, err := Gitea{}.PrStatus(ctx, settings, "bit-dom1")
+			return string(status.State), err
+		},
+		expectedResult: string(PrStateOpen),
+		expectedRequests: []apiRequest{
+			{Method: "GET", Url: giteaPullsQuery, Auth: "token token"},
+			{Method: "GET", Url: "/api/v1/repos/o/r/pulls/1/reviews", Auth: "token token"},
+		},
+	},
+	{
+		description: "Fail on missing owner",
+		mods:        []func(*Settings){func(s *Settings) { s.Gitea.Owner = "" }},
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			return Gitea{}.FindPr(ctx, settings, "bit-dom1")
+		},
+		expectedRequests: []apiRequest{},
+		expectedErr:      fmt.Errorf("missing or empty config field"),
+	},
+}
+
+func TestGiteaApi(t *testing.T) {
+	ctxWithSilentLogger := ContextWithSilentLogger(context.Background())
+	t.Setenv("GITEA_TOKEN", "token")
+
+	for _, tt := range giteaApiTests {
+		t.Run(tt.description, func(t *testing.T) {
+			server, gotRequests := fixtureApi(t, tt.responses)
+			settings := fixtureBigChange().Settings
+			settings.Gitea = &GiteaSettings{BaseUrl: server.URL, Owner: "o", Repository: "r"}
+			for _, mod := range tt.mods {
+				mod(settings)
+			}
+
+			gotResult, gotErr := tt.call(ctxWithSilentLogger, settings)
+
+			// We get an error when we don't expect it or we don't get one when we expect it
+			if tt.expectedErr != nil != (gotErr != nil) {
+				t.Errorf("got '%v', want '%v'", gotErr, tt.expectedErr)
+			}
+			// We get a different error of what's expected
+			if tt.expectedErr != nil && gotErr != nil &&
+				tt.expectedErr.Error() != gotErr.Error() {
+				t.Errorf("got '%v', want '%v'", gotErr, tt.expectedErr)
+			}
+
+			diff := cmp.Diff(gotResult, tt.expectedResult)
+			if diff != "" {
+				t.Errorf("%v", diff)
+			}
+			diff = cmp.Diff(*gotRequests, tt.expectedRequests)
+			if diff != "" {
+				t.Errorf("%v", diff)
+			}
+		})
+	}
+}
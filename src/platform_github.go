@@ -2,10 +2,32 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"strings"
 )
 
-func GitHubCreatePr(ctx context.Context, settings *Settings, head, title, body string) (string, error) {
+type gitHubPrStatus struct {
+	Url               string        `json:"url"`
+	State             string        `json:"state"`
+	IsDraft           bool          `json:"isDraft"`
+	ReviewDecision    string        `json:"reviewDecision"`
+	Mergeable         string        `json:"mergeable"`
+	StatusCheckRollup []gitHubCheck `json:"statusCheckRollup"`
+}
+
+type gitHubCheck struct {
+	// Check runs
+	Status     string `json:"status"`
+	Conclusion string `json:"conclusion"`
+	// Commit statuses
+	State string `json:"state"`
+}
+
+type GitHub struct {
+	unsupportedFeatures
+}
+
+func (GitHub) CreatePr(ctx context.Context, settings *Settings, head, title, body string) (string, error) {
 	prFlags := []string{
 		"pr", "create",
 		"-H", head,
@@ -30,7 +52,182 @@ func GitHubCreatePr(ctx context.Context, settings *Settings, head, title, body s
 	return strings.Trim(prUrl, "'"), nil
 }
 
+// Returns an empty url when the branch has no open PR
+func (GitHub) FindPr(ctx context.Context, _ *Settings, head string) (string, error) {
+	rawPrUrl, err := runCmd(ctx, "gh", "pr", "list", "--head", head, "--state", "open", "--limit", "1", "--json", "url", "--jq", ".[].url")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(rawPrUrl[:])), nil
+}
+
+func (GitHub) UpdatePr(ctx context.Context, _ *Settings, head, title, body string) error {
+	_, err := runCmd(ctx, "gh", "pr", "edit", head, "-t", title, "-b", body)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Teams are given as `org/team-slug`, the leading `@` used in CODEOWNERS is optional
+func (GitHub) AddReviewers(ctx context.Context, settings *Settings, head string, reviewers []string) error {
+	if settings.RequiredReviewers {
+		return &UnsupportedFeatureError{Feature: "required reviewers"}
+	}
+	var names []string
+	for _, reviewer := range reviewers {
+		names = append(names, strings.TrimPrefix(reviewer, "@"))
+	}
+
+	_, err := runCmd(ctx, "gh", "pr", "edit", head, "--add-reviewer", strings.Join(names, ","))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (GitHub) AddLabels(ctx context.Context, _ *Settings, head string, labels []string) error {
+	_, err := runCmd(ctx, "gh", "pr", "edit", head, "--add-label", strings.Join(labels, ","))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (GitHub) SetMilestone(ctx context.Context, _ *Settings, head string, milestone string) error {
+	_, err := runCmd(ctx, "gh", "pr", "edit", head, "--milestone", milestone)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Head branches are deleted after the merge only when the repository setting
+// "Automatically delete head branches" is enabled, `deleteBranch` has no effect
+func (GitHub) EnableAutoMerge(ctx context.Context, _ *Settings, head string, autoMerge *AutoMerge) error {
+	_, err := runCmd(ctx, "gh", "pr", "merge", head, "--auto", "--"+string(autoMerge.Method))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// gh prints the url of the created issue as last line
+func (GitHub) CreateIssue(ctx context.Context, _ *Settings, title, body string) (string, error) {
+	rawIssueUrl, err := runCmd(ctx, "gh", "issue", "create", "-t", title, "-b", body)
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(strings.TrimSpace(string(rawIssueUrl[:])), "\n")
+	return strings.TrimSpace(lines[len(lines)-1]), nil
+}
+
+// The search is fuzzy so the exact title is matched on the results
+func (GitHub) FindIssue(ctx context.Context, _ *Settings, title string) (string, error) {
+	resp, err := runCmd(ctx, "gh", "issue", "list",
+		"--search", title+" in:title",
+		"--state", "open",
+		"--json", "url,title")
+	if err != nil {
+		return "", err
+	}
+
+	var issues []struct {
+		Url   string `json:"url"`
+		Title string `json:"title"`
+	}
+	if err := json.Unmarshal(resp, &issues); err != nil {
+		log := LoggerFromContext(ctx)
+		log.Error("failed to unmarshal the issues", "error", err)
+		return "", err
+	}
+	for _, issue := range issues {
+		if issue.Title == title {
+			return issue.Url, nil
+		}
+	}
+	return "", nil
+}
+
+func (GitHub) UpdateIssue(ctx context.Context, _ *Settings, issueUrl, title, body string) error {
+	_, err := runCmd(ctx, "gh", "issue", "edit", issueUrl, "-t", title, "-b", body)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (GitHub) PrStatus(ctx context.Context, _ *Settings, head string) (*PrStatus, error) {
+	resp, err := runCmd(ctx, "gh", "pr", "list",
+		"--head", head,
+		"--state", "all",
+		"--limit", "1",
+		"--json", "url,state,isDraft,reviewDecision,mergeable,statusCheckRollup")
+	if err != nil {
+		return nil, err
+	}
+
+	var prs []gitHubPrStatus
+	if err := json.Unmarshal(resp, &prs); err != nil {
+		log := LoggerFromContext(ctx)
+		log.Error("failed to unmarshal the PR status", "error", err)
+		return nil, err
+	}
+	if len(prs) < 1 {
+		return noPrStatus(), nil
+	}
+	return prs[0].toPrStatus(), nil
+}
+
+func (pr gitHubPrStatus) toPrStatus() *PrStatus {
+	status := noPrStatus()
+	status.Url = pr.Url
+
+	switch {
+	case pr.State == "MERGED":
+		status.State = PrStateMerged
+	case pr.State == "CLOSED":
+		status.State = PrStateAbandoned
+	case pr.IsDraft:
+		status.State = PrStateDraft
+	default:
+		status.State = PrStateOpen
+	}
+
+	switch pr.ReviewDecision {
+	case "APPROVED":
+		status.Review = ReviewApproved
+	case "CHANGES_REQUESTED":
+		status.Review = ReviewChangesRequested
+	case "REVIEW_REQUIRED":
+		status.Review = ReviewRequired
+	}
+
+	switch pr.Mergeable {
+	case "MERGEABLE":
+		status.Mergeable = MergeableYes
+	case "CONFLICTING":
+		status.Mergeable = MergeableConflicting
+	}
+
+	for _, check := range pr.StatusCheckRollup {
+		switch {
+		case check.Conclusion == "FAILURE" || check.Conclusion == "TIMED_OUT" ||
+			check.Conclusion == "CANCELLED" || check.Conclusion == "ACTION_REQUIRED" ||
+			check.State == "FAILURE" || check.State == "ERROR":
+			status.Checks = ChecksFailure
+		case status.Checks == ChecksFailure:
+		case (check.Status != "" && check.Status != "COMPLETED") ||
+			check.State == "PENDING" || check.State == "EXPECTED":
+			status.Checks = ChecksPending
+		case status.Checks == ChecksNone:
+			status.Checks = ChecksSuccess
+		}
+	}
+	return status
+}
+
 // GitHub automatically abandon PR with deleted source branches, so this is a noOp
-func GitHubAbandonPr(_ context.Context, _ string) error {
+func (GitHub) ClosePr(_ context.Context, _ *Settings, _ string) error {
 	return nil
 }
@@ -0,0 +1,531 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const gitHubApiBaseUrl = "https://api.github.com"
+
+const gitHubPrStatusQuery = `query($owner: String!, $repository: String!, $head: String!) {
+  repository(owner: $owner, name: $repository) {
+    pullRequests(headRefName: $head, first: 1, orderBy: {field: CREATED_AT, direction: DESC}) {
+      nodes {
+        url
+        state
+        isDraft
+        reviewDecision
+        mergeable
+        commits(last: 1) {
+          nodes {
+            commit {
+              statusCheckRollup {
+                contexts(first: 100) {
+                  nodes {
+                    ... on CheckRun { status conclusion }
+                    ... on StatusContext { state }
+                  }
+                }
+              }
+            }
+          }
+        }
+      }
+    }
+  }
+}`
+
+const gitHubEnableAutoMergeMutation = `mutation($pullRequestId: ID!, $mergeMethod: PullRequestMergeMethod!) {
+  enablePullRequestAutoMerge(input: {pullRequestId: $pullRequestId, mergeMethod: $mergeMethod}) {
+    clientMutationId
+  }
+}`
+
+// Matches git@github.com:owner/repo.git, https://github.com/owner/repo and ssh://git@host/owner/repo.git
+var gitHubRemoteUrlRegexp = regexp.MustCompile(`[:/]([^/:]+)/([^/]+?)(\.git)?/?$`)
+
+type GitHubApiPr struct {
+	Number  int    `json:"number"`
+	NodeId  string `json:"node_id"`
+	HtmlUrl string `json:"html_url"`
+}
+
+type gitHubGraphQlError struct {
+	Message string `json:"message"`
+}
+
+// `pull_request` is only set on the PRs, which are listed with the issues
+type gitHubIssue struct {
+	Number      int             `json:"number"`
+	Title       string          `json:"title"`
+	HtmlUrl     string          `json:"html_url"`
+	PullRequest json.RawMessage `json:"pull_request"`
+}
+
+type gitHubMilestone struct {
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+}
+
+type gitHubGraphQlPrs struct {
+	Data struct {
+		Repository struct {
+			PullRequests struct {
+				Nodes []struct {
+					gitHubPrStatus
+					Commits struct {
+						Nodes []struct {
+							Commit struct {
+								StatusCheckRollup *struct {
+									Contexts struct {
+										Nodes []gitHubCheck `json:"nodes"`
+									} `json:"contexts"`
+								} `json:"statusCheckRollup"`
+							} `json:"commit"`
+						} `json:"nodes"`
+					} `json:"commits"`
+				} `json:"nodes"`
+			} `json:"pullRequests"`
+		} `json:"repository"`
+	} `json:"data"`
+	Errors []gitHubGraphQlError `json:"errors"`
+}
+
+type gitHubRepo struct {
+	owner      string
+	repository string
+	client     *restClient
+	graphQl    *restClient
+}
+
+type GitHubApi struct {
+	unsupportedFeatures
+	// Reads the url of the remote when the repository is not in the settings
+	gitOps *GitOps
+}
+
+func (gitHub GitHubApi) withGitOps(gitOps *GitOps) Platform {
+	gitHub.gitOps = gitOps
+	return gitHub
+}
+
+func (gitHub GitHubApi) CreatePr(ctx context.Context, settings *Settings, head, title, body string) (string, error) {
+	repo, err := newGitHubRepo(ctx, gitHub.gitOps, settings)
+	if err != nil {
+		return "", err
+	}
+
+	reqBody := map[string]any{
+		"head":  head,
+		"base":  settings.MainBranch,
+		"title": title,
+		"body":  body,
+		"draft": settings.IsDraftPrs,
+	}
+	var pr GitHubApiPr
+	err = repo.client.do(ctx, http.MethodPost, repo.path("/pulls"), reqBody, &pr)
+	if err != nil {
+		return "", err
+	}
+	return pr.HtmlUrl, nil
+}
+
+// Closes the open PR of the branch, if any
+func (gitHub GitHubApi) ClosePr(ctx context.Context, settings *Settings, head string) error {
+	repo, err := newGitHubRepo(ctx, gitHub.gitOps, settings)
+	if err != nil {
+		return err
+	}
+
+	pr, err := repo.findPr(ctx, head)
+	if err != nil || pr == nil {
+		return err
+	}
+	reqBody := map[string]string{"state": "closed"}
+	return repo.client.do(ctx, http.MethodPatch, repo.path(fmt.Sprintf("/pulls/%d", pr.Number)), reqBody, nil)
+}
+
+// Returns an empty url when the branch has no open PR
+func (gitHub GitHubApi) FindPr(ctx context.Context, settings *Settings, head string) (string, error) {
+	repo, err := newGitHubRepo(ctx, gitHub.gitOps, settings)
+	if err != nil {
+		return "", err
+	}
+
+	pr, err := repo.findPr(ctx, head)
+	if err != nil || pr == nil {
+		return "", err
+	}
+	return pr.HtmlUrl, nil
+}
+
+func (gitHub GitHubApi) UpdatePr(ctx context.Context, settings *Settings, head, title, body string) error {
+	repo, err := newGitHubRepo(ctx, gitHub.gitOps, settings)
+	if err != nil {
+		return err
+	}
+
+	pr, err := repo.findOpenPr(ctx, head)
+	if err != nil {
+		return err
+	}
+	reqBody := map[string]string{
+		"title": title,
+		"body":  body,
+	}
+	return repo.client.do(ctx, http.MethodPatch, repo.path(fmt.Sprintf("/pulls/%d", pr.Number)), reqBody, nil)
+}
+
+func (gitHub GitHubApi) AddReviewers(ctx context.Context, settings *Settings, head string, reviewers []string) error {
+	if settings.RequiredReviewers {
+		return &UnsupportedFeatureError{Feature: "required reviewers"}
+	}
+	repo, err := newGitHubRepo(ctx, gitHub.gitOps, settings)
+	if err != nil {
+		return err
+	}
+
+	pr, err := repo.findOpenPr(ctx, head)
+	if err != nil {
+		return err
+	}
+
+	users, teamSlugs := gitHubReviewers(reviewers)
+	reqBody := map[string][]string{
+		"reviewers":      users,
+		"team_reviewers": teamSlugs,
+	}
+	return repo.client.do(ctx, http.MethodPost, repo.path(fmt.Sprintf("/pulls/%d/requested_reviewers", pr.Number)), reqBody, nil)
+}
+
+// Labels missing in the repository are created by GitHub
+func (gitHub GitHubApi) AddLabels(ctx context.Context, settings *Settings, head string, labels []string) error {
+	repo, err := newGitHubRepo(ctx, gitHub.gitOps, settings)
+	if err != nil {
+		return err
+	}
+
+	pr, err := repo.findOpenPr(ctx, head)
+	if err != nil {
+		return err
+	}
+	reqBody := map[string][]string{"labels": labels}
+	return repo.client.do(ctx, http.MethodPost, repo.path(fmt.Sprintf("/issues/%d/labels", pr.Number)), reqBody, nil)
+}
+
+func (gitHub GitHubApi) SetMilestone(ctx context.Context, settings *Settings, head string, milestone string) error {
+	repo, err := newGitHubRepo(ctx, gitHub.gitOps, settings)
+	if err != nil {
+		return err
+	}
+
+	pr, err := repo.findOpenPr(ctx, head)
+	if err != nil {
+		return err
+	}
+	number, err := repo.findMilestone(ctx, milestone)
+	if err != nil {
+		return err
+	}
+	reqBody := map[string]int{"milestone": number}
+	return repo.client.do(ctx, http.MethodPatch, repo.path(fmt.Sprintf("/issues/%d", pr.Number)), reqBody, nil)
+}
+
+// Only available through the GraphQL API, `deleteBranch` has no effect as for the gh CLI
+func (gitHub GitHubApi) EnableAutoMerge(ctx context.Context, settings *Settings, head string, autoMerge *AutoMerge) error {
+	log := LoggerFromContext(ctx)
+
+	repo, err := newGitHubRepo(ctx, gitHub.gitOps, settings)
+	if err != nil {
+		return err
+	}
+
+	pr, err := repo.findOpenPr(ctx, head)
+	if err != nil {
+		return err
+	}
+	reqBody := map[string]any{
+		"query": gitHubEnableAutoMergeMutation,
+		"variables": map[string]string{
+			"pullRequestId": pr.NodeId,
+			"mergeMethod":   strings.ToUpper(string(autoMerge.Method)),
+		},
+	}
+	var resp struct {
+		Errors []gitHubGraphQlError `json:"errors"`
+	}
+	err = repo.graphQl.do(ctx, http.MethodPost, "", reqBody, &resp)
+	if err != nil {
+		return err
+	}
+	if len(resp.Errors) > 0 {
+		log.Error("failed to enable auto-merge", "error", resp.Errors[0].Message)
+		return fmt.Errorf("failed to enable auto-merge: %s", resp.Errors[0].Message)
+	}
+	return nil
+}
+
+func (gitHub GitHubApi) CreateIssue(ctx context.Context, settings *Settings, title, body string) (string, error) {
+	repo, err := newGitHubRepo(ctx, gitHub.gitOps, settings)
+	if err != nil {
+		return "", err
+	}
+
+	reqBody := map[string]string{
+		"title": title,
+		"body":  body,
+	}
+	var issue gitHubIssue
+	err = repo.client.do(ctx, http.MethodPost, repo.path("/issues"), reqBody, &issue)
+	if err != nil {
+		return "", err
+	}
+	return issue.HtmlUrl, nil
+}
+
+// The search API only matches words, the exact title is checked on the found issues
+func (gitHub GitHubApi) FindIssue(ctx context.Context, settings *Settings, title string) (string, error) {
+	repo, err := newGitHubRepo(ctx, gitHub.gitOps, settings)
+	if err != nil {
+		return "", err
+	}
+
+	// Quotes cannot be escaped in a search phrase
+	phrase := `"` + strings.ReplaceAll(title, `"`, " ") + `"`
+	query := url.Values{}
+	query.Set("q", fmt.Sprintf("repo:%s/%s is:issue is:open in:title %s", repo.owner, repo.repository, phrase))
+	query.Set("per_page", "100")
+
+	var resp struct {
+		Items []gitHubIssue `json:"items"`
+	}
+	err = repo.client.do(ctx, http.MethodGet, "/search/issues?"+query.Encode(), nil, &resp)
+	if err != nil {
+		return "", err
+	}
+	for _, issue := range resp.Items {
+		if issue.PullRequest == nil && issue.Title == title {
+			return issue.HtmlUrl, nil
+		}
+	}
+	return "", nil
+}
+
+func (gitHub GitHubApi) UpdateIssue(ctx context.Context, settings *Settings, issueUrl, title, body string) error {
+	repo, err := newGitHubRepo(ctx, gitHub.gitOps, settings)
+	if err != nil {
+		return err
+	}
+
+	number, err := gitHubIssueNumber(ctx, issueUrl)
+	if err != nil {
+		return err
+	}
+	reqBody := map[string]string{
+		"title": title,
+		"body":  body,
+	}
+	return repo.client.do(ctx, http.MethodPatch, repo.path(fmt.Sprintf("/issues/%d", number)), reqBody, nil)
+}
+
+// The review decision and the checks rollup are only exposed by the GraphQL API
+func (gitHub GitHubApi) PrStatus(ctx context.Context, settings *Settings, head string) (*PrStatus, error) {
+	log := LoggerFromContext(ctx)
+
+	repo, err := newGitHubRepo(ctx, gitHub.gitOps, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	reqBody := map[string]any{
+		"query": gitHubPrStatusQuery,
+		"variables": map[string]string{
+			"owner":      repo.owner,
+			"repository": repo.repository,
+			"head":       head,
+		},
+	}
+	var resp gitHubGraphQlPrs
+	err = repo.graphQl.do(ctx, http.MethodPost, "", reqBody, &resp)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Errors) > 0 {
+		log.Error("failed to query the PR status", "error", resp.Errors[0].Message)
+		return nil, fmt.Errorf("failed to query the PR status: %s", resp.Errors[0].Message)
+	}
+
+	prs := resp.Data.Repository.PullRequests.Nodes
+	if len(prs) < 1 {
+		return noPrStatus(), nil
+	}
+	pr := prs[0].gitHubPrStatus
+	for _, commit := range prs[0].Commits.Nodes {
+		if commit.Commit.StatusCheckRollup != nil {
+			pr.StatusCheckRollup = commit.Commit.StatusCheckRollup.Contexts.Nodes
+		}
+	}
+	return pr.toPrStatus(), nil
+}
+
+// The token is taken from GITHUB_TOKEN or GH_TOKEN
+func newGitHubRepo(ctx context.Context, gitOps *GitOps, settings *Settings) (*gitHubRepo, error) {
+	log := LoggerFromContext(ctx)
+
+	gitHubSettings := settings.GitHub
+	if gitHubSettings == nil {
+		gitHubSettings = &GitHubSettings{}
+	}
+
+	owner, repository := gitHubSettings.Owner, gitHubSettings.Repository
+	if owner == "" || repository == "" {
+		remoteOwner, remoteRepository, err := gitHubRepoFromRemote(ctx, gitOps, settings.Remote)
+		if err != nil {
+			return nil, err
+		}
+		if owner == "" {
+			owner = remoteOwner
+		}
+		if repository == "" {
+			repository = remoteRepository
+		}
+	}
+
+	token := os.Getenv("GITHUB_TOKEN")
+	if token == "" {
+		token = os.Getenv("GH_TOKEN")
+	}
+	if token == "" {
+		log.Error("missing GitHub credentials, set GITHUB_TOKEN or GH_TOKEN")
+		return nil, fmt.Errorf("missing GitHub credentials")
+	}
+
+	baseUrl := strings.TrimSuffix(gitHubSettings.BaseUrl, "/")
+	if baseUrl == "" {
+		baseUrl = gitHubApiBaseUrl
+	}
+	client := newRestClient(baseUrl, bearerAuth(token))
+	client.headers = map[string]string{
+		"Accept":               "application/vnd.github+json",
+		"X-GitHub-Api-Version": "2022-11-28",
+	}
+
+	return &gitHubRepo{
+		owner:      owner,
+		repository: repository,
+		client:     client,
+		graphQl:    newRestClient(gitHubGraphQlUrl(baseUrl), bearerAuth(token)),
+	}, nil
+}
+
+// GitHub Enterprise serves the REST API under /api/v3 and the GraphQL one under /api/graphql
+func gitHubGraphQlUrl(baseUrl string) string {
+	if strings.HasSuffix(baseUrl, "/api/v3") {
+		return strings.TrimSuffix(baseUrl, "/v3") + "/graphql"
+	}
+	return baseUrl + "/graphql"
+}
+
+func gitHubRepoFromRemote(ctx context.Context, gitOps *GitOps, remote string) (string, string, error) {
+	remoteUrl, err := gitOps.gitRemoteUrl(ctx, remote)
+	if err != nil {
+		return "", "", err
+	}
+	return parseGitHubRemoteUrl(ctx, remoteUrl)
+}
+
+func parseGitHubRemoteUrl(ctx context.Context, remoteUrl string) (string, string, error) {
+	matches := gitHubRemoteUrlRegexp.FindStringSubmatch(remoteUrl)
+	if matches == nil {
+		log := LoggerFromContext(ctx)
+		log.Error("failed to get the GitHub repository from the remote url, set settings.github.owner and settings.github.repository",
+			"url", remoteUrl)
+		return "", "", fmt.Errorf("unknown GitHub repository")
+	}
+	return matches[1], matches[2], nil
+}
+
+// Reviewers are users or `org/team-slug` teams, with or without the leading
+// `@` used in CODEOWNERS, the API only takes the slug of the teams
+func gitHubReviewers(reviewers []string) ([]string, []string) {
+	users, teamSlugs := []string{}, []string{}
+	for _, reviewer := range reviewers {
+		reviewer = strings.TrimPrefix(reviewer, "@")
+		if _, slug, isTeam := strings.Cut(reviewer, "/"); isTeam {
+			teamSlugs = append(teamSlugs, slug)
+		} else {
+			users = append(users, reviewer)
+		}
+	}
+	return users, teamSlugs
+}
+
+// Issue urls end with the issue number, e.g. https://github.com/owner/repo/issues/12
+func gitHubIssueNumber(ctx context.Context, issueUrl string) (int, error) {
+	number, err := strconv.Atoi(path.Base(issueUrl))
+	if err != nil {
+		log := LoggerFromContext(ctx)
+		log.Error("invalid GitHub issue url", "url", issueUrl)
+		return 0, fmt.Errorf("invalid GitHub issue url '%s'", issueUrl)
+	}
+	return number, nil
+}
+
+func (repo *gitHubRepo) path(subPath string) string {
+	return fmt.Sprintf("/repos/%s/%s%s", url.PathEscape(repo.owner), url.PathEscape(repo.repository), subPath)
+}
+
+func (repo *gitHubRepo) findPr(ctx context.Context, head string) (*GitHubApiPr, error) {
+	query := url.Values{}
+	query.Set("head", repo.owner+":"+head)
+	query.Set("state", "open")
+	query.Set("per_page", "1")
+
+	var prs []GitHubApiPr
+	err := repo.client.do(ctx, http.MethodGet, repo.path("/pulls?"+query.Encode()), nil, &prs)
+	if err != nil {
+		return nil, err
+	}
+	if len(prs) < 1 {
+		return nil, nil
+	}
+	return &prs[0], nil
+}
+
+// Same as findPr but a missing PR is an error
+func (repo *gitHubRepo) findOpenPr(ctx context.Context, head string) (*GitHubApiPr, error) {
+	pr, err := repo.findPr(ctx, head)
+	if err != nil {
+		return nil, err
+	}
+	if pr == nil {
+		return nil, fmt.Errorf("no open PR for branch '%s'", head)
+	}
+	return pr, nil
+}
+
+// Only the first 100 open milestones are searched
+func (repo *gitHubRepo) findMilestone(ctx context.Context, title string) (int, error) {
+	var milestones []gitHubMilestone
+	err := repo.client.do(ctx, http.MethodGet, repo.path("/milestones?state=open&per_page=100"), nil, &milestones)
+	if err != nil {
+		return 0, err
+	}
+	for _, milestone := range milestones {
+		if milestone.Title == title {
+			return milestone.Number, nil
+		}
+	}
+
+	log := LoggerFromContext(ctx)
+	log.Error("unknown GitHub milestone", "milestone", title)
+	return 0, fmt.Errorf("unknown GitHub milestone '%s'", title)
+}
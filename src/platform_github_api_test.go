@@ -0,0 +1,408 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var parseGitHubRemoteUrlTests = []struct {
+	description        string
+	remoteUrl          string
+	expectedOwner      string
+	expectedRepository string
+	expectedErr        error
+}{
+	{
+		description:        "SSH url",
+		remoteUrl:          "git@github.com:mikysett/big-is-tiny.git",
+		expectedOwner:      "mikysett",
+		expectedRepository: "big-is-tiny",
+	},
+	{
+		description:        "HTTPS url without .git suffix",
+		remoteUrl:          "https://github.com/mikysett/big-is-tiny",
+		expectedOwner:      "mikysett",
+		expectedRepository: "big-is-tiny",
+	},
+	{
+		description:        "SSH url with scheme on GitHub Enterprise",
+		remoteUrl:          "ssh://git@github.example.com/org/repo.git",
+		expectedOwner:      "org",
+		expectedRepository: "repo",
+	},
+	{
+		description: "Fail on url without owner",
+		remoteUrl:   "repo",
+		expectedErr: fmt.Errorf("unknown GitHub repository"),
+	},
+}
+
+func TestParseGitHubRemoteUrl(t *testing.T) {
+	ctxWithSilentLogger := ContextWithSilentLogger(context.Background())
+
+	for _, tt := range parseGitHubRemoteUrlTests {
+		t.Run(tt.description, func(t *testing.T) {
+			gotOwner, gotRepository, gotErr := parseGitHubRemoteUrl(ctxWithSilentLogger, tt.remoteUrl)
+
+			// We get an error when we don't expect it or we don't get one when we expect it
+			if tt.expectedErr != nil != (gotErr != nil) {
+				t.Errorf("got '%v', want '%v'", gotErr, tt.expectedErr)
+			}
+			if gotOwner != tt.expectedOwner || gotRepository != tt.expectedRepository {
+				t.Errorf("got '%s/%s', want '%s/%s'", gotOwner, gotRepository, tt.expectedOwner, tt.expectedRepository)
+			}
+		})
+	}
+}
+
+func TestGitHubGraphQlUrl(t *testing.T) {
+	diff := cmp.Diff(gitHubGraphQlUrl("https://api.github.com"), "https://api.github.com/graphql")
+	if diff != "" {
+		t.Errorf("%v", diff)
+	}
+	diff = cmp.Diff(gitHubGraphQlUrl("https://github.example.com/api/v3"), "https://github.example.com/api/graphql")
+	if diff != "" {
+		t.Errorf("%v", diff)
+	}
+}
+
+var gitHubApiTests = []struct {
+	description      string
+	responses        map[string]string
+	call             func(ctx context.Context, settings *Settings) (string, error)
+	expectedResult   string
+	expectedRequests []apiRequest
+	expectedErr      error
+}{
+	{
+		description: "Create PR in one call",
+		responses: map[string]string{
+			"POST /repos/o/r/pulls": `{"number": 1, "html_url": "https://github.com/o/r/pull/1"}`,
+		},
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			return GitHubApi{}.CreatePr(ctx, settings, "bit-dom1", "title", "body")
+		},
+		expectedResult: "https://github.com/o/r/pull/1",
+		expectedRequests: []apiRequest{
+			{
+				Method: "POST",
+				Url:    "/repos/o/r/pulls",
+				Auth:   "Bearer token",
+				Body:   `{"base":"main","body":"body","draft":false,"head":"bit-dom1","title":"title"}`,
+			},
+		},
+	},
+	{
+		description: "Create PR in the repository of the remote url",
+		responses: map[string]string{
+			"POST /repos/o/r/pulls": `{"number": 1, "html_url": "https://github.com/o/r/pull/1"}`,
+		},
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			settings.GitHub.Owner, settings.GitHub.Repository = "", ""
+			gitOps := fixtureGitOps(func(g *GitOps) {
+				g.gitRemoteUrl = func(ctx context.Context, remote string) (string, error) {
+					if remote != "origin" {
+						return "", fmt.Errorf("unexpected remote '%s'", remote)
+					}
+					return "https://github.com/o/r.git", nil
+				}
+			})
+			return GitHubApi{}.withGitOps(gitOps).CreatePr(ctx, settings, "bit-dom1", "title", "body")
+		},
+		expectedResult: "https://github.com/o/r/pull/1",
+		expectedRequests: []apiRequest{
+			{
+				Method: "POST",
+				Url:    "/repos/o/r/pulls",
+				Auth:   "Bearer token",
+				Body:   `{"base":"main","body":"body","draft":false,"head":"bit-dom1","title":"title"}`,
+			},
+		},
+	},
+	{
+		description: "Find PR",
+		responses: map[string]string{
+			"GET /repos/o/r/pulls": `[{"number": 1, "html_url": "https://github.com/o/r/pull/1"}]`,
+		},
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			return GitHubApi{}.FindPr(ctx, settings, "bit-dom1")
+		},
+		expectedResult: "https://github.com/o/r/pull/1",
+		expectedRequests: []apiRequest{
+			{Method: "GET", Url: "/repos/o/r/pulls?head=o%3Abit-dom1&per_page=1&state=open", Auth: "Bearer token"},
+		},
+	},
+	{
+		description: "Close PR",
+		responses: map[string]string{
+			"GET /repos/o/r/pulls":     `[{"number": 1, "html_url": "https://github.com/o/r/pull/1"}]`,
+			"PATCH /repos/o/r/pulls/1": `{"number": 1}`,
+		},
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			return "", GitHubApi{}.ClosePr(ctx, settings, "bit-dom1")
+		},
+		expectedRequests: []apiRequest{
+			{Method: "GET", Url: "/repos/o/r/pulls?head=o%3Abit-dom1&per_page=1&state=open", Auth: "Bearer token"},
+			{Method: "PATCH", Url: "/repos/o/r/pulls/1", Auth: "Bearer token", Body: `{"state":"closed"}`},
+		},
+	},
+	{
+		description: "Request reviews from users and teams",
+		responses: map[string]string{
+			"GET /repos/o/r/pulls":                        `[{"number": 1, "html_url": "https://github.com/o/r/pull/1"}]`,
+			"POST /repos/o/r/pulls/1/requested_reviewers": `{"number": 1}`,
+		},
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			return "", GitHubApi{}.AddReviewers(ctx, settings, "bit-dom1", []string{"@alice", "o/team-a", "@o/team-b"})
+		},
+		expectedRequests: []apiRequest{
+			{Method: "GET", Url: "/repos/o/r/pulls?head=o%3Abit-dom1&per_page=1&state=open", Auth: "Bearer token"},
+			{
+				Method: "POST",
+				Url:    "/repos/o/r/pulls/1/requested_reviewers",
+				Auth:   "Bearer token",
+				Body:   `{"reviewers":["alice"],"team_reviewers":["team-a","team-b"]}`,
+			},
+		},
+	},
+	{
+		description: "Add labels",
+		responses: map[string]string{
+			"GET /repos/o/r/pulls":            `[{"number": 1, "html_url": "https://github.com/o/r/pull/1"}]`,
+			"POST /repos/o/r/issues/1/labels": `[{"name": "big-change"}]`,
+		},
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			return "", GitHubApi{}.AddLabels(ctx, settings, "bit-dom1", []string{"big-change"})
+		},
+		expectedRequests: []apiRequest{
+			{Method: "GET", Url: "/repos/o/r/pulls?head=o%3Abit-dom1&per_page=1&state=open", Auth: "Bearer token"},
+			{Method: "POST", Url: "/repos/o/r/issues/1/labels", Auth: "Bearer token", Body: `{"labels":["big-change"]}`},
+		},
+	},
+	{
+		description: "Set milestone by title",
+		responses: map[string]string{
+			"GET /repos/o/r/pulls":      `[{"number": 1, "html_url": "https://github.com/o/r/pull/1"}]`,
+			"GET /repos/o/r/milestones": `[{"number": 3, "title": "v1"}, {"number": 4, "title": "v2"}]`,
+			"PATCH /repos/o/r/issues/1": `{"number": 1}`,
+		},
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			return "", GitHubApi{}.SetMilestone(ctx, settings, "bit-dom1", "v2")
+		},
+		expectedRequests: []apiRequest{
+			{Method: "GET", Url: "/repos/o/r/pulls?head=o%3Abit-dom1&per_page=1&state=open", Auth: "Bearer token"},
+			{Method: "GET", Url: "/repos/o/r/milestones?state=open&per_page=100", Auth: "Bearer token"},
+			{Method: "PATCH", Url: "/repos/o/r/issues/1", Auth: "Bearer token", Body: `{"milestone":4}`},
+		},
+	},
+	{
+		description: "Fail on unknown milestone",
+		responses: map[string]string{
+			"GET /repos/o/r/pulls":      `[{"number": 1, "html_url": "https://github.com/o/r/pull/1"}]`,
+			"GET /repos/o/r/milestones": `[{"number": 3, "title": "v1"}]`,
+		},
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			return "", GitHubApi{}.SetMilestone(ctx, settings, "bit-dom1", "v2")
+		},
+		expectedRequests: []apiRequest{
+			{Method: "GET", Url: "/repos/o/r/pulls?head=o%3Abit-dom1&per_page=1&state=open", Auth: "Bearer token"},
+			{Method: "GET", Url: "/repos/o/r/milestones?state=open&per_page=100", Auth: "Bearer token"},
+		},
+		expectedErr: fmt.Errorf("unknown GitHub milestone 'v2'"),
+	},
+	{
+		description: "Create issue",
+		responses: map[string]string{
+			"POST /repos/o/r/issues": `{"number": 7, "html_url": "https://github.com/o/r/issues/7"}`,
+		},
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			return GitHubApi{}.CreateIssue(ctx, settings, "title", "body")
+		},
+		expectedResult: "https://github.com/o/r/issues/7",
+		expectedRequests: []apiRequest{
+			{Method: "POST", Url: "/repos/o/r/issues", Auth: "Bearer token", Body: `{"body":"body","title":"title"}`},
+		},
+	},
+	{
+		description: "Find issue by exact title",
+		responses: map[string]string{
+			"GET /search/issues": `{"items": [
+				{"number": 8, "title": "big change: split", "html_url": "https://github.com/o/r/issues/8"},
+				{"number": 7, "title": "big change", "html_url": "https://github.com/o/r/issues/7"}
+			]}`,
+		},
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			return GitHubApi{}.FindIssue(ctx, settings, "big change")
+		},
+		expectedResult: "https://github.com/o/r/issues/7",
+		expectedRequests: []apiRequest{
+			{Method: "GET", Url: "/search/issues?per_page=100&q=repo%3Ao%2Fr+is%3Aissue+is%3Aopen+in%3Atitle+%22big+change%22", Auth: "Bearer token"},
+		},
+	},
+	{
+		description: "No issue with the exact title",
+		responses: map[string]string{
+			"GET /search/issues": `{"items": [{"number": 8, "title": "big change: split", "html_url": "https://github.com/o/r/issues/8"}]}`,
+		},
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			return GitHubApi{}.FindIssue(ctx, settings, "big change")
+		},
+		expectedRequests: []apiRequest{
+			{Method: "GET", Url: "/search/issues?per_page=100&q=repo%3Ao%2Fr+is%3Aissue+is%3Aopen+in%3Atitle+%22big+change%22", Auth: "Bearer token"},
+		},
+	},
+	{
+		description: "Update issue",
+		responses: map[string]string{
+			"PATCH /repos/o/r/issues/7": `{"number": 7}`,
+		},
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			return "", GitHubApi{}.UpdateIssue(ctx, settings, "https://github.com/o/r/issues/7", "title", "body")
+		},
+		expectedRequests: []apiRequest{
+			{Method: "PATCH", Url: "/repos/o/r/issues/7", Auth: "Bearer token", Body: `{"body":"body","title":"title"}`},
+		},
+	},
+	{
+		description: "Fail on invalid issue url",
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			return "", GitHubApi{}.UpdateIssue(ctx, settings, "https://github.com/o/r/issues", "title", "body")
+		},
+		expectedRequests: []apiRequest{},
+		expectedErr:      fmt.Errorf("invalid GitHub issue url 'https://github.com/o/r/issues'"),
+	},
+	{
+		description: "Enable auto-merge",
+		responses: map[string]string{
+			"GET /repos/o/r/pulls": `[{"number": 1, "node_id": "PR_1", "html_url": "https://github.com/o/r/pull/1"}]`,
+			"POST /graphql":        `{"data": {"enablePullRequestAutoMerge": {"clientMutationId": null}}}`,
+		},
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			return "", GitHubApi{}.EnableAutoMerge(ctx, settings, "bit-dom1", &AutoMerge{Method: MergeMethodSquash})
+		},
+		expectedRequests: []apiRequest{
+			{Method: "GET", Url: "/repos/o/r/pulls?head=o%3Abit-dom1&per_page=1&state=open", Auth: "Bearer token"},
+			{
+				Method: "POST",
+				Url:    "/graphql",
+				Auth:   "Bearer token",
+				Body: fmt.Sprintf(`{"query":%q,"variables":{"mergeMethod":"SQUASH","pullRequestId":"PR_1"}}`,
+					gitHubEnableAutoMergeMutation),
+			},
+		},
+	},
+	{
+		description: "Fail on auto-merge not allowed in the repository",
+		responses: map[string]string{
+			"GET /repos/o/r/pulls": `[{"number": 1, "node_id": "PR_1", "html_url": "https://github.com/o/r/pull/1"}]`,
+			"POST /graphql":        `{"errors": [{"message": "Auto merge is not allowed for this repository"}]}`,
+		},
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			return "", GitHubApi{}.EnableAutoMerge(ctx, settings, "bit-dom1", &AutoMerge{Method: MergeMethodMerge})
+		},
+		expectedRequests: []apiRequest{
+			{Method: "GET", Url: "/repos/o/r/pulls?head=o%3Abit-dom1&per_page=1&state=open", Auth: "Bearer token"},
+			{
+				Method: "POST",
+				Url:    "/graphql",
+				Auth:   "Bearer token",
+				Body: fmt.Sprintf(`{"query":%q,"variables":{"mergeMethod":"MERGE","pullRequestId":"PR_1"}}`,
+					gitHubEnableAutoMergeMutation),
+			},
+		},
+		expectedErr: fmt.Errorf("failed to enable auto-merge: Auto merge is not allowed for this repository"),
+	},
+	{
+		description: "Fail on required reviewers",
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			settings.RequiredReviewers = true
+			return "", GitHubApi{}.AddReviewers(ctx, settings, "bit-dom1", []string{"alice"})
+		},
+		expectedRequests: []apiRequest{},
+		expectedErr:      fmt.Errorf("required reviewers not supported by the platform"),
+	},
+	{
+		description: "PR status",
+		responses: map[string]string{
+			"POST /graphql": `{"data": {"repository": {"pullRequests": {"nodes": [{
+				"url": "https://github.com/o/r/pull/1", "state": "OPEN", "isDraft": false,
+				"reviewDecision": "APPROVED", "mergeable": "MERGEABLE",
+				"commits": {"nodes": [{"commit": {"statusCheckRollup": {"contexts": {"nodes": [
+					{"status": "COMPLETED", "conclusion": "SUCCESS"}, {"state": "PENDING"}
+				]}}}}]}
+			}]}}}}`,
+		},
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			status, err := GitHubApi{}.PrStatus(ctx, settings, "bit-dom1")
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("%s %s %s %s", status.State, status.Review, status.Checks, status.Mergeable), nil
+		},
+		expectedResult: "open approved pending mergeable",
+		expectedRequests: []apiRequest{
+			{
+				Method: "POST",
+				Url:    "/graphql",
+				Auth:   "Bearer token",
+				Body:   fmt.Sprintf(`{"query":%q,"variables":{"head":"bit-dom1","owner":"o","repository":"r"}}`, gitHubPrStatusQuery),
+			},
+		},
+	},
+	{
+		description: "Fail on GraphQL errors",
+		responses: map[string]string{
+			"POST /graphql": `{"errors": [{"message": "Could not resolve to a Repository"}]}`,
+		},
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			_, err := GitHubApi{}.PrStatus(ctx, settings, "bit-dom1")
+			return "", err
+		},
+		expectedRequests: []apiRequest{
+			{
+				Method: "POST",
+				Url:    "/graphql",
+				Auth:   "Bearer token",
+				Body:   fmt.Sprintf(`{"query":%q,"variables":{"head":"bit-dom1","owner":"o","repository":"r"}}`, gitHubPrStatusQuery),
+			},
+		},
+		expectedErr: fmt.Errorf("failed to query the PR status: Could not resolve to a Repository"),
+	},
+}
+
+func TestGitHubApi(t *testing.T) {
+	ctxWithSilentLogger := ContextWithSilentLogger(context.Background())
+	t.Setenv("GITHUB_TOKEN", "token")
+
+	for _, tt := range gitHubApiTests {
+		t.Run(tt.description, func(t *testing.T) {
+			server, gotRequests := fixtureApi(t, tt.responses)
+			settings := fixtureBigChange().Settings
+			settings.GitHub = &GitHubSettings{BaseUrl: server.URL, Owner: "o", Repository: "r"}
+
+			gotResult, gotErr := tt.call(ctxWithSilentLogger, settings)
+
+			// We get an error when we don't expect it or we don't get one when we expect it
+			if tt.expectedErr != nil != (gotErr != nil) {
+				t.Errorf("got '%v', want '%v'", gotErr, tt.expectedErr)
+			}
+			// We get a different error of what's expected
+			if tt.expectedErr != nil && gotErr != nil &&
+				tt.expectedErr.Error() != gotErr.Error() {
+				t.Errorf("got '%v', want '%v'", gotErr, tt.expectedErr)
+			}
+
+			diff := cmp.Diff(gotResult, tt.expectedResult)
+			if diff != "" {
+				t.Errorf("%v", diff)
+			}
+			diff = cmp.Diff(*gotRequests, tt.expectedRequests)
+			if diff != "" {
+				t.Errorf("%v", diff)
+			}
+		})
+	}
+}
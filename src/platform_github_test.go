@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var gitHubPrStatusTests = []struct {
+	description    string
+	rawPr          string
+	expectedStatus *PrStatus
+}{
+	{
+		description: "Open PR approved with passing checks",
+		rawPr: `{"url": "https://github.com/o/r/pull/1", "state": "OPEN", "isDraft": false,
+			"reviewDecision": "APPROVED", "mergeable": "MERGEABLE",
+			"statusCheckRollup": [{"status": "COMPLETED", "conclusion": "SUCCESS"}, {"state": "SUCCESS"}]}`,
+		expectedStatus: &PrStatus{
+			Url:       "https://github.com/o/r/pull/1",
+			State:     PrStateOpen,
+			Review:    ReviewApproved,
+			Checks:    ChecksSuccess,
+			Mergeable: MergeableYes,
+		},
+	},
+	{
+		description: "Draft PR with a failing and a running check",
+		rawPr: `{"url": "https://github.com/o/r/pull/2", "state": "OPEN", "isDraft": true,
+			"reviewDecision": "REVIEW_REQUIRED", "mergeable": "CONFLICTING",
+			"statusCheckRollup": [{"status": "IN_PROGRESS", "conclusion": ""}, {"status": "COMPLETED", "conclusion": "FAILURE"}]}`,
+		expectedStatus: &PrStatus{
+			Url:       "https://github.com/o/r/pull/2",
+			State:     PrStateDraft,
+			Review:    ReviewRequired,
+			Checks:    ChecksFailure,
+			Mergeable: MergeableConflicting,
+		},
+	},
+	{
+		description: "Merged PR without checks",
+		rawPr:       `{"url": "https://github.com/o/r/pull/3", "state": "MERGED", "mergeable": "UNKNOWN"}`,
+		expectedStatus: &PrStatus{
+			Url:       "https://github.com/o/r/pull/3",
+			State:     PrStateMerged,
+			Review:    ReviewNone,
+			Checks:    ChecksNone,
+			Mergeable: MergeableUnknown,
+		},
+	},
+}
+
+func TestGitHubPrStatus(t *testing.T) {
+	for _, tt := range gitHubPrStatusTests {
+		t.Run(tt.description, func(t *testing.T) {
+			var pr gitHubPrStatus
+			if err := json.Unmarshal([]byte(tt.rawPr), &pr); err != nil {
+				t.Fatal(err)
+			}
+
+			diff := cmp.Diff(pr.toPrStatus(), tt.expectedStatus)
+			if diff != "" {
+				t.Errorf("%v", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+type GitLabMr struct {
+	Iid          int    `json:"iid"`
+	WebUrl       string `json:"web_url"`
+	State        string `json:"state"`
+	Draft        bool   `json:"draft"`
+	HasConflicts bool   `json:"has_conflicts"`
+	HeadPipeline *struct {
+		Status string `json:"status"`
+	} `json:"head_pipeline"`
+}
+
+type gitLabApprovals struct {
+	Approved      bool `json:"approved"`
+	ApprovalsLeft int  `json:"approvals_left"`
+	ApprovedBy    []struct {
+		User struct {
+			Username string `json:"username"`
+		} `json:"user"`
+	} `json:"approved_by"`
+}
+
+type GitLab struct {
+	unsupportedFeatures
+}
+
+func (GitLab) CreatePr(ctx context.Context, settings *Settings, head, title, description string) (string, error) {
+	mrFlags := []string{
+		"mr", "create",
+		"--source-branch", head,
+		"--target-branch", settings.MainBranch,
+		"--title", title,
+		"--description", description,
+		"--yes",
+	}
+	if settings.IsDraftPrs {
+		mrFlags = append(mrFlags, "--draft")
+	}
+
+	_, err := runCmd(ctx, "glab", mrFlags...)
+	if err != nil {
+		return "", err
+	}
+
+	mr, err := gitLabFindMr(ctx, head, false)
+	if err != nil {
+		return "", err
+	}
+	if mr == nil {
+		return "", fmt.Errorf("created MR not found for branch '%s'", head)
+	}
+	return mr.WebUrl, nil
+}
+
+func (GitLab) ClosePr(ctx context.Context, _ *Settings, sourceBranch string) error {
+	mr, err := gitLabFindMr(ctx, sourceBranch, false)
+	if err != nil || mr == nil {
+		return err
+	}
+
+	_, err = runCmd(ctx, "glab", "mr", "close", strconv.Itoa(mr.Iid))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Returns an empty url when the branch has no open MR
+func (GitLab) FindPr(ctx context.Context, _ *Settings, sourceBranch string) (string, error) {
+	mr, err := gitLabFindMr(ctx, sourceBranch, false)
+	if err != nil || mr == nil {
+		return "", err
+	}
+	return mr.WebUrl, nil
+}
+
+func (GitLab) UpdatePr(ctx context.Context, _ *Settings, sourceBranch, title, description string) error {
+	mr, err := gitLabFindMr(ctx, sourceBranch, false)
+	if err != nil {
+		return err
+	}
+	if mr == nil {
+		return fmt.Errorf("no open MR for branch '%s'", sourceBranch)
+	}
+
+	_, err = runCmd(ctx, "glab", "mr", "update", strconv.Itoa(mr.Iid),
+		"--title", title,
+		"--description", description,
+		"--yes")
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (GitLab) PrStatus(ctx context.Context, _ *Settings, sourceBranch string) (*PrStatus, error) {
+	log := LoggerFromContext(ctx)
+
+	mr, err := gitLabFindMr(ctx, sourceBranch, true)
+	if err != nil {
+		return nil, err
+	}
+	if mr == nil {
+		return noPrStatus(), nil
+	}
+
+	// The list does not contain the pipeline
+	resp, err := runCmd(ctx, "glab", "mr", "view", strconv.Itoa(mr.Iid), "--output", "json")
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(resp, mr); err != nil {
+		log.Error("failed to unmarshal the MR", "error", err)
+		return nil, err
+	}
+
+	resp, err = runCmd(ctx, "glab", "api", fmt.Sprintf("projects/:id/merge_requests/%d/approvals", mr.Iid))
+	if err != nil {
+		return nil, err
+	}
+	var approvals gitLabApprovals
+	if err := json.Unmarshal(resp, &approvals); err != nil {
+		log.Error("failed to unmarshal the MR approvals", "error", err)
+		return nil, err
+	}
+
+	return mr.toPrStatus(approvals), nil
+}
+
+func (mr GitLabMr) toPrStatus(approvals gitLabApprovals) *PrStatus {
+	status := noPrStatus()
+	status.Url = mr.WebUrl
+
+	switch {
+	case mr.State == "merged":
+		status.State = PrStateMerged
+	case mr.State == "closed" || mr.State == "locked":
+		status.State = PrStateAbandoned
+	case mr.Draft:
+		status.State = PrStateDraft
+	default:
+		status.State = PrStateOpen
+	}
+
+	switch {
+	case approvals.Approved && len(approvals.ApprovedBy) > 0:
+		status.Review = ReviewApproved
+	case approvals.ApprovalsLeft > 0:
+		status.Review = ReviewRequired
+	}
+
+	if mr.HeadPipeline != nil {
+		switch mr.HeadPipeline.Status {
+		case "success":
+			status.Checks = ChecksSuccess
+		case "failed", "canceled":
+			status.Checks = ChecksFailure
+		case "":
+		default:
+			status.Checks = ChecksPending
+		}
+	}
+
+	if mr.State == "opened" {
+		if mr.HasConflicts {
+			status.Mergeable = MergeableConflicting
+		} else {
+			status.Mergeable = MergeableYes
+		}
+	}
+	return status
+}
+
+// Only opened MRs are returned unless `all` is set
+func gitLabFindMr(ctx context.Context, sourceBranch string, all bool) (*GitLabMr, error) {
+	listFlags := []string{
+		"mr", "list",
+		"--source-branch", sourceBranch,
+		"--per-page", "1",
+		"--output", "json",
+	}
+	if all {
+		listFlags = append(listFlags, "--all")
+	}
+
+	resp, err := runCmd(ctx, "glab", listFlags...)
+	if err != nil {
+		return nil, err
+	}
+
+	var mrs []GitLabMr
+	if err := json.Unmarshal(resp, &mrs); err != nil {
+		log := LoggerFromContext(ctx)
+		log.Error("failed to unmarshal the MR list", "error", err)
+		return nil, err
+	}
+
+	if len(mrs) < 1 {
+		return nil, nil
+	}
+	return &mrs[0], nil
+}
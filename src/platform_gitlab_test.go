@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var gitLabPrStatusTests = []struct {
+	description    string
+	rawMr          string
+	rawApprovals   string
+	expectedStatus *PrStatus
+}{
+	{
+		description: "Opened MR approved with a passing pipeline",
+		rawMr: `{"iid": 1, "web_url": "https://gitlab.com/g/p/-/merge_requests/1", "state": "opened",
+			"draft": false, "has_conflicts": false, "head_pipeline": {"status": "success"}}`,
+		rawApprovals: `{"approved": true, "approvals_left": 0, "approved_by": [{"user": {"username": "alice"}}]}`,
+		expectedStatus: &PrStatus{
+			Url:       "https://gitlab.com/g/p/-/merge_requests/1",
+			State:     PrStateOpen,
+			Review:    ReviewApproved,
+			Checks:    ChecksSuccess,
+			Mergeable: MergeableYes,
+		},
+	},
+	{
+		description: "Draft MR with conflicts and a running pipeline",
+		rawMr: `{"iid": 2, "web_url": "https://gitlab.com/g/p/-/merge_requests/2", "state": "opened",
+			"draft": true, "has_conflicts": true, "head_pipeline": {"status": "running"}}`,
+		rawApprovals: `{"approved": false, "approvals_left": 1, "approved_by": []}`,
+		expectedStatus: &PrStatus{
+			Url:       "https://gitlab.com/g/p/-/merge_requests/2",
+			State:     PrStateDraft,
+			Review:    ReviewRequired,
+			Checks:    ChecksPending,
+			Mergeable: MergeableConflicting,
+		},
+	},
+	{
+		description:  "Closed MR without pipeline",
+		rawMr:        `{"iid": 3, "web_url": "https://gitlab.com/g/p/-/merge_requests/3", "state": "closed"}`,
+		rawApprovals: `{"approved": true, "approvals_left": 0, "approved_by": []}`,
+		expectedStatus: &PrStatus{
+			Url:       "https://gitlab.com/g/p/-/merge_requests/3",
+			State:     PrStateAbandoned,
+			Review:    ReviewNone,
+			Checks:    ChecksNone,
+			Mergeable: MergeableUnknown,
+		},
+	},
+}
+
+func TestGitLabPrStatus(t *testing.T) {
+	for _, tt := range gitLabPrStatusTests {
+		t.Run(tt.description, func(t *testing.T) {
+			var mr GitLabMr
+			if err := json.Unmarshal([]byte(tt.rawMr), &mr); err != nil {
+				t.Fatal(err)
+			}
+			var approvals gitLabApprovals
+			if err := json.Unmarshal([]byte(tt.rawApprovals), &approvals); err != nil {
+				t.Fatal(err)
+			}
+
+			diff := cmp.Diff(mr.toPrStatus(approvals), tt.expectedStatus)
+			if diff != "" {
+				t.Errorf("%v", diff)
+			}
+		})
+	}
+}
+
+// Answers the MR lookups of bit-dom1 and records the arguments next to itself
+const fixtureGlabScript = `#!/bin/sh
+echo "$*" >> "$0.calls"
+case "$*" in
+  "mr list --source-branch bit-dom1 "*) echo '[{"iid": 4, "web_url": "https://gitlab.com/g/p/-/merge_requests/4", "state": "opened"}]' ;;
+  "mr list "*) echo '[]' ;;
+  "mr create "*) echo 'Creating merge request for bit-dom1 into main in g/p'; echo 'https://gitlab.com/g/p/-/merge_requests/4' ;;
+  "mr close 4") echo 'Closed merge request !4' ;;
+  *) exit 1 ;;
+esac
+`
+
+// Installs a fake `glab` in a temporary folder of the PATH
+func fixtureGlab(t *testing.T) string {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "glab")
+	if err := os.WriteFile(path, []byte(fixtureGlabScript), 0755); err != nil {
+		t.Fatalf("failed to write the fake glab: %v", err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return path
+}
+
+const gitLabFindMrCall = "mr list --source-branch bit-dom1 --per-page 1 --output json"
+
+var gitLabCommandsTests = []struct {
+	description   string
+	call          func(context.Context, *Settings) (string, error)
+	expectedUrl   string
+	expectedCalls []string
+}{
+	{
+		description: "Create a MR and return its url",
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			return GitLab{}.CreatePr(ctx, settings, "bit-dom1", "dom1: Big change", "Split of the big change")
+		},
+		expectedUrl: "https://gitlab.com/g/p/-/merge_requests/4",
+		expectedCalls: []string{
+			"mr create --source-branch bit-dom1 --target-branch main --title dom1: Big change --description Split of the big change --yes",
+			gitLabFindMrCall,
+		},
+	},
+	{
+		description: "Create a draft MR",
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			settings.IsDraftPrs = true
+			return GitLab{}.CreatePr(ctx, settings, "bit-dom1", "dom1: Big change", "Split of the big change")
+		},
+		expectedUrl: "https://gitlab.com/g/p/-/merge_requests/4",
+		expectedCalls: []string{
+			"mr create --source-branch bit-dom1 --target-branch main --title dom1: Big change --description Split of the big change --yes --draft",
+			gitLabFindMrCall,
+		},
+	},
+	{
+		description: "Find the open MR of a branch",
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			return GitLab{}.FindPr(ctx, settings, "bit-dom1")
+		},
+		expectedUrl:   "https://gitlab.com/g/p/-/merge_requests/4",
+		expectedCalls: []string{gitLabFindMrCall},
+	},
+	{
+		description: "Find no MR for a branch without one",
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			return GitLab{}.FindPr(ctx, settings, "bit-dom2")
+		},
+		expectedCalls: []string{"mr list --source-branch bit-dom2 --per-page 1 --output json"},
+	},
+	{
+		description: "Close the open MR of a branch by its iid",
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			return "", GitLab{}.ClosePr(ctx, settings, "bit-dom1")
+		},
+		expectedCalls: []string{gitLabFindMrCall, "mr close 4"},
+	},
+	{
+		description: "Closing a branch without MR does nothing",
+		call: func(ctx context.Context, settings *Settings) (string, error) {
+			return "", GitLab{}.ClosePr(ctx, settings, "bit-dom2")
+		},
+		expectedCalls: []string{"mr list --source-branch bit-dom2 --per-page 1 --output json"},
+	},
+}
+
+func TestGitLabCommands(t *testing.T) {
+	ctxWithSilentLogger := ContextWithSilentLogger(context.Background())
+
+	for _, tt := range gitLabCommandsTests {
+		t.Run(tt.description, func(t *testing.T) {
+			glabPath := fixtureGlab(t)
+
+			gotUrl, err := tt.call(ctxWithSilentLogger, fixtureBigChange().Settings)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gotUrl != tt.expectedUrl {
+				t.Errorf("got url '%s', want '%s'", gotUrl, tt.expectedUrl)
+			}
+
+			rawCalls, err := os.ReadFile(glabPath + ".calls")
+			if err != nil {
+				t.Fatal(err)
+			}
+			diff := cmp.Diff(strings.Split(strings.TrimSpace(string(rawCalls)), "\n"), tt.expectedCalls)
+			if diff != "" {
+				t.Errorf("%v", diff)
+			}
+		})
+	}
+}
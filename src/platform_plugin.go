@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// External platforms are executables named `bit-platform-<name>` found in the PATH
+const pluginExecutablePrefix = "bit-platform-"
+
+// Increased on breaking changes of PluginRequest or PluginResponse
+const pluginProtocolVersion = 1
+
+type PluginOperation string
+
+const (
+	PluginCreatePr        PluginOperation = "create_pr"
+	PluginFindPr          PluginOperation = "find_pr"
+	PluginUpdatePr        PluginOperation = "update_pr"
+	PluginAbandonPr       PluginOperation = "abandon_pr"
+	PluginPrStatus        PluginOperation = "pr_status"
+	PluginAddReviewers    PluginOperation = "add_reviewers"
+	PluginAddLabels       PluginOperation = "add_labels"
+	PluginSetMilestone    PluginOperation = "set_milestone"
+	PluginLinkWorkItems   PluginOperation = "link_work_items"
+	PluginEnableAutoMerge PluginOperation = "enable_auto_merge"
+	PluginCreateIssue     PluginOperation = "create_issue"
+	PluginFindIssue       PluginOperation = "find_issue"
+	PluginUpdateIssue     PluginOperation = "update_issue"
+)
+
+// Written as JSON on the standard input of the plugin, one request per execution
+type PluginRequest struct {
+	Version   int             `json:"version"`
+	Operation PluginOperation `json:"operation"`
+	Settings  *Settings       `json:"settings"`
+	Branch    string          `json:"branch"`
+	Title     string          `json:"title,omitempty"`
+	Body      string          `json:"body,omitempty"`
+	Reviewers []string        `json:"reviewers,omitempty"`
+	Labels    []string        `json:"labels,omitempty"`
+	Milestone string          `json:"milestone,omitempty"`
+	WorkItems []int           `json:"workItems,omitempty"`
+	AutoMerge *AutoMerge      `json:"autoMerge,omitempty"`
+	// Issue to update, the branch is empty for the issue operations
+	Url string `json:"url,omitempty"`
+}
+
+// Read as JSON from the standard output of the plugin:
+//   - create_pr: `url` of the created PR
+//   - find_pr: `url` of the open PR of the branch, empty when there is none
+//   - update_pr, add_reviewers, add_labels, set_milestone, link_work_items and
+//     enable_auto_merge: nothing
+//   - abandon_pr: nothing, abandoning a branch without PR is not an error
+//   - pr_status: `status`, omitted fields are reported as unknown
+//   - create_issue: `url` of the created issue
+//   - find_issue: `url` of the open issue with this exact title, empty when there is none
+//   - update_issue: nothing
+//
+// A non empty `error` or a non zero exit code fails the operation
+type PluginResponse struct {
+	Url    string    `json:"url"`
+	Status *PrStatus `json:"status"`
+	Error  string    `json:"error"`
+}
+
+type PlatformPlugin struct {
+	Name string
+}
+
+func pluginExecutable(name string) string {
+	return pluginExecutablePrefix + name
+}
+
+func (plugin PlatformPlugin) CreatePr(ctx context.Context, settings *Settings, head, title, body string) (string, error) {
+	resp, err := plugin.call(ctx, &PluginRequest{
+		Operation: PluginCreatePr,
+		Settings:  settings,
+		Branch:    head,
+		Title:     title,
+		Body:      body,
+	})
+	if err != nil {
+		return "", err
+	}
+	if resp.Url == "" {
+		return "", fmt.Errorf("platform plugin '%s' returned no url for branch '%s'", plugin.Name, head)
+	}
+	return resp.Url, nil
+}
+
+func (plugin PlatformPlugin) ClosePr(ctx context.Context, settings *Settings, head string) error {
+	_, err := plugin.call(ctx, &PluginRequest{
+		Operation: PluginAbandonPr,
+		Settings:  settings,
+		Branch:    head,
+	})
+	return err
+}
+
+// Returns an empty url when the branch has no open PR
+func (plugin PlatformPlugin) FindPr(ctx context.Context, settings *Settings, head string) (string, error) {
+	resp, err := plugin.call(ctx, &PluginRequest{
+		Operation: PluginFindPr,
+		Settings:  settings,
+		Branch:    head,
+	})
+	if err != nil {
+		return "", err
+	}
+	return resp.Url, nil
+}
+
+func (plugin PlatformPlugin) UpdatePr(ctx context.Context, settings *Settings, head, title, body string) error {
+	_, err := plugin.call(ctx, &PluginRequest{
+		Operation: PluginUpdatePr,
+		Settings:  settings,
+		Branch:    head,
+		Title:     title,
+		Body:      body,
+	})
+	return err
+}
+
+func (plugin PlatformPlugin) AddReviewers(ctx context.Context, settings *Settings, head string, reviewers []string) error {
+	_, err := plugin.call(ctx, &PluginRequest{
+		Operation: PluginAddReviewers,
+		Settings:  settings,
+		Branch:    head,
+		Reviewers: reviewers,
+	})
+	return err
+}
+
+func (plugin PlatformPlugin) AddLabels(ctx context.Context, settings *Settings, head string, labels []string) error {
+	_, err := plugin.call(ctx, &PluginRequest{
+		Operation: PluginAddLabels,
+		Settings:  settings,
+		Branch:    head,
+		Labels:    labels,
+	})
+	return err
+}
+
+func (plugin PlatformPlugin) SetMilestone(ctx context.Context, settings *Settings, head string, milestone string) error {
+	_, err := plugin.call(ctx, &PluginRequest{
+		Operation: PluginSetMilestone,
+		Settings:  settings,
+		Branch:    head,
+		Milestone: milestone,
+	})
+	return err
+}
+
+func (plugin PlatformPlugin) LinkWorkItems(ctx context.Context, settings *Settings, head string, workItems []int) error {
+	_, err := plugin.call(ctx, &PluginRequest{
+		Operation: PluginLinkWorkItems,
+		Settings:  settings,
+		Branch:    head,
+		WorkItems: workItems,
+	})
+	return err
+}
+
+func (plugin PlatformPlugin) EnableAutoMerge(ctx context.Context, settings *Settings, head string, autoMerge *AutoMerge) error {
+	_, err := plugin.call(ctx, &PluginRequest{
+		Operation: PluginEnableAutoMerge,
+		Settings:  settings,
+		Branch:    head,
+		AutoMerge: autoMerge,
+	})
+	return err
+}
+
+func (plugin PlatformPlugin) CreateIssue(ctx context.Context, settings *Settings, title, body string) (string, error) {
+	resp, err := plugin.call(ctx, &PluginRequest{
+		Operation: PluginCreateIssue,
+		Settings:  settings,
+		Title:     title,
+		Body:      body,
+	})
+	if err != nil {
+		return "", err
+	}
+	if resp.Url == "" {
+		return "", fmt.Errorf("platform plugin '%s' returned no url for issue '%s'", plugin.Name, title)
+	}
+	return resp.Url, nil
+}
+
+// Returns an empty url when no open issue has exactly this title
+func (plugin PlatformPlugin) FindIssue(ctx context.Context, settings *Settings, title string) (string, error) {
+	resp, err := plugin.call(ctx, &PluginRequest{
+		Operation: PluginFindIssue,
+		Settings:  settings,
+		Title:     title,
+	})
+	if err != nil {
+		return "", err
+	}
+	return resp.Url, nil
+}
+
+func (plugin PlatformPlugin) UpdateIssue(ctx context.Context, settings *Settings, issueUrl, title, body string) error {
+	_, err := plugin.call(ctx, &PluginRequest{
+		Operation: PluginUpdateIssue,
+		Settings:  settings,
+		Title:     title,
+		Body:      body,
+		Url:       issueUrl,
+	})
+	return err
+}
+
+func (plugin PlatformPlugin) PrStatus(ctx context.Context, settings *Settings, head string) (*PrStatus, error) {
+	resp, err := plugin.call(ctx, &PluginRequest{
+		Operation: PluginPrStatus,
+		Settings:  settings,
+		Branch:    head,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	status := noPrStatus()
+	if resp.Status == nil {
+		return status, nil
+	}
+	status.Url = resp.Status.Url
+	if resp.Status.State != "" {
+		status.State = resp.Status.State
+	}
+	if resp.Status.Review != "" {
+		status.Review = resp.Status.Review
+	}
+	if resp.Status.Checks != "" {
+		status.Checks = resp.Status.Checks
+	}
+	if resp.Status.Mergeable != "" {
+		status.Mergeable = resp.Status.Mergeable
+	}
+	return status, nil
+}
+
+func (plugin PlatformPlugin) call(ctx context.Context, request *PluginRequest) (*PluginResponse, error) {
+	log := LoggerFromContext(ctx)
+	request.Version = pluginProtocolVersion
+
+	rawRequest, err := json.Marshal(request)
+	if err != nil {
+		log.Error("failed to marshal the plugin request", "plugin", plugin.Name, "error", err)
+		return nil, err
+	}
+
+	rawResp, err := runCmdWithInput(ctx, rawRequest, pluginExecutable(plugin.Name))
+	if err != nil {
+		return nil, err
+	}
+
+	var resp PluginResponse
+	if err := json.Unmarshal(rawResp, &resp); err != nil {
+		log.Error("failed to unmarshal the plugin response",
+			"plugin", plugin.Name,
+			"operation", request.Operation,
+			"error", err)
+		return nil, err
+	}
+	if resp.Error != "" {
+		log.Error("platform plugin failed",
+			"plugin", plugin.Name,
+			"operation", request.Operation,
+			"branch", request.Branch,
+			"error", resp.Error)
+		return nil, fmt.Errorf("platform plugin '%s' failed to %s: %s", plugin.Name, request.Operation, resp.Error)
+	}
+	return &resp, nil
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// Answers depending on the operation and records the requests next to itself
+const fixturePluginScript = `#!/bin/sh
+request=$(cat)
+printf '%s\n' "$request" >> "$0.requests"
+case "$request" in
+  *'"operation":"create_pr"'*) echo '{"url": "https://review.example.com/1"}' ;;
+  *'"operation":"find_pr"'*) echo '{"url": ""}' ;;
+  *'"operation":"pr_status"'*) echo '{"status": {"url": "https://review.example.com/1", "state": "open", "review": "approved"}}' ;;
+  *'"operation":"update_pr"'*) echo '{"error": "update not supported"}' ;;
+  *) echo 'crashed' >&2; exit 3 ;;
+esac
+`
+
+// Installs the `test` plugin in a temporary folder of the PATH
+func fixturePlugin(t *testing.T) string {
+	dir := t.TempDir()
+	path := filepath.Join(dir, pluginExecutable("test"))
+	if err := os.WriteFile(path, []byte(fixturePluginScript), 0755); err != nil {
+		t.Fatalf("failed to write the test plugin: %v", err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return path
+}
+
+var pluginTests = []struct {
+	description     string
+	call            func(ctx context.Context, plugin PlatformPlugin, settings *Settings) (string, error)
+	expectedResult  string
+	expectedRequest *PluginRequest
+	expectedErr     error
+}{
+	{
+		description: "Create PR",
+		call: func(ctx context.Context, plugin PlatformPlugin, settings *Settings) (string, error) {
+			return plugin.CreatePr(ctx, settings, "bit-dom1", "title", "body")
+		},
+		expectedResult: "https://review.example.com/1",
+		expectedRequest: &PluginRequest{
+			Version:   pluginProtocolVersion,
+			Operation: PluginCreatePr,
+			Settings:  fixtureBigChange().Settings,
+			Branch:    "bit-dom1",
+			Title:     "title",
+			Body:      "body",
+		},
+	},
+	{
+		description: "Find PR without result",
+		call: func(ctx context.Context, plugin PlatformPlugin, settings *Settings) (string, error) {
+			return plugin.FindPr(ctx, settings, "bit-dom1")
+		},
+		expectedRequest: &PluginRequest{
+			Version:   pluginProtocolVersion,
+			Operation: PluginFindPr,
+			Settings:  fixtureBigChange().Settings,
+			Branch:    "bit-dom1",
+		},
+	},
+	{
+		description: "PR status with default values",
+		call: func(ctx context.Context, plugin PlatformPlugin, settings *Settings) (string, error) {
+			status, err := plugin.PrStatus(ctx, settings, "bit-dom1")
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("%s %s %s %s %s", status.Url, status.State, status.Review, status.Checks, status.Mergeable), nil
+		},
+		expectedResult: "https://review.example.com/1 open approved none unknown",
+		expectedRequest: &PluginRequest{
+			Version:   pluginProtocolVersion,
+			Operation: PluginPrStatus,
+			Settings:  fixtureBigChange().Settings,
+			Branch:    "bit-dom1",
+		},
+	},
+	{
+		description: "Fail on error in the response",
+		call: func(ctx context.Context, plugin PlatformPlugin, settings *Settings) (string, error) {
+			return "", plugin.UpdatePr(ctx, settings, "bit-dom1", "title", "body")
+		},
+		expectedRequest: &PluginRequest{
+			Version:   pluginProtocolVersion,
+			Operation: PluginUpdatePr,
+			Settings:  fixtureBigChange().Settings,
+			Branch:    "bit-dom1",
+			Title:     "title",
+			Body:      "body",
+		},
+		expectedErr: fmt.Errorf("platform plugin 'test' failed to update_pr: update not supported"),
+	},
+	{
+		description: "Fail on plugin exit code",
+		call: func(ctx context.Context, plugin PlatformPlugin, settings *Settings) (string, error) {
+			return "", plugin.ClosePr(ctx, settings, "bit-dom1")
+		},
+		expectedRequest: &PluginRequest{
+			Version:   pluginProtocolVersion,
+			Operation: PluginAbandonPr,
+			Settings:  fixtureBigChange().Settings,
+			Branch:    "bit-dom1",
+		},
+		expectedErr: fmt.Errorf("exit status 3"),
+	},
+}
+
+func TestPlatformPlugin(t *testing.T) {
+	ctxWithSilentLogger := ContextWithSilentLogger(context.Background())
+
+	for _, tt := range pluginTests {
+		t.Run(tt.description, func(t *testing.T) {
+			pluginPath := fixturePlugin(t)
+
+			gotResult, gotErr := tt.call(ctxWithSilentLogger, PlatformPlugin{Name: "test"}, fixtureBigChange().Settings)
+
+			// We get an error when we don't expect it or we don't get one when we expect it
+			if tt.expectedErr != nil != (gotErr != nil) {
+				t.Errorf("got '%v', want '%v'", gotErr, tt.expectedErr)
+			}
+			// We get a different error of what's expected
+			if tt.expectedErr != nil && gotErr != nil &&
+				tt.expectedErr.Error() != gotErr.Error() {
+				t.Errorf("got '%v', want '%v'", gotErr, tt.expectedErr)
+			}
+
+			diff := cmp.Diff(gotResult, tt.expectedResult)
+			if diff != "" {
+				t.Errorf("%v", diff)
+			}
+
+			rawRequests, err := os.ReadFile(pluginPath + ".requests")
+			if err != nil {
+				t.Fatalf("plugin not called: %v", err)
+			}
+			var gotRequest PluginRequest
+			if err := json.Unmarshal([]byte(strings.TrimSpace(string(rawRequests))), &gotRequest); err != nil {
+				t.Fatalf("invalid plugin request: %v", err)
+			}
+			diff = cmp.Diff(&gotRequest, tt.expectedRequest)
+			if diff != "" {
+				t.Errorf("%v", diff)
+			}
+		})
+	}
+}
+
+func TestGetFlagsPlugin(t *testing.T) {
+	fixturePlugin(t)
+
+	gotFlags, gotErr := getFlags("bit", []string{"-p", "test"})
+	if gotErr != nil {
+		t.Fatalf("got '%v', want no error", gotErr)
+	}
+
+	diff := cmp.Diff(gotFlags, fixtureFlags(func(f *Flags) {
+		f.Platform = "test"
+	}))
+	if diff != "" {
+		t.Errorf("%v", diff)
+	}
+}
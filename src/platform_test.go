@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+var getPlatformTests = []struct {
+	description        string
+	name               string
+	expectedPlatform   Platform
+	expectedBranchless bool
+	expectedErr        error
+}{
+	{
+		description:      "Built-in platform",
+		name:             "azure-api",
+		expectedPlatform: AzureApi{},
+	},
+	{
+		description:      "Alias of a built-in platform",
+		name:             "forgejo",
+		expectedPlatform: Gitea{},
+	},
+	{
+		description:        "Branchless platform",
+		name:               "gerrit",
+		expectedPlatform:   Gerrit{},
+		expectedBranchless: true,
+	},
+	{
+		description:      "Fail on unknown platform",
+		name:             "invalidPlatform",
+		expectedPlatform: nil,
+		expectedErr:      errors.New("platform 'invalidPlatform' is not supported"),
+	},
+}
+
+func TestGetPlatform(t *testing.T) {
+	for _, tt := range getPlatformTests {
+		t.Run(tt.description, func(t *testing.T) {
+			gotPlatform, gotErr := GetPlatform(tt.name)
+
+			if gotPlatform != tt.expectedPlatform {
+				t.Errorf("got '%#v', want '%#v'", gotPlatform, tt.expectedPlatform)
+			}
+
+			if gotPlatform != nil && isBranchless(gotPlatform) != tt.expectedBranchless {
+				t.Errorf("got branchless '%v', want '%v'", isBranchless(gotPlatform), tt.expectedBranchless)
+			}
+
+			if tt.expectedErr != nil != (gotErr != nil) {
+				t.Errorf("got '%v', want '%v'", gotErr, tt.expectedErr)
+			}
+		})
+	}
+}
+
+var unsupportedFeaturesTests = []struct {
+	description     string
+	platform        string
+	call            func(ctx context.Context, platform Platform, settings *Settings) error
+	expectedFeature string
+}{
+	{
+		description: "Labels on Gitea",
+		platform:    "gitea",
+		call: func(ctx context.Context, platform Platform, settings *Settings) error {
+			return platform.AddLabels(ctx, settings, "bit-dom1", []string{"label"})
+		},
+		expectedFeature: "labels",
+	},
+	{
+		description: "Milestone on Azure DevOps",
+		platform:    "azure",
+		call: func(ctx context.Context, platform Platform, settings *Settings) error {
+			return platform.SetMilestone(ctx, settings, "bit-dom1", "v1")
+		},
+		expectedFeature: "milestone",
+	},
+	{
+		description: "Work items on GitHub",
+		platform:    "github-api",
+		call: func(ctx context.Context, platform Platform, settings *Settings) error {
+			return platform.LinkWorkItems(ctx, settings, "bit-dom1", []int{1})
+		},
+		expectedFeature: "work items",
+	},
+	{
+		description: "Reviewers on Gerrit",
+		platform:    "gerrit",
+		call: func(ctx context.Context, platform Platform, settings *Settings) error {
+			return platform.AddReviewers(ctx, settings, "bit-dom1", []string{"alice"})
+		},
+		expectedFeature: "reviewers",
+	},
+	{
+		description: "Rebase auto-merge with the Azure CLI",
+		platform:    "azure",
+		call: func(ctx context.Context, platform Platform, settings *Settings) error {
+			return platform.EnableAutoMerge(ctx, settings, "bit-dom1", &AutoMerge{Method: MergeMethodRebase})
+		},
+		expectedFeature: "rebase auto-merge",
+	},
+	{
+		description: "Tracking issues on Azure DevOps",
+		platform:    "azure-api",
+		call: func(ctx context.Context, platform Platform, settings *Settings) error {
+			_, err := platform.CreateIssue(ctx, settings, "title", "body")
+			return err
+		},
+		expectedFeature: "issues",
+	},
+	{
+		description: "Required reviewers on GitHub",
+		platform:    "github",
+		call: func(ctx context.Context, platform Platform, settings *Settings) error {
+			settings.RequiredReviewers = true
+			return platform.AddReviewers(ctx, settings, "bit-dom1", []string{"alice"})
+		},
+		expectedFeature: "required reviewers",
+	},
+}
+
+func TestUnsupportedFeatures(t *testing.T) {
+	ctxWithSilentLogger := ContextWithSilentLogger(context.Background())
+	for _, tt := range unsupportedFeaturesTests {
+		t.Run(tt.description, func(t *testing.T) {
+			err := tt.call(ctxWithSilentLogger, platforms[tt.platform], fixtureBigChange().Settings)
+
+			var unsupportedErr *UnsupportedFeatureError
+			if !errors.As(err, &unsupportedErr) {
+				t.Fatalf("got '%v', want an UnsupportedFeatureError", err)
+			}
+			if unsupportedErr.Feature != tt.expectedFeature {
+				t.Errorf("got feature '%s', want '%s'", unsupportedErr.Feature, tt.expectedFeature)
+			}
+		})
+	}
+}
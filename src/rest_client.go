@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Minimal JSON client shared by the platforms talking to a REST API
+type restClient struct {
+	baseUrl    string
+	authorize  func(*http.Request)
+	httpClient *http.Client
+	// Sent with every request, they override the default ones
+	headers map[string]string
+	// Stripped from the responses before decoding them, e.g. the Gerrit XSSI protection
+	responsePrefix string
+}
+
+type restError struct {
+	Method     string
+	Url        string
+	StatusCode int
+	Body       string
+}
+
+func (e *restError) Error() string {
+	return fmt.Sprintf("%s %s: status %d: %s", e.Method, e.Url, e.StatusCode, e.Body)
+}
+
+func newRestClient(baseUrl string, authorize func(*http.Request)) *restClient {
+	return &restClient{
+		baseUrl:    strings.TrimSuffix(baseUrl, "/"),
+		authorize:  authorize,
+		httpClient: &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+func bearerAuth(token string) func(*http.Request) {
+	return func(req *http.Request) {
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+}
+
+func basicAuth(username string, password string) func(*http.Request) {
+	return func(req *http.Request) {
+		req.SetBasicAuth(username, password)
+	}
+}
+
+// `reqBody` and `respBody` are JSON encoded/decoded when not nil
+func (c *restClient) do(ctx context.Context, method string, path string, reqBody any, respBody any) error {
+	log := LoggerFromContext(ctx)
+	url := c.baseUrl + path
+
+	var body io.Reader
+	if reqBody != nil {
+		rawBody, err := json.Marshal(reqBody)
+		if err != nil {
+			log.Error("failed to marshal request", "method", method, "url", url, "error", err)
+			return err
+		}
+		body = bytes.NewReader(rawBody)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		log.Error("failed to create request", "method", method, "url", url, "error", err)
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if reqBody != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for name, value := range c.headers {
+		req.Header.Set(name, value)
+	}
+	if c.authorize != nil {
+		c.authorize(req)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		log.Error("failed to call API", "method", method, "url", url, "error", err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	rawResp, err := io.ReadAll(resp.Body)
+	if err != nil {
+		log.Error("failed to read API response", "method", method, "url", url, "error", err)
+		return err
+	}
+
+	if resp.StatusCode >= 300 {
+		err := &restError{Method: method, Url: url, StatusCode: resp.StatusCode, Body: string(rawResp[:])}
+		log.Error("failed to call API",
+			"method", method,
+			"url", url,
+			"status", resp.StatusCode,
+			"output", string(rawResp[:]),
+			"error", err)
+		return err
+	}
+	log.Debug("call API",
+		"method", method,
+		"url", url,
+		"status", resp.StatusCode,
+		"output", string(rawResp[:]))
+
+	rawResp = bytes.TrimPrefix(rawResp, []byte(c.responsePrefix))
+	if respBody != nil && len(bytes.TrimSpace(rawResp)) > 0 {
+		if err := json.Unmarshal(rawResp, respBody); err != nil {
+			log.Error("failed to unmarshal API response", "method", method, "url", url, "error", err)
+			return err
+		}
+	}
+	return nil
+}
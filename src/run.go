@@ -2,7 +2,8 @@ package main
 
 import (
 	"context"
-	"strings"
+	"errors"
+	"fmt"
 
 	"golang.org/x/sync/errgroup"
 )
@@ -20,9 +21,9 @@ func (bit *BigIsTiny) run(ctx context.Context, config *BigChange) (err error) {
 		// }
 	}
 
-	// On cleanup or failure remove the branches and PRs created during the split
+	// On cleanup remove the branches and PRs created during the split
 	defer func() {
-		if bit.flags.Cleanup || err != nil {
+		if bit.flags.Cleanup {
 			bit.cleanup(ctx, config)
 		}
 	}()
@@ -31,51 +32,77 @@ func (bit *BigIsTiny) run(ctx context.Context, config *BigChange) (err error) {
 		return nil
 	}
 
-	// Checkout to the main branch
-	err = bit.gitOps.gitCheckout(ctx, config.Settings.MainBranch)
+	state, err := bit.loadRunState(ctx, config)
 	if err != nil {
 		return err
 	}
 
-	// Fetch the files on remote branch for the changes we are working on
-	err = bit.gitOps.gitCheckoutFiles(ctx, config.Settings.Remote, config.Settings.BranchToSplit, bit.flags.AllowDeletions)
-	if err != nil {
-		return err
-	}
+	// Completed steps are kept on failure so the run can be resumed
+	defer func() {
+		if err != nil {
+			log := LoggerFromContext(ctx)
+			log.Error("run failed, use -resume to continue from the last completed step or -cleanup to remove the created branches and PRs")
+		}
+	}()
 
-	// Un-stage the files added from the big change branch
-	err = bit.gitOps.gitReset(ctx)
+	err = bit.collectChanges(ctx, config, config.Settings)
 	if err != nil {
 		return err
 	}
 
-	// Get a list of all touched files
-	changedFiles, err := bit.listChangedFiles(ctx)
-	if err != nil {
-		return err
+	if config.Settings.Tracking != nil {
+		err = bit.openTracking(ctx, config)
+		if err != nil {
+			return err
+		}
 	}
 
 	errGrp := new(errgroup.Group)
 	for _, domain := range config.Domains {
-		if !fileChangedInDomain(domain.Path, changedFiles) {
+		if len(domain.Files) == 0 {
 			continue
 		}
 
-		err = bit.createBranch(ctx, config, domain, config.Settings)
-		if err != nil {
-			return err
-		}
-
-		errGrp.Go(func() error {
-			err = bit.gitOps.gitPushSetUpstream(ctx, config.Settings.Remote, domain.Branch.Name)
+		domainState := state.domain(domain.Branch.Name)
+		if domainState.CommitSha == "" {
+			err = bit.createBranch(ctx, config, domain, config.Settings, bit.gitOps.gitCreateBranch)
+			if errors.Is(err, ErrNoChanges) {
+				skipDomain(ctx, domain)
+				continue
+			}
 			if err != nil {
 				return err
 			}
 
-			domain.PullRequest.Url, err = bit.createPullRequest(ctx, domain, config.Settings)
+			commitSha, err := bit.gitOps.gitRevParse(ctx, domain.Branch.Name)
 			if err != nil {
 				return err
 			}
+			err = bit.recordStep(ctx, state, domain.Branch.Name, func(ds *DomainState) { ds.CommitSha = commitSha })
+			if err != nil {
+				return err
+			}
+		}
+
+		errGrp.Go(func() error {
+			if !domainState.Pushed {
+				err := bit.gitOps.gitPushSetUpstream(ctx, config.Settings.Remote, domain.Branch.Name)
+				if err != nil {
+					return err
+				}
+				err = bit.recordStep(ctx, state, domain.Branch.Name, func(ds *DomainState) { ds.Pushed = true })
+				if err != nil {
+					return err
+				}
+			}
+
+			if domainState.PrUrl == "" {
+				return bit.createPullRequest(ctx, config, state, domain)
+			}
+			domain.PullRequest.Url = domainState.PrUrl
+			if !domainState.PrSetUp {
+				return bit.completePullRequestSetup(ctx, config, state, domain)
+			}
 			return nil
 		})
 	}
@@ -83,6 +110,13 @@ func (bit *BigIsTiny) run(ctx context.Context, config *BigChange) (err error) {
 		return err
 	}
 
+	if config.Settings.Tracking != nil {
+		err = bit.updateTracking(ctx, config)
+		if err != nil {
+			return err
+		}
+	}
+
 	err = bit.exportResults(ctx, bit.flags, config)
 	if err != nil {
 		return err
@@ -91,83 +125,199 @@ func (bit *BigIsTiny) run(ctx context.Context, config *BigChange) (err error) {
 	return nil
 }
 
-func (domain *Domain) initDomain(config *BigChange) {
-	domain.Branch = &Branch{
-		Name: config.generateFromTemplate(domain, config.Settings.BranchNameTemplate),
+// A fresh run refuses to start over a previous one as its branches already exist
+func (bit *BigIsTiny) loadRunState(ctx context.Context, config *BigChange) (*RunState, error) {
+	log := LoggerFromContext(ctx)
+
+	state, err := bit.stateOps.loadState(ctx, config.Id)
+	if err != nil {
+		return nil, err
 	}
-	domain.PullRequest = PullRequest{
-		Title: config.generateFromTemplate(domain, config.Settings.PrNameTemplate),
-		Body:  config.generateFromTemplate(domain, config.Settings.PrDescTemplate),
+
+	if state == nil {
+		if bit.flags.Resume {
+			log.Info("no previous run to resume, starting a new one", "change id", config.Id)
+		}
+		return newRunState(config.Id), nil
 	}
+	if !bit.flags.Resume {
+		log.Error("a previous run exists for this change, use -resume to continue it or -cleanup to remove it",
+			"change id", config.Id)
+		return nil, fmt.Errorf("previous run state found")
+	}
+	log.Debug("resuming previous run", "state", state)
+	return state, nil
 }
 
-func (bit *BigIsTiny) listChangedFiles(ctx context.Context) ([]string, error) {
-	gitStatusResponse, err := bit.gitOps.gitStatus(ctx)
+// Assigns the files changed by the big change to the domains, the repository is not modified
+func (bit *BigIsTiny) collectChanges(ctx context.Context, config *BigChange, settings *Settings) error {
+	changes, err := bit.listChangedFilesFromDiff(ctx, settings)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	rawOutput := string(gitStatusResponse[:])
-	rawList := strings.Split(strings.Trim(rawOutput, "\n"), "\n")
+	// Assign each file to a single domain before creating any branch
+	bit.assignFiles(ctx, config, changes)
+	return nil
+}
 
-	changedFiles := make([]string, 0, len(rawList))
-	for _, statusLine := range rawList {
-		_, filePath, _ := strings.Cut(strings.TrimSpace(statusLine), " ")
-		changedFiles = append(changedFiles, strings.Trim(filePath, "\""))
+func (domain *Domain) initDomain(config *BigChange) {
+	domain.Branch = &Branch{
+		Name: config.generateFromTemplate(domain, config.Settings.BranchNameTemplate),
+	}
+	domain.PullRequest = PullRequest{
+		Title: config.generateFromTemplate(domain, config.Settings.PrNameTemplate),
+		Body:  config.generateFromTemplate(domain, config.Settings.PrDescTemplate),
 	}
-	return changedFiles, nil
 }
 
-func fileChangedInDomain(domainPath string, changedFiles []string) bool {
-	for _, filePath := range changedFiles {
-		if strings.HasPrefix(filePath, domainPath) {
-			return true
+func (bit *BigIsTiny) assignFiles(ctx context.Context, config *BigChange, changes []FileChange) *FileAssignment {
+	log := LoggerFromContext(ctx)
+
+	for _, change := range changes {
+		if change.OldPath != "" {
+			log.Debug("file renamed or copied", "status", change.Status, "from", change.OldPath, "to", change.Path)
 		}
 	}
-	return false
+	assignment := assignChanges(config.Domains, changes, config.Settings.AssignmentStrategy, config.Settings.RenamePolicy)
+	for _, overlap := range assignment.Overlaps {
+		log.Warn("file matches multiple domains",
+			"file", overlap.File,
+			"domains", overlap.Domains,
+			"assigned to", overlap.AssignedTo)
+	}
+	if len(assignment.Unassigned) > 0 {
+		log.Info("files not matching any domain", "files", assignment.Unassigned)
+	}
+	return assignment
 }
 
-func (bit *BigIsTiny) createBranch(ctx context.Context, config *BigChange, domain *Domain, settings *Settings) (err error) {
+// The commit is built from the main branch and the big change files of the domain,
+// or the commits of the big change are replayed on it when the history is preserved,
+// `updateBranch` then points the branch to it
+func (bit *BigIsTiny) createBranch(ctx context.Context, config *BigChange, domain *Domain, settings *Settings, updateBranch GitTwoArgsStringFunc) (err error) {
 	defer func() {
 		if err != nil {
 			log := LoggerFromContext(ctx)
+			if errors.Is(err, ErrNoChanges) {
+				return
+			}
+			var conflictErr *ConflictError
+			if errors.As(err, &conflictErr) {
+				log.Error("changes conflict with the main branch, rebase or merge the branch to split on it",
+					"branch", domain.Branch.Name,
+					"commit", conflictErr.Commit,
+					"files", conflictErr.Files)
+			}
 			log.Error("failed to create Branch", "branch", domain.Branch.Name)
 		}
 	}()
 
-	err = bit.gitOps.gitCheckoutNewBranch(ctx, domain.Branch.Name)
+	source := fmt.Sprintf("%s/%s", settings.Remote, settings.BranchToSplit)
+	var commitSha string
+	if settings.PreserveHistory {
+		commitSha, err = bit.gitOps.gitReplayCommits(ctx, settings.MainBranch, source, domain.Files)
+	} else {
+		commitSha, err = bit.gitOps.gitCommitFiles(ctx,
+			settings.MainBranch,
+			source,
+			domain.Files,
+			config.generateFromTemplate(domain, settings.CommitMsgTemplate))
+	}
 	if err != nil {
 		return err
 	}
 
-	// We go back to main branch not to change the repository initial state
-	defer func() {
-		checkoutErr := bit.gitOps.gitCheckout(ctx, settings.MainBranch)
-		if checkoutErr != nil {
-			err = checkoutErr
-		}
-	}()
+	return updateBranch(ctx, domain.Branch.Name, commitSha)
+}
 
-	err = bit.gitOps.gitAdd(ctx, domain.Path)
+func (bit *BigIsTiny) createPullRequest(ctx context.Context, config *BigChange, state *RunState, domain *Domain) error {
+	var url string
+	var err error
+	if creator, onCreation := requiredReviewersOnCreation(config.Settings, bit.gitOps.platform); onCreation {
+		url, err = creator.CreatePrWithRequiredReviewers(ctx, config.Settings, domain.Branch.Name, domain.PullRequest.Title, domain.PullRequest.Body, domain.reviewers())
+	} else {
+		url, err = bit.gitOps.platform.CreatePr(ctx, config.Settings, domain.Branch.Name, domain.PullRequest.Title, domain.PullRequest.Body)
+	}
 	if err != nil {
+		log := LoggerFromContext(ctx)
+		log.Error("failed to create Pull Request", "branch", domain.Branch.Name)
 		return err
 	}
+	domain.PullRequest.Url = url
 
-	err = bit.gitOps.gitCommit(ctx, config.generateFromTemplate(domain, settings.CommitMsgTemplate))
+	// Recorded before the setup of the PR so a resumed run does not create it again
+	err = bit.recordStep(ctx, state, domain.Branch.Name, func(ds *DomainState) { ds.PrUrl = url })
 	if err != nil {
 		return err
 	}
+	return bit.completePullRequestSetup(ctx, config, state, domain)
+}
 
-	return nil
+// The changes of the domain are all already on the main branch, it is then handled
+// as a domain without files
+func skipDomain(ctx context.Context, domain *Domain) {
+	log := LoggerFromContext(ctx)
+	log.Info("no changes left on the main branch for the domain, skipping it", "branch", domain.Branch.Name)
+	domain.Files = nil
 }
 
-func (bit *BigIsTiny) createPullRequest(ctx context.Context, domain *Domain, settings *Settings) (url string, err error) {
-	url, err = bit.gitOps.createPr(ctx, settings, domain.Branch.Name, domain.PullRequest.Title, domain.PullRequest.Body)
+// A resumed run sets up the PR again until its setup is recorded
+func (bit *BigIsTiny) completePullRequestSetup(ctx context.Context, config *BigChange, state *RunState, domain *Domain) error {
+	err := bit.setupPullRequest(ctx, config, domain)
 	if err != nil {
-		log := LoggerFromContext(ctx)
-		log.Error("failed to create Pull Request", "branch", domain.Branch.Name)
-		return "", err
+		return err
 	}
+	return bit.recordStep(ctx, state, domain.Branch.Name, func(ds *DomainState) { ds.PrSetUp = true })
+}
+
+// Applies the labels, milestone, work items, auto-merge and reviewers of the domain to its PR
+func (bit *BigIsTiny) setupPullRequest(ctx context.Context, config *BigChange, domain *Domain) error {
+	log := LoggerFromContext(ctx)
+	settings := config.Settings
+	branch := domain.Branch.Name
+	platform := bit.gitOps.platform
 
-	return url, nil
+	if labels := config.prLabels(domain); len(labels) > 0 {
+		err := platform.AddLabels(ctx, settings, branch, labels)
+		if err != nil {
+			log.Error("failed to add labels", "branch", branch, "labels", labels, "error", err)
+			return err
+		}
+	}
+
+	if milestone := domain.prMilestone(settings); milestone != "" {
+		err := platform.SetMilestone(ctx, settings, branch, milestone)
+		if err != nil {
+			log.Error("failed to set milestone", "branch", branch, "milestone", milestone, "error", err)
+			return err
+		}
+	}
+
+	if workItems := domain.prWorkItems(settings); len(workItems) > 0 {
+		err := platform.LinkWorkItems(ctx, settings, branch, workItems)
+		if err != nil {
+			log.Error("failed to link work items", "branch", branch, "work items", workItems, "error", err)
+			return err
+		}
+	}
+
+	if autoMerge := settings.AutoMerge; autoMerge != nil {
+		err := platform.EnableAutoMerge(ctx, settings, branch, autoMerge)
+		if err != nil {
+			log.Error("failed to enable auto-merge", "branch", branch, "method", autoMerge.Method, "error", err)
+			return err
+		}
+	}
+
+	if _, onCreation := requiredReviewersOnCreation(settings, platform); onCreation {
+		log.Debug("reviewers already required when creating the Pull Request", "branch", branch)
+	} else if reviewers := domain.reviewers(); len(reviewers) > 0 {
+		err := platform.AddReviewers(ctx, settings, branch, reviewers)
+		if err != nil {
+			log.Error("failed to request reviews", "branch", branch, "reviewers", reviewers, "error", err)
+			return err
+		}
+	}
+	return nil
 }
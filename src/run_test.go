@@ -3,16 +3,46 @@ package main
 import (
 	"context"
 	"fmt"
+	"strings"
 	"testing"
+
+	"github.com/google/go-cmp/cmp"
 )
 
 type givenRun struct {
 	exportResults ExportResultsFunc
 	flags         *Flags
 	gitOps        *GitOps
+	stateOps      *StateOps
 	config        *BigChange
 }
 
+func fixtureRunState() *RunState {
+	state := newRunState("")
+	state.Domains["bit-dom1-big-change-split"] = &DomainState{
+		Branch:    "bit-dom1-big-change-split",
+		CommitSha: "dom1-sha",
+		Pushed:    true,
+		PrUrl:     "https://example.com/pr/1",
+		PrSetUp:   true,
+	}
+	state.Domains["bit-dom2-big-change-split"] = &DomainState{
+		Branch:    "bit-dom2-big-change-split",
+		CommitSha: "dom2-sha",
+	}
+	return state
+}
+
+// Platform requiring the reviewers when creating the PR, like the Azure CLI
+type fakeRequiredReviewersPlatform struct {
+	*fakePlatform
+	createPrWithRequiredReviewers func(context.Context, *Settings, string, string, string, []string) (string, error)
+}
+
+func (p *fakeRequiredReviewersPlatform) CreatePrWithRequiredReviewers(ctx context.Context, settings *Settings, head, title, body string, reviewers []string) (string, error) {
+	return p.createPrWithRequiredReviewers(ctx, settings, head, title, body, reviewers)
+}
+
 var runTests = []struct {
 	description string
 	given       givenRun
@@ -25,6 +55,7 @@ var runTests = []struct {
 				bc.Domains[0].Branch = &Branch{
 					Name: "bit-dom1-big-change-split",
 				}
+				bc.Domains[0].Files = []string{"domains/dom1/file1"}
 				bc.Domains[0].PullRequest = PullRequest{
 					Title: "AA dom1: Big change split",
 					Body:  "This change refers to this refactor for domain AA dom1: https://example.com",
@@ -33,6 +64,7 @@ var runTests = []struct {
 				bc.Domains[1].Branch = &Branch{
 					Name: "bit-dom2-big-change-split",
 				}
+				bc.Domains[1].Files = []string{"domains/dom2/file2"}
 				bc.Domains[1].PullRequest = PullRequest{
 					Title: "BB dom2: Big change split",
 					Body:  "This change refers to this refactor for domain BB dom2: https://example.com",
@@ -52,6 +84,114 @@ var runTests = []struct {
 			config: fixtureBigChange(),
 		},
 	},
+	{
+		description: "Commit only the files matching the domain glob",
+		given: givenRun{
+			exportResults: func(ctx context.Context, f *Flags, bc *BigChange) error { return nil },
+			flags:         fixtureFlags(),
+			gitOps: fixtureGitOps(func(g *GitOps) {
+				g.gitDiffNameStatus = func(ctx context.Context, s1, s2 string) ([]byte, error) {
+					return []byte("M\x00domains/dom1/file1.go\x00M\x00domains/dom1/file2.md\x00A\x00domains/dom2/new/file3.go\x00"), nil
+				}
+				g.gitCommitFiles = func(ctx context.Context, s1, s2 string, files []string, s3 string) (string, error) {
+					if diff := cmp.Diff(files, []string{"domains/dom1/file1.go", "domains/dom2/new/file3.go"}); diff != "" {
+						return "", fmt.Errorf("unexpected files committed: %v", diff)
+					}
+					return "commit-sha", nil
+				}
+			}),
+			config: fixtureBigChange(func(bc *BigChange) {
+				bc.Domains = []*Domain{{Name: "go", Id: "GO", Path: "domains/**/*.go"}}
+			}),
+		},
+	},
+	{
+		description: "Build the commits from the main branch and the remote branch to split",
+		given: givenRun{
+			exportResults: func(ctx context.Context, f *Flags, bc *BigChange) error { return nil },
+			flags:         fixtureFlags(),
+			gitOps: fixtureGitOps(func(g *GitOps) {
+				g.gitCommitFiles = func(ctx context.Context, parent, source string, files []string, message string) (string, error) {
+					if parent != "main" || source != "origin/big-change-to-split" {
+						return "", fmt.Errorf("unexpected commit of '%s' on '%s'", source, parent)
+					}
+					if !strings.HasPrefix(message, "implement new feature for ") {
+						return "", fmt.Errorf("unexpected commit message '%s'", message)
+					}
+					return strings.Split(files[0], "/")[1] + "-commit", nil
+				}
+				g.gitCreateBranch = func(ctx context.Context, branch, commitSha string) error {
+					if branch != "bit-"+strings.TrimSuffix(commitSha, "-commit")+"-big-change-split" {
+						return fmt.Errorf("unexpected commit '%s' for branch '%s'", commitSha, branch)
+					}
+					return nil
+				}
+			}),
+			config: fixtureBigChange(),
+		},
+	},
+	{
+		description: "Replay the commits of the branch to split when the history is preserved",
+		given: givenRun{
+			exportResults: func(ctx context.Context, f *Flags, bc *BigChange) error { return nil },
+			flags:         fixtureFlags(),
+			gitOps: fixtureGitOps(func(g *GitOps) {
+				g.gitCommitFiles = func(ctx context.Context, s1, s2 string, files []string, s3 string) (string, error) {
+					return "", fmt.Errorf("gitCommitFiles should not be called")
+				}
+				g.gitReplayCommits = func(ctx context.Context, parent, source string, files []string) (string, error) {
+					if parent != "main" || source != "origin/big-change-to-split" {
+						return "", fmt.Errorf("unexpected replay of '%s' on '%s'", source, parent)
+					}
+					return strings.Split(files[0], "/")[1] + "-commit", nil
+				}
+				g.gitCreateBranch = func(ctx context.Context, branch, commitSha string) error {
+					if branch != "bit-"+strings.TrimSuffix(commitSha, "-commit")+"-big-change-split" {
+						return fmt.Errorf("unexpected commit '%s' for branch '%s'", commitSha, branch)
+					}
+					return nil
+				}
+			}),
+			config: fixtureBigChange(func(bc *BigChange) {
+				bc.Settings.PreserveHistory = true
+			}),
+		},
+	},
+	{
+		description: "Skip the domains whose replayed commits change nothing on the main branch",
+		given: givenRun{
+			exportResults: func(ctx context.Context, f *Flags, bc *BigChange) error {
+				if len(bc.Domains[0].Files) != 0 {
+					return fmt.Errorf("skipped domain exported with files %v", bc.Domains[0].Files)
+				}
+				return nil
+			},
+			flags: fixtureFlags(),
+			gitOps: fixtureGitOps(func(g *GitOps) {
+				g.gitReplayCommits = func(ctx context.Context, parent, source string, files []string) (string, error) {
+					if strings.HasPrefix(files[0], "domains/dom1/") {
+						return "", ErrNoChanges
+					}
+					return "dom2-commit", nil
+				}
+				g.gitCreateBranch = func(ctx context.Context, branch, commitSha string) error {
+					if branch == "bit-dom1-big-change-split" {
+						return fmt.Errorf("branch created for the skipped domain")
+					}
+					return nil
+				}
+				g.gitPushSetUpstream = func(ctx context.Context, remote, branch string) error {
+					if branch == "bit-dom1-big-change-split" {
+						return fmt.Errorf("branch pushed for the skipped domain")
+					}
+					return nil
+				}
+			}),
+			config: fixtureBigChange(func(bc *BigChange) {
+				bc.Settings.PreserveHistory = true
+			}),
+		},
+	},
 	{
 		description: "Don't create branches and PRs on cleanup",
 		given: givenRun{
@@ -60,8 +200,9 @@ var runTests = []struct {
 				f.Cleanup = true
 			}),
 			gitOps: fixtureGitOps(func(g *GitOps) {
-				g.gitAdd = func(ctx context.Context, s string) error { return fmt.Errorf("gitAdd should not be called") }
-				g.gitCommit = func(ctx context.Context, s string) error { return fmt.Errorf("gitCommit should not be called") }
+				g.gitCommitFiles = func(ctx context.Context, s1, s2 string, files []string, s3 string) (string, error) {
+					return "", fmt.Errorf("gitCommitFiles should not be called")
+				}
 				g.gitPushSetUpstream = func(ctx context.Context, s1, s2 string) error {
 					return fmt.Errorf("gitPushSetUpstream should not be called")
 				}
@@ -70,116 +211,486 @@ var runTests = []struct {
 		},
 	},
 	{
-		description: "Fail on gitCheckout",
+		description: "Fail on gitDiffNameStatus",
 		given: givenRun{
 			exportResults: checkExportResults(nil),
 			flags:         fixtureFlags(),
 			gitOps: fixtureGitOps(func(g *GitOps) {
-				g.gitCheckout = func(ctx context.Context, s string) error { return fmt.Errorf("gitCheckout failed") }
+				g.gitDiffNameStatus = func(ctx context.Context, s1, s2 string) ([]byte, error) {
+					return nil, fmt.Errorf("gitDiffNameStatus failed")
+				}
 			}),
 			config: fixtureBigChange(),
 		},
-		expectedErr: fmt.Errorf("gitCheckout failed"),
+		expectedErr: fmt.Errorf("gitDiffNameStatus failed"),
 	},
 	{
-		description: "Fail on gitCheckoutNewBranch",
+		description: "Fail on gitCommitFiles",
 		given: givenRun{
 			exportResults: checkExportResults(nil),
 			flags:         fixtureFlags(),
 			gitOps: fixtureGitOps(func(g *GitOps) {
-				g.gitCheckoutNewBranch = func(ctx context.Context, s string) error { return fmt.Errorf("gitCheckoutNewBranch failed") }
+				g.gitCommitFiles = func(ctx context.Context, s1, s2 string, files []string, s3 string) (string, error) {
+					return "", fmt.Errorf("gitCommitFiles failed")
+				}
 			}),
 			config: fixtureBigChange(),
 		},
-		expectedErr: fmt.Errorf("gitCheckoutNewBranch failed"),
+		expectedErr: fmt.Errorf("gitCommitFiles failed"),
 	},
 	{
-		description: "Fail on gitStatus",
+		description: "Fail on gitReplayCommits",
 		given: givenRun{
 			exportResults: checkExportResults(nil),
 			flags:         fixtureFlags(),
 			gitOps: fixtureGitOps(func(g *GitOps) {
-				g.gitStatus = func(ctx context.Context) ([]byte, error) { return nil, fmt.Errorf("gitStatus failed") }
+				g.gitReplayCommits = func(ctx context.Context, s1, s2 string, files []string) (string, error) {
+					return "", &ConflictError{Files: files, Commit: "big-sha"}
+				}
+			}),
+			config: fixtureBigChange(func(bc *BigChange) {
+				bc.Settings.PreserveHistory = true
 			}),
-			config: fixtureBigChange(),
 		},
-		expectedErr: fmt.Errorf("gitStatus failed"),
+		expectedErr: &ConflictError{Files: []string{"domains/dom1/file1"}, Commit: "big-sha"},
 	},
 	{
-		description: "Fail on gitAdd",
+		description: "Fail on gitCreateBranch",
 		given: givenRun{
 			exportResults: checkExportResults(nil),
 			flags:         fixtureFlags(),
 			gitOps: fixtureGitOps(func(g *GitOps) {
-				g.gitAdd = func(ctx context.Context, s string) error { return fmt.Errorf("gitAdd failed") }
+				g.gitCreateBranch = func(ctx context.Context, s1, s2 string) error { return fmt.Errorf("gitCreateBranch failed") }
 			}),
 			config: fixtureBigChange(),
 		},
-		expectedErr: fmt.Errorf("gitAdd failed"),
+		expectedErr: fmt.Errorf("gitCreateBranch failed"),
 	},
 	{
-		description: "Fail on gitCommit",
+		description: "Fail on gitPushSetUpstream",
 		given: givenRun{
 			exportResults: checkExportResults(nil),
 			flags:         fixtureFlags(),
 			gitOps: fixtureGitOps(func(g *GitOps) {
-				g.gitCommit = func(ctx context.Context, s string) error { return fmt.Errorf("gitCommit failed") }
+				g.gitPushSetUpstream = func(ctx context.Context, s1, s2 string) error { return fmt.Errorf("gitPushSetUpstream failed") }
 			}),
 			config: fixtureBigChange(),
 		},
-		expectedErr: fmt.Errorf("gitCommit failed"),
+		expectedErr: fmt.Errorf("gitPushSetUpstream failed"),
 	},
 	{
-		description: "Fail on gitCheckoutFiles",
+		description: "Fail on createPr",
 		given: givenRun{
 			exportResults: checkExportResults(nil),
 			flags:         fixtureFlags(),
 			gitOps: fixtureGitOps(func(g *GitOps) {
-				g.gitCheckoutFiles = func(ctx context.Context, s1, s2 string, allowDeletions bool) error {
-					return fmt.Errorf("gitCheckoutFiles failed")
+				platformOf(g).createPr = func(ctx context.Context, s1 *Settings, s2, s3, s4 string) (string, error) {
+					return "", fmt.Errorf("createPr failed")
 				}
 			}),
 			config: fixtureBigChange(),
 		},
-		expectedErr: fmt.Errorf("gitCheckoutFiles failed"),
+		expectedErr: fmt.Errorf("createPr failed"),
+	},
+	{
+		description: "Request reviews from the domain teams",
+		given: givenRun{
+			exportResults: func(ctx context.Context, f *Flags, bc *BigChange) error { return nil },
+			flags:         fixtureFlags(),
+			gitOps: fixtureGitOps(func(g *GitOps) {
+				platformOf(g).addReviewers = func(ctx context.Context, s1 *Settings, s2 string, reviewers []string) error {
+					if s2 != "bit-dom2-big-change-split" {
+						return fmt.Errorf("addReviewers should only be called for dom2")
+					}
+					if diff := cmp.Diff(reviewers, []string{"alice", "@org/team-bb"}); diff != "" {
+						return fmt.Errorf("%v", diff)
+					}
+					return nil
+				}
+			}),
+			config: fixtureBigChange(func(bc *BigChange) {
+				bc.Domains[1].Teams[0].Reviewers = []string{"alice", "@org/team-bb"}
+				bc.Domains[1].Teams[1].Reviewers = []string{"alice"}
+			}),
+		},
+	},
+	{
+		description: "Apply labels, milestone and work items",
+		given: givenRun{
+			exportResults: func(ctx context.Context, f *Flags, bc *BigChange) error { return nil },
+			flags:         fixtureFlags(),
+			gitOps: fixtureGitOps(func(g *GitOps) {
+				platformOf(g).addLabels = func(ctx context.Context, s1 *Settings, s2 string, labels []string) error {
+					expectedLabels := []string{"big-change", strings.Split(s2, "-")[1]}
+					if diff := cmp.Diff(labels, expectedLabels); diff != "" {
+						return fmt.Errorf("%v", diff)
+					}
+					return nil
+				}
+				platformOf(g).setMilestone = func(ctx context.Context, s1 *Settings, s2 string, milestone string) error {
+					if s2 == "bit-dom1-big-change-split" && milestone != "v2" || s2 != "bit-dom1-big-change-split" && milestone != "v1" {
+						return fmt.Errorf("unexpected milestone '%s' for '%s'", milestone, s2)
+					}
+					return nil
+				}
+				platformOf(g).linkWorkItems = func(ctx context.Context, s1 *Settings, s2 string, workItems []int) error {
+					if diff := cmp.Diff(workItems, []int{42}); diff != "" {
+						return fmt.Errorf("%v", diff)
+					}
+					return nil
+				}
+			}),
+			config: fixtureBigChange(func(bc *BigChange) {
+				bc.Settings.Labels = []string{"big-change", "{{domain_name}}"}
+				bc.Settings.Milestone = "v1"
+				bc.Settings.WorkItems = []int{42}
+				bc.Domains[0].Milestone = "v2"
+				bc.Domains[0].WorkItems = []int{42}
+			}),
+		},
+	},
+	{
+		description: "Enable auto-merge",
+		given: givenRun{
+			exportResults: func(ctx context.Context, f *Flags, bc *BigChange) error { return nil },
+			flags:         fixtureFlags(),
+			gitOps: fixtureGitOps(func(g *GitOps) {
+				platformOf(g).enableAutoMerge = func(ctx context.Context, s1 *Settings, s2 string, autoMerge *AutoMerge) error {
+					if diff := cmp.Diff(autoMerge, &AutoMerge{Method: MergeMethodSquash, DeleteBranch: true}); diff != "" {
+						return fmt.Errorf("%v", diff)
+					}
+					return nil
+				}
+			}),
+			config: fixtureBigChange(func(bc *BigChange) {
+				bc.Settings.AutoMerge = &AutoMerge{Method: MergeMethodSquash, DeleteBranch: true}
+			}),
+		},
 	},
 	{
-		description: "Fail on gitReset",
+		description: "Fail on auto-merge not supported by the platform",
 		given: givenRun{
 			exportResults: checkExportResults(nil),
 			flags:         fixtureFlags(),
+			gitOps:        fixtureGitOps(),
+			config: fixtureBigChange(func(bc *BigChange) {
+				bc.Settings.AutoMerge = &AutoMerge{Method: MergeMethodMerge}
+			}),
+		},
+		expectedErr: fmt.Errorf("auto-merge not supported by the platform"),
+	},
+	{
+		description: "Fail on milestone not supported by the platform",
+		given: givenRun{
+			exportResults: checkExportResults(nil),
+			flags:         fixtureFlags(),
+			gitOps:        fixtureGitOps(),
+			config: fixtureBigChange(func(bc *BigChange) {
+				bc.Settings.Milestone = "v1"
+			}),
+		},
+		expectedErr: fmt.Errorf("milestone not supported by the platform"),
+	},
+	{
+		description: "Fail on reviewers not supported by the platform",
+		given: givenRun{
+			exportResults: checkExportResults(nil),
+			flags:         fixtureFlags(),
+			gitOps:        fixtureGitOps(),
+			config: fixtureBigChange(func(bc *BigChange) {
+				bc.Domains[0].Teams[0].Reviewers = []string{"alice"}
+			}),
+		},
+		expectedErr: fmt.Errorf("reviewers not supported by the platform"),
+	},
+	{
+		description: "Create a tracking issue listing the PRs",
+		given: givenRun{
+			exportResults: checkExportResults(fixtureBigChange(func(bc *BigChange) {
+				bc.Domains[0].Branch = &Branch{
+					Name: "bit-dom1-big-change-split",
+				}
+				bc.Domains[0].Files = []string{"domains/dom1/file1"}
+				bc.Domains[0].PullRequest = PullRequest{
+					Title: "AA dom1: Big change split",
+					Body:  "Tracked in https://example.com/issues/7",
+					Url:   "bit-dom1-big-change-split/pr",
+				}
+				bc.Domains[1].Branch = &Branch{
+					Name: "bit-dom2-big-change-split",
+				}
+				bc.Domains[1].Files = []string{"domains/dom2/file2"}
+				bc.Domains[1].PullRequest = PullRequest{
+					Title: "BB dom2: Big change split",
+					Body:  "Tracked in https://example.com/issues/7",
+					Url:   "bit-dom2-big-change-split/pr",
+				}
+				bc.Domains[2].Branch = &Branch{
+					Name: "bit-dom3-big-change-split",
+				}
+				bc.Domains[2].PullRequest = PullRequest{
+					Title: "CC dom3: Big change split",
+					Body:  "Tracked in https://example.com/issues/7",
+				}
+			}).Domains),
+			flags: fixtureFlags(),
 			gitOps: fixtureGitOps(func(g *GitOps) {
-				g.gitReset = func(ctx context.Context) error { return fmt.Errorf("gitReset failed") }
+				platformOf(g).createPr = func(ctx context.Context, s1 *Settings, s2, s3, s4 string) (string, error) {
+					if s4 != "Tracked in https://example.com/issues/7" {
+						return "", fmt.Errorf("unexpected PR body '%s'", s4)
+					}
+					return s2 + "/pr", nil
+				}
+				platformOf(g).findIssue = func(ctx context.Context, s1 *Settings, title string) (string, error) {
+					if title != "big-42: big change split" {
+						return "", fmt.Errorf("unexpected issue title '%s'", title)
+					}
+					return "", nil
+				}
+				platformOf(g).createIssue = func(ctx context.Context, s1 *Settings, s2, s3 string) (string, error) {
+					return "https://example.com/issues/7", nil
+				}
+				platformOf(g).updateIssue = func(ctx context.Context, s1 *Settings, issueUrl, title, body string) error {
+					expectedBody := "Split of big-42\n\n" +
+						"- [ ] AA dom1 (First Team AA): bit-dom1-big-change-split/pr open\n" +
+						"- [ ] BB dom2 (Team BB 1, Team BB 2): bit-dom2-big-change-split/pr open"
+					if diff := cmp.Diff(body, expectedBody); issueUrl != "https://example.com/issues/7" || diff != "" {
+						return fmt.Errorf("unexpected update of '%s': %v", issueUrl, diff)
+					}
+					return nil
+				}
+			}),
+			config: fixtureBigChange(func(bc *BigChange) {
+				bc.Id = "big-42"
+				bc.Settings.PrDescTemplate = "Tracked in {{tracking_url}}"
+				bc.Settings.TrackingTemplate = defaultTrackingTemplate
+				bc.Settings.Tracking = &Tracking{
+					Type:        TrackingIssue,
+					Title:       defaultTrackingTitle,
+					Description: "Split of {{change_id}}",
+				}
 			}),
-			config: fixtureBigChange(),
 		},
-		expectedErr: fmt.Errorf("gitReset failed"),
 	},
 	{
-		description: "Fail on gitPushSetUpstream",
+		description: "Reuse the PR of the branch to split as umbrella PR",
+		given: givenRun{
+			exportResults: func(ctx context.Context, f *Flags, bc *BigChange) error { return nil },
+			flags:         fixtureFlags(),
+			gitOps: fixtureGitOps(func(g *GitOps) {
+				platformOf(g).findPr = func(ctx context.Context, s1 *Settings, s2 string) (string, error) {
+					if s2 != "big-change-to-split" {
+						return "", fmt.Errorf("findPr should only be called for the branch to split")
+					}
+					return "https://example.com/pr/0", nil
+				}
+				platformOf(g).updatePr = func(ctx context.Context, s1 *Settings, s2, s3, s4 string) error {
+					expectedBody := "- [x] AA merged\n- [x] BB merged"
+					if diff := cmp.Diff(s4, expectedBody); s2 != "big-change-to-split" || diff != "" {
+						return fmt.Errorf("unexpected update of '%s': %v", s2, diff)
+					}
+					return nil
+				}
+				platformOf(g).prStatus = func(ctx context.Context, s1 *Settings, s2 string) (*PrStatus, error) {
+					return &PrStatus{State: PrStateMerged}, nil
+				}
+			}),
+			config: fixtureBigChange(func(bc *BigChange) {
+				bc.Settings.TrackingTemplate = "- [{{pr_checked}}] {{domain_id}} {{pr_state}}"
+				bc.Settings.Tracking = &Tracking{Type: TrackingPr}
+			}),
+		},
+	},
+	{
+		description: "Fail on tracking issues not supported by the platform",
 		given: givenRun{
 			exportResults: checkExportResults(nil),
 			flags:         fixtureFlags(),
+			gitOps:        fixtureGitOps(),
+			config: fixtureBigChange(func(bc *BigChange) {
+				bc.Settings.Tracking = &Tracking{Type: TrackingIssue}
+			}),
+		},
+		expectedErr: fmt.Errorf("issues not supported by the platform"),
+	},
+	{
+		description: "Resume skips the completed steps",
+		given: givenRun{
+			exportResults: checkExportResults(fixtureBigChange(func(bc *BigChange) {
+				bc.Domains[0].Branch = &Branch{
+					Name: "bit-dom1-big-change-split",
+				}
+				bc.Domains[0].Files = []string{"domains/dom1/file1"}
+				bc.Domains[0].PullRequest = PullRequest{
+					Title: "AA dom1: Big change split",
+					Body:  "This change refers to this refactor for domain AA dom1: https://example.com",
+					Url:   "https://example.com/pr/1",
+				}
+				bc.Domains[1].Branch = &Branch{
+					Name: "bit-dom2-big-change-split",
+				}
+				bc.Domains[1].Files = []string{"domains/dom2/file2"}
+				bc.Domains[1].PullRequest = PullRequest{
+					Title: "BB dom2: Big change split",
+					Body:  "This change refers to this refactor for domain BB dom2: https://example.com",
+					Url:   "bit-dom2-big-change-split/pr",
+				}
+				bc.Domains[2].Branch = &Branch{
+					Name: "bit-dom3-big-change-split",
+				}
+				bc.Domains[2].PullRequest = PullRequest{
+					Title: "CC dom3: Big change split",
+					Body:  "This change refers to this refactor for domain CC dom3: https://example.com",
+				}
+			}).Domains),
+			flags: fixtureFlags(func(f *Flags) {
+				f.Resume = true
+			}),
 			gitOps: fixtureGitOps(func(g *GitOps) {
-				g.gitPushSetUpstream = func(ctx context.Context, s1, s2 string) error { return fmt.Errorf("gitPushSetUpstream failed") }
+				g.gitCreateBranch = func(ctx context.Context, s1, s2 string) error {
+					if s1 == "bit-dom1-big-change-split" {
+						return fmt.Errorf("gitCreateBranch should not be called for dom1")
+					}
+					return nil
+				}
+				g.gitPushSetUpstream = func(ctx context.Context, s1, s2 string) error {
+					if s2 == "bit-dom1-big-change-split" {
+						return fmt.Errorf("gitPushSetUpstream should not be called for dom1")
+					}
+					return nil
+				}
+				platformOf(g).createPr = func(ctx context.Context, s1 *Settings, s2, s3, s4 string) (string, error) {
+					if s2 == "bit-dom1-big-change-split" {
+						return "", fmt.Errorf("createPr should not be called for dom1")
+					}
+					return s2 + "/pr", nil
+				}
+				platformOf(g).addLabels = func(ctx context.Context, s1 *Settings, s2 string, s3 []string) error {
+					if s2 == "bit-dom1-big-change-split" {
+						return fmt.Errorf("addLabels should not be called for dom1")
+					}
+					return nil
+				}
+			}),
+			stateOps: fixtureStateOps(func(so *StateOps) {
+				so.loadState = func(ctx context.Context, s string) (*RunState, error) { return fixtureRunState(), nil }
+			}),
+			config: fixtureBigChange(func(bc *BigChange) {
+				bc.Settings.Labels = []string{"big-change"}
 			}),
-			config: fixtureBigChange(),
 		},
-		expectedErr: fmt.Errorf("gitPushSetUpstream failed"),
 	},
 	{
-		description: "Fail on createPr",
+		description: "Resume sets up again a Pull Request whose setup failed",
 		given: givenRun{
 			exportResults: checkExportResults(nil),
+			flags: fixtureFlags(func(f *Flags) {
+				f.Resume = true
+			}),
+			gitOps: fixtureGitOps(func(g *GitOps) {
+				platformOf(g).createPr = func(ctx context.Context, s1 *Settings, s2, s3, s4 string) (string, error) {
+					if s2 == "bit-dom1-big-change-split" {
+						return "", fmt.Errorf("createPr should not be called for dom1")
+					}
+					return s2 + "/pr", nil
+				}
+				platformOf(g).addLabels = func(ctx context.Context, s1 *Settings, s2 string, s3 []string) error {
+					if s2 == "bit-dom1-big-change-split" {
+						return fmt.Errorf("addLabels called for dom1")
+					}
+					return nil
+				}
+			}),
+			stateOps: fixtureStateOps(func(so *StateOps) {
+				so.loadState = func(ctx context.Context, s string) (*RunState, error) {
+					state := fixtureRunState()
+					state.Domains["bit-dom1-big-change-split"].PrSetUp = false
+					return state, nil
+				}
+			}),
+			config: fixtureBigChange(func(bc *BigChange) {
+				bc.Settings.Labels = []string{"big-change"}
+			}),
+		},
+		expectedErr: fmt.Errorf("addLabels called for dom1"),
+	},
+	{
+		description: "Required reviewers are given when creating the Pull Request",
+		given: givenRun{
+			exportResults: func(ctx context.Context, f *Flags, bc *BigChange) error { return nil },
 			flags:         fixtureFlags(),
 			gitOps: fixtureGitOps(func(g *GitOps) {
-				g.createPr = func(ctx context.Context, s1 *Settings, s2, s3, s4 string) (string, error) {
-					return "", fmt.Errorf("createPr failed")
+				platformOf(g).addReviewers = func(ctx context.Context, s1 *Settings, s2 string, s3 []string) error {
+					return fmt.Errorf("addReviewers should not be called")
+				}
+				g.platform = &fakeRequiredReviewersPlatform{
+					fakePlatform: platformOf(g),
+					createPrWithRequiredReviewers: func(ctx context.Context, s1 *Settings, s2, s3, s4 string, s5 []string) (string, error) {
+						if s2 == "bit-dom1-big-change-split" {
+							if diff := cmp.Diff(s5, []string{"alice"}); diff != "" {
+								return "", fmt.Errorf("unexpected required reviewers: %v", diff)
+							}
+						}
+						return s2 + "/pr", nil
+					},
 				}
 			}),
+			config: fixtureBigChange(func(bc *BigChange) {
+				bc.Settings.RequiredReviewers = true
+				bc.Domains[0].Teams[0].Reviewers = []string{"alice"}
+			}),
+		},
+	},
+	{
+		description: "Fail when a previous run exists and resume is not set",
+		given: givenRun{
+			exportResults: checkExportResults(nil),
+			flags:         fixtureFlags(),
+			gitOps:        fixtureGitOps(),
+			stateOps: fixtureStateOps(func(so *StateOps) {
+				so.loadState = func(ctx context.Context, s string) (*RunState, error) { return fixtureRunState(), nil }
+			}),
 			config: fixtureBigChange(),
 		},
-		expectedErr: fmt.Errorf("createPr failed"),
+		expectedErr: fmt.Errorf("previous run state found"),
+	},
+	{
+		description: "Fail on loadState",
+		given: givenRun{
+			exportResults: checkExportResults(nil),
+			flags:         fixtureFlags(),
+			gitOps:        fixtureGitOps(),
+			stateOps: fixtureStateOps(func(so *StateOps) {
+				so.loadState = func(ctx context.Context, s string) (*RunState, error) { return nil, fmt.Errorf("loadState failed") }
+			}),
+			config: fixtureBigChange(),
+		},
+		expectedErr: fmt.Errorf("loadState failed"),
+	},
+	{
+		description: "Fail on saveState",
+		given: givenRun{
+			exportResults: checkExportResults(nil),
+			flags:         fixtureFlags(),
+			gitOps:        fixtureGitOps(),
+			stateOps: fixtureStateOps(func(so *StateOps) {
+				so.saveState = func(ctx context.Context, rs *RunState) error { return fmt.Errorf("saveState failed") }
+			}),
+			config: fixtureBigChange(),
+		},
+		expectedErr: fmt.Errorf("saveState failed"),
+	},
+	{
+		description: "Fail on gitRevParse",
+		given: givenRun{
+			exportResults: checkExportResults(nil),
+			flags:         fixtureFlags(),
+			gitOps: fixtureGitOps(func(g *GitOps) {
+				g.gitRevParse = func(ctx context.Context, s string) (string, error) { return "", fmt.Errorf("gitRevParse failed") }
+			}),
+			config: fixtureBigChange(),
+		},
+		expectedErr: fmt.Errorf("gitRevParse failed"),
 	},
 	{
 		description: "Fail on exportResults",
@@ -198,10 +709,15 @@ func TestRun(t *testing.T) {
 
 	for _, tt := range runTests {
 		t.Run(tt.description, func(t *testing.T) {
+			stateOps := tt.given.stateOps
+			if stateOps == nil {
+				stateOps = fixtureStateOps()
+			}
 			bit := &BigIsTiny{
 				exportResults: tt.given.exportResults,
 				flags:         tt.given.flags,
 				gitOps:        tt.given.gitOps,
+				stateOps:      stateOps,
 			}
 			gotErr := bit.run(ctxWithSilentLogger, tt.given.config)
 
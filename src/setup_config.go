@@ -6,7 +6,8 @@ import (
 	"fmt"
 )
 
-func setupConfig(ctx context.Context, rawConfig []byte) (*BigChange, error) {
+// The settings not supported by `platform` are rejected before any change is made
+func setupConfig(ctx context.Context, rawConfig []byte, platform Platform) (*BigChange, error) {
 	log := LoggerFromContext(ctx)
 	bigChange := &BigChange{}
 
@@ -16,14 +17,15 @@ func setupConfig(ctx context.Context, rawConfig []byte) (*BigChange, error) {
 		return nil, err
 	}
 
-	if bigChange.Domains == nil {
-		log.Error("missing or empty config field", "field", "BigChange.Domains")
-		return nil, fmt.Errorf("missing or empty config field")
-	}
 	if bigChange.Settings == nil {
 		log.Error("missing or empty config field", "field", "BigChange.Settings")
 		return nil, fmt.Errorf("missing or empty config field")
 	}
+	// Domains can be imported from CODEOWNERS later on
+	if bigChange.Domains == nil && bigChange.Settings.CodeOwners == nil {
+		log.Error("missing or empty config field", "field", "BigChange.Domains")
+		return nil, fmt.Errorf("missing or empty config field")
+	}
 
 	if bigChange.Settings.MainBranch == "" {
 		log.Error("missing or empty config field", "field", "BigChange.Settings.MainBranch")
@@ -38,12 +40,177 @@ func setupConfig(ctx context.Context, rawConfig []byte) (*BigChange, error) {
 		return nil, fmt.Errorf("missing or empty config field")
 	}
 
+	switch bigChange.Settings.AssignmentStrategy {
+	case "":
+		bigChange.Settings.AssignmentStrategy = FirstMatch
+	case FirstMatch, MostSpecific:
+	default:
+		log.Error("invalid config field",
+			"field", "BigChange.Settings.AssignmentStrategy",
+			"value", bigChange.Settings.AssignmentStrategy)
+		return nil, fmt.Errorf("invalid config field")
+	}
+
+	switch bigChange.Settings.RenamePolicy {
+	case "":
+		bigChange.Settings.RenamePolicy = RenameSplit
+	case RenameSplit, RenameDestination, RenameSource:
+	default:
+		log.Error("invalid config field",
+			"field", "BigChange.Settings.RenamePolicy",
+			"value", bigChange.Settings.RenamePolicy)
+		return nil, fmt.Errorf("invalid config field")
+	}
+
+	if codeOwners := bigChange.Settings.CodeOwners; codeOwners != nil {
+		switch codeOwners.GroupBy {
+		case "":
+			codeOwners.GroupBy = GroupByOwner
+		case GroupByOwner, GroupByRule:
+		default:
+			log.Error("invalid config field",
+				"field", "BigChange.Settings.CodeOwners.GroupBy",
+				"value", codeOwners.GroupBy)
+			return nil, fmt.Errorf("invalid config field")
+		}
+	}
+
+	if autoMerge := bigChange.Settings.AutoMerge; autoMerge != nil {
+		switch autoMerge.Method {
+		case "":
+			autoMerge.Method = MergeMethodMerge
+		case MergeMethodMerge, MergeMethodSquash, MergeMethodRebase:
+		default:
+			log.Error("invalid config field",
+				"field", "BigChange.Settings.AutoMerge.Method",
+				"value", autoMerge.Method)
+			return nil, fmt.Errorf("invalid config field")
+		}
+	}
+
+	if tracking := bigChange.Settings.Tracking; tracking != nil {
+		switch tracking.Type {
+		case "":
+			tracking.Type = TrackingIssue
+		case TrackingIssue, TrackingPr:
+		default:
+			log.Error("invalid config field",
+				"field", "BigChange.Settings.Tracking.Type",
+				"value", tracking.Type)
+			return nil, fmt.Errorf("invalid config field")
+		}
+		if tracking.Title == "" {
+			tracking.Title = defaultTrackingTitle
+		}
+		if bigChange.Settings.TrackingTemplate == "" {
+			bigChange.Settings.TrackingTemplate = defaultTrackingTemplate
+		}
+	}
+
+	if bigChange.Settings.RequiredReviewers && !supportsRequiredReviewers(platform) {
+		log.Error("invalid config field",
+			"field", "BigChange.Settings.RequiredReviewers",
+			"error", &UnsupportedFeatureError{Feature: "required reviewers"})
+		return nil, fmt.Errorf("invalid config field")
+	}
+	if len(bigChange.Settings.Labels) > 0 && !supportsLabels(platform) {
+		log.Error("invalid config field",
+			"field", "BigChange.Settings.Labels",
+			"error", &UnsupportedFeatureError{Feature: "labels"})
+		return nil, fmt.Errorf("invalid config field")
+	}
+	if bigChange.Settings.Milestone != "" && !supportsMilestone(platform) {
+		log.Error("invalid config field",
+			"field", "BigChange.Settings.Milestone",
+			"error", &UnsupportedFeatureError{Feature: "milestone"})
+		return nil, fmt.Errorf("invalid config field")
+	}
+	if len(bigChange.Settings.WorkItems) > 0 && !supportsWorkItems(platform) {
+		log.Error("invalid config field",
+			"field", "BigChange.Settings.WorkItems",
+			"error", &UnsupportedFeatureError{Feature: "work items"})
+		return nil, fmt.Errorf("invalid config field")
+	}
+	if autoMerge := bigChange.Settings.AutoMerge; autoMerge != nil {
+		if !supportsAutoMerge(platform) {
+			log.Error("invalid config field",
+				"field", "BigChange.Settings.AutoMerge",
+				"error", &UnsupportedFeatureError{Feature: "auto-merge"})
+			return nil, fmt.Errorf("invalid config field")
+		}
+		if !supportsMergeMethod(platform, autoMerge.Method) {
+			log.Error("invalid config field",
+				"field", "BigChange.Settings.AutoMerge.Method",
+				"error", &UnsupportedFeatureError{Feature: string(autoMerge.Method) + " auto-merge"})
+			return nil, fmt.Errorf("invalid config field")
+		}
+	}
+
+	// A branchless platform reviews each domain as a single commit
+	if bigChange.Settings.PreserveHistory && isBranchless(platform) {
+		log.Error("invalid config field",
+			"field", "BigChange.Settings.PreserveHistory",
+			"error", &UnsupportedFeatureError{Feature: "preserved history"})
+		return nil, fmt.Errorf("invalid config field")
+	}
+
+	if gerrit := bigChange.Settings.Gerrit; gerrit != nil && gerrit.Topic == "" {
+		gerrit.Topic = bigChange.Id
+	}
+
 	for _, domain := range bigChange.Domains {
-		if domain.Path == "" {
+		if len(domain.includePaths()) == 0 {
 			log.Error("missing or empty config field", "domain name", domain.Name, "field", "Domain.Path")
 			return nil, fmt.Errorf("missing or empty config field")
 		}
+		if err := validateDomainPaths(ctx, domain, "Domain.Paths", domain.includePaths()); err != nil {
+			return nil, err
+		}
+		if err := validateDomainPaths(ctx, domain, "Domain.ExcludePaths", domain.ExcludePaths); err != nil {
+			return nil, err
+		}
+		if err := validateDomainFeatures(ctx, domain, platform); err != nil {
+			return nil, err
+		}
 	}
 
 	return bigChange, nil
 }
+
+func validateDomainPaths(ctx context.Context, domain *Domain, field string, domainPaths []string) error {
+	log := LoggerFromContext(ctx)
+
+	for _, domainPath := range domainPaths {
+		if domainPath == "" {
+			log.Error("missing or empty config field", "domain name", domain.Name, "field", field)
+			return fmt.Errorf("missing or empty config field")
+		}
+		if !validateDomainPath(domainPath) {
+			log.Error("invalid glob pattern", "domain name", domain.Name, "field", field, "path", domainPath)
+			return fmt.Errorf("invalid config field")
+		}
+	}
+	return nil
+}
+
+// Same checks as the settings labels, milestone and work items
+func validateDomainFeatures(ctx context.Context, domain *Domain, platform Platform) error {
+	log := LoggerFromContext(ctx)
+
+	var feature, field string
+	switch {
+	case len(domain.Labels) > 0 && !supportsLabels(platform):
+		feature, field = "labels", "Domain.Labels"
+	case domain.Milestone != "" && !supportsMilestone(platform):
+		feature, field = "milestone", "Domain.Milestone"
+	case len(domain.WorkItems) > 0 && !supportsWorkItems(platform):
+		feature, field = "work items", "Domain.WorkItems"
+	default:
+		return nil
+	}
+	log.Error("invalid config field",
+		"domain name", domain.Name,
+		"field", field,
+		"error", &UnsupportedFeatureError{Feature: feature})
+	return fmt.Errorf("invalid config field")
+}
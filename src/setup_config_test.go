@@ -12,6 +12,7 @@ import (
 var setupConfigTests = []struct {
 	description       string
 	given             []byte
+	platform          Platform
 	expectedBigChange *BigChange
 	expectedErr       error
 }{
@@ -32,6 +33,35 @@ var setupConfigTests = []struct {
 		})),
 		expectedErr: fmt.Errorf("missing or empty config field"),
 	},
+	{
+		description: "Happy path - domains imported from CODEOWNERS",
+		given: marshalBigChange(fixtureBigChange(func(bc *BigChange) {
+			bc.Domains = nil
+			bc.Settings.CodeOwners = &CodeOwners{Path: ".github/CODEOWNERS"}
+		})),
+		expectedBigChange: fixtureBigChange(func(bc *BigChange) {
+			bc.Domains = nil
+			bc.Settings.CodeOwners = &CodeOwners{Path: ".github/CODEOWNERS", GroupBy: GroupByOwner}
+		}),
+	},
+	{
+		description: "Happy path - gerrit topic defaults to the change id",
+		given: marshalBigChange(fixtureBigChange(func(bc *BigChange) {
+			bc.Id = "big-change"
+			bc.Settings.Gerrit = &GerritSettings{BaseUrl: "https://gerrit.example.com", Project: "repo"}
+		})),
+		expectedBigChange: fixtureBigChange(func(bc *BigChange) {
+			bc.Id = "big-change"
+			bc.Settings.Gerrit = &GerritSettings{BaseUrl: "https://gerrit.example.com", Project: "repo", Topic: "big-change"}
+		}),
+	},
+	{
+		description: "fail because invalid Settings.CodeOwners.GroupBy",
+		given: marshalBigChange(fixtureBigChange(func(bc *BigChange) {
+			bc.Settings.CodeOwners = &CodeOwners{GroupBy: "folder"}
+		})),
+		expectedErr: fmt.Errorf("invalid config field"),
+	},
 	{
 		description: "fail because mandatory field is missing: Settings",
 		given: marshalBigChange(fixtureBigChange(func(bc *BigChange) {
@@ -69,6 +99,225 @@ var setupConfigTests = []struct {
 		})),
 		expectedErr: fmt.Errorf("missing or empty config field"),
 	},
+	{
+		description: "Happy path - domain with paths and exclude paths only",
+		given: marshalBigChange(fixtureBigChange(func(bc *BigChange) {
+			bc.Domains[0].Path = ""
+			bc.Domains[0].Paths = []string{"domains/dom1/", "libs/dom1/"}
+			bc.Domains[0].ExcludePaths = []string{"**/generated/"}
+		})),
+		expectedBigChange: fixtureBigChange(func(bc *BigChange) {
+			bc.Domains[0].Path = ""
+			bc.Domains[0].Paths = []string{"domains/dom1/", "libs/dom1/"}
+			bc.Domains[0].ExcludePaths = []string{"**/generated/"}
+		}),
+	},
+	{
+		description: "Happy path - default assignment strategy",
+		given: marshalBigChange(fixtureBigChange(func(bc *BigChange) {
+			bc.Settings.AssignmentStrategy = ""
+		})),
+		expectedBigChange: fixtureBigChange(),
+	},
+	{
+		description: "Happy path - most specific assignment strategy",
+		given: marshalBigChange(fixtureBigChange(func(bc *BigChange) {
+			bc.Settings.AssignmentStrategy = MostSpecific
+		})),
+		expectedBigChange: fixtureBigChange(func(bc *BigChange) {
+			bc.Settings.AssignmentStrategy = MostSpecific
+		}),
+	},
+	{
+		description: "fail because invalid Settings.AssignmentStrategy",
+		given: marshalBigChange(fixtureBigChange(func(bc *BigChange) {
+			bc.Settings.AssignmentStrategy = "random"
+		})),
+		expectedErr: fmt.Errorf("invalid config field"),
+	},
+	{
+		description: "Happy path - default rename policy",
+		given: marshalBigChange(fixtureBigChange(func(bc *BigChange) {
+			bc.Settings.RenamePolicy = ""
+		})),
+		expectedBigChange: fixtureBigChange(),
+	},
+	{
+		description: "fail because invalid Settings.RenamePolicy",
+		given: marshalBigChange(fixtureBigChange(func(bc *BigChange) {
+			bc.Settings.RenamePolicy = "both"
+		})),
+		expectedErr: fmt.Errorf("invalid config field"),
+	},
+	{
+		description: "Happy path - auto-merge method defaults to merge",
+		given: marshalBigChange(fixtureBigChange(func(bc *BigChange) {
+			bc.Settings.AutoMerge = &AutoMerge{DeleteBranch: true}
+		})),
+		expectedBigChange: fixtureBigChange(func(bc *BigChange) {
+			bc.Settings.AutoMerge = &AutoMerge{Method: MergeMethodMerge, DeleteBranch: true}
+		}),
+	},
+	{
+		description: "fail because invalid Settings.AutoMerge.Method",
+		given: marshalBigChange(fixtureBigChange(func(bc *BigChange) {
+			bc.Settings.AutoMerge = &AutoMerge{Method: "fast-forward"}
+		})),
+		expectedErr: fmt.Errorf("invalid config field"),
+	},
+	{
+		description: "Happy path - rebase auto-merge with azure-api",
+		given: marshalBigChange(fixtureBigChange(func(bc *BigChange) {
+			bc.Settings.AutoMerge = &AutoMerge{Method: MergeMethodRebase}
+		})),
+		platform: AzureApi{},
+		expectedBigChange: fixtureBigChange(func(bc *BigChange) {
+			bc.Settings.AutoMerge = &AutoMerge{Method: MergeMethodRebase}
+		}),
+	},
+	{
+		description: "fail because Settings.AutoMerge not supported by Bitbucket",
+		given: marshalBigChange(fixtureBigChange(func(bc *BigChange) {
+			bc.Settings.AutoMerge = &AutoMerge{}
+		})),
+		platform:    Bitbucket{},
+		expectedErr: fmt.Errorf("invalid config field"),
+	},
+	{
+		description: "fail because rebase Settings.AutoMerge.Method not supported by the Azure CLI",
+		given: marshalBigChange(fixtureBigChange(func(bc *BigChange) {
+			bc.Settings.AutoMerge = &AutoMerge{Method: MergeMethodRebase}
+		})),
+		platform:    Azure{},
+		expectedErr: fmt.Errorf("invalid config field"),
+	},
+	{
+		description: "Happy path - tracking defaults to an issue",
+		given: marshalBigChange(fixtureBigChange(func(bc *BigChange) {
+			bc.Settings.Tracking = &Tracking{}
+		})),
+		expectedBigChange: fixtureBigChange(func(bc *BigChange) {
+			bc.Settings.TrackingTemplate = defaultTrackingTemplate
+			bc.Settings.Tracking = &Tracking{Type: TrackingIssue, Title: defaultTrackingTitle}
+		}),
+	},
+	{
+		description: "fail because invalid Settings.Tracking.Type",
+		given: marshalBigChange(fixtureBigChange(func(bc *BigChange) {
+			bc.Settings.Tracking = &Tracking{Type: "wiki"}
+		})),
+		expectedErr: fmt.Errorf("invalid config field"),
+	},
+	{
+		description: "Happy path - required reviewers with the Azure CLI",
+		given: marshalBigChange(fixtureBigChange(func(bc *BigChange) {
+			bc.Settings.RequiredReviewers = true
+		})),
+		platform: Azure{},
+		expectedBigChange: fixtureBigChange(func(bc *BigChange) {
+			bc.Settings.RequiredReviewers = true
+		}),
+	},
+	{
+		description: "fail because Settings.RequiredReviewers not supported by the platform",
+		given: marshalBigChange(fixtureBigChange(func(bc *BigChange) {
+			bc.Settings.RequiredReviewers = true
+		})),
+		platform:    GitHub{},
+		expectedErr: fmt.Errorf("invalid config field"),
+	},
+	{
+		description: "Happy path - labels and milestone on GitHub",
+		given: marshalBigChange(fixtureBigChange(func(bc *BigChange) {
+			bc.Settings.Labels = []string{"big-change"}
+			bc.Settings.Milestone = "v2.0"
+		})),
+		expectedBigChange: fixtureBigChange(func(bc *BigChange) {
+			bc.Settings.Labels = []string{"big-change"}
+			bc.Settings.Milestone = "v2.0"
+		}),
+	},
+	{
+		description: "Happy path - labels and work items with the Azure CLI",
+		given: marshalBigChange(fixtureBigChange(func(bc *BigChange) {
+			bc.Settings.Labels = []string{"big-change"}
+			bc.Domains[0].WorkItems = []int{1234}
+		})),
+		platform: Azure{},
+		expectedBigChange: fixtureBigChange(func(bc *BigChange) {
+			bc.Settings.Labels = []string{"big-change"}
+			bc.Domains[0].WorkItems = []int{1234}
+		}),
+	},
+	{
+		description: "fail because Settings.Labels not supported by GitLab",
+		given: marshalBigChange(fixtureBigChange(func(bc *BigChange) {
+			bc.Settings.Labels = []string{"big-change"}
+		})),
+		platform:    GitLab{},
+		expectedErr: fmt.Errorf("invalid config field"),
+	},
+	{
+		description: "fail because Domain.Labels not supported by Gitea",
+		given: marshalBigChange(fixtureBigChange(func(bc *BigChange) {
+			bc.Domains[0].Labels = []string{"domain:dom1"}
+		})),
+		platform:    Gitea{},
+		expectedErr: fmt.Errorf("invalid config field"),
+	},
+	{
+		description: "fail because Settings.Milestone not supported by Azure DevOps",
+		given: marshalBigChange(fixtureBigChange(func(bc *BigChange) {
+			bc.Settings.Milestone = "v2.0"
+		})),
+		platform:    AzureApi{},
+		expectedErr: fmt.Errorf("invalid config field"),
+	},
+	{
+		description: "fail because Domain.Milestone not supported by the Azure CLI",
+		given: marshalBigChange(fixtureBigChange(func(bc *BigChange) {
+			bc.Domains[0].Milestone = "v2.0"
+		})),
+		platform:    Azure{},
+		expectedErr: fmt.Errorf("invalid config field"),
+	},
+	{
+		description: "fail because Settings.WorkItems not supported by GitHub",
+		given: marshalBigChange(fixtureBigChange(func(bc *BigChange) {
+			bc.Settings.WorkItems = []int{1234}
+		})),
+		platform:    GitHubApi{},
+		expectedErr: fmt.Errorf("invalid config field"),
+	},
+	{
+		description: "fail because Settings.PreserveHistory not supported by Gerrit",
+		given: marshalBigChange(fixtureBigChange(func(bc *BigChange) {
+			bc.Settings.PreserveHistory = true
+		})),
+		platform:    Gerrit{},
+		expectedErr: fmt.Errorf("invalid config field"),
+	},
+	{
+		description: "fail because empty Domain.Paths entry",
+		given: marshalBigChange(fixtureBigChange(func(bc *BigChange) {
+			bc.Domains[0].Paths = []string{""}
+		})),
+		expectedErr: fmt.Errorf("missing or empty config field"),
+	},
+	{
+		description: "fail because invalid glob pattern in Domain.ExcludePaths",
+		given: marshalBigChange(fixtureBigChange(func(bc *BigChange) {
+			bc.Domains[0].ExcludePaths = []string{"domains/{dom1/"}
+		})),
+		expectedErr: fmt.Errorf("invalid config field"),
+	},
+	{
+		description: "fail because invalid glob pattern in Domain.Path",
+		given: marshalBigChange(fixtureBigChange(func(bc *BigChange) {
+			bc.Domains[0].Path = "domains/[dom1/"
+		})),
+		expectedErr: fmt.Errorf("invalid config field"),
+	},
 }
 
 func marshalBigChange(bigChange *BigChange) []byte {
@@ -81,7 +330,11 @@ func TestSetupConfig(t *testing.T) {
 
 	for _, tt := range setupConfigTests {
 		t.Run(tt.description, func(t *testing.T) {
-			gotBigChange, gotErr := setupConfig(ctxWithSilentLogger, tt.given)
+			platform := tt.platform
+			if platform == nil {
+				platform = GitHub{}
+			}
+			gotBigChange, gotErr := setupConfig(ctxWithSilentLogger, tt.given, platform)
 
 			// We get an error when we don't expect it or we don't get one when we expect it
 			if tt.expectedErr != nil != (gotErr != nil) {
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const defaultStateDir = ".bit/state"
+
+// Progress of a run, persisted after each completed step so a failed run can be resumed
+type RunState struct {
+	ChangeId string `json:"changeId"`
+	// Keyed by the domain branch name
+	Domains map[string]*DomainState `json:"domains"`
+
+	mu sync.Mutex
+}
+
+type DomainState struct {
+	Branch    string `json:"branch"`
+	CommitSha string `json:"commitSha"`
+	Pushed    bool   `json:"pushed"`
+	PrUrl     string `json:"prUrl"`
+	// Set once the labels, milestone, work items, auto-merge and reviewers are applied to the PR
+	PrSetUp bool `json:"prSetUp"`
+	// Set by sync once the PR is merged, the domain is not split anymore
+	Merged bool `json:"merged"`
+}
+
+type LoadStateFunc func(context.Context, string) (*RunState, error)
+type SaveStateFunc func(context.Context, *RunState) error
+type DeleteStateFunc func(context.Context, string) error
+
+type StateOps struct {
+	loadState   LoadStateFunc
+	saveState   SaveStateFunc
+	deleteState DeleteStateFunc
+}
+
+func newRunState(changeId string) *RunState {
+	return &RunState{
+		ChangeId: changeId,
+		Domains:  make(map[string]*DomainState),
+	}
+}
+
+// Returns a copy of the domain state, a domain without state has not completed any step yet
+func (state *RunState) domain(branchName string) DomainState {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	if domainState, found := state.Domains[branchName]; found {
+		return *domainState
+	}
+	return DomainState{Branch: branchName}
+}
+
+// Records a completed step and persists the whole state right away
+func (bit *BigIsTiny) recordStep(ctx context.Context, state *RunState, branchName string, step func(*DomainState)) error {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	domainState, found := state.Domains[branchName]
+	if !found {
+		domainState = &DomainState{Branch: branchName}
+		state.Domains[branchName] = domainState
+	}
+	step(domainState)
+
+	return bit.stateOps.saveState(ctx, state)
+}
+
+// State files are stored in `<stateDir>/<change_id>.json`
+func newFileStateOps(stateDir string) *StateOps {
+	return &StateOps{
+		loadState: func(ctx context.Context, changeId string) (*RunState, error) {
+			return loadStateFile(ctx, statePath(stateDir, changeId))
+		},
+		saveState: func(ctx context.Context, state *RunState) error {
+			return saveStateFile(ctx, statePath(stateDir, state.ChangeId), state)
+		},
+		deleteState: func(ctx context.Context, changeId string) error {
+			err := os.Remove(statePath(stateDir, changeId))
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				log := LoggerFromContext(ctx)
+				log.Error("failed to delete state file", "error", err)
+				return err
+			}
+			return nil
+		},
+	}
+}
+
+func statePath(stateDir string, changeId string) string {
+	if changeId == "" {
+		changeId = "default"
+	}
+	return filepath.Join(stateDir, changeId+".json")
+}
+
+// Returns a nil state when no previous run was recorded
+func loadStateFile(ctx context.Context, path string) (*RunState, error) {
+	log := LoggerFromContext(ctx)
+
+	rawState, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		log.Error("failed to read state file", "path", path, "error", err)
+		return nil, err
+	}
+
+	state := &RunState{}
+	if err := json.Unmarshal(rawState, state); err != nil {
+		log.Error("failed to unmarshal state file", "path", path, "error", err)
+		return nil, err
+	}
+	if state.Domains == nil {
+		state.Domains = make(map[string]*DomainState)
+	}
+	return state, nil
+}
+
+func saveStateFile(ctx context.Context, path string, state *RunState) error {
+	log := LoggerFromContext(ctx)
+
+	rawState, err := json.MarshalIndent(state, "", "    ")
+	if err != nil {
+		log.Error("failed to marshal state", "error", err)
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		log.Error("failed to create state directory", "path", path, "error", err)
+		return err
+	}
+	// State files must never show up as changes to split
+	gitIgnorePath := filepath.Join(filepath.Dir(path), ".gitignore")
+	if _, err := os.Stat(gitIgnorePath); errors.Is(err, fs.ErrNotExist) {
+		if err := os.WriteFile(gitIgnorePath, []byte("*\n"), 0666); err != nil {
+			log.Error("failed to write state directory .gitignore", "path", gitIgnorePath, "error", err)
+			return err
+		}
+	}
+
+	// Written in a temporary file first so a crash never leaves a truncated state
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, rawState, 0666); err != nil {
+		log.Error("failed to write state file", "path", tmpPath, "error", err)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		log.Error("failed to write state file", "path", path, "error", err)
+		return err
+	}
+	return nil
+}
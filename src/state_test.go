@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestFileStateOps(t *testing.T) {
+	ctxWithSilentLogger := ContextWithSilentLogger(context.Background())
+	stateOps := newFileStateOps(t.TempDir())
+
+	// No previous run
+	gotState, gotErr := stateOps.loadState(ctxWithSilentLogger, "BIT001")
+	if gotErr != nil || gotState != nil {
+		t.Errorf("got '%v' '%v', want '<nil>' '<nil>'", gotState, gotErr)
+	}
+
+	savedState := fixtureRunState()
+	savedState.ChangeId = "BIT001"
+	if err := stateOps.saveState(ctxWithSilentLogger, savedState); err != nil {
+		t.Fatalf("got '%v', want '<nil>'", err)
+	}
+
+	gotState, gotErr = stateOps.loadState(ctxWithSilentLogger, "BIT001")
+	if gotErr != nil {
+		t.Errorf("got '%v', want '<nil>'", gotErr)
+	}
+	diff := cmp.Diff(gotState, savedState, cmpopts.IgnoreUnexported(RunState{}))
+	if diff != "" {
+		t.Errorf("%v", diff)
+	}
+
+	if err := stateOps.deleteState(ctxWithSilentLogger, "BIT001"); err != nil {
+		t.Errorf("got '%v', want '<nil>'", err)
+	}
+	gotState, gotErr = stateOps.loadState(ctxWithSilentLogger, "BIT001")
+	if gotErr != nil || gotState != nil {
+		t.Errorf("got '%v' '%v', want '<nil>' '<nil>'", gotState, gotErr)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"golang.org/x/sync/errgroup"
+)
+
+type PrState string
+
+const (
+	PrStateNone      PrState = "none"
+	PrStateOpen      PrState = "open"
+	PrStateDraft     PrState = "draft"
+	PrStateMerged    PrState = "merged"
+	PrStateAbandoned PrState = "abandoned"
+)
+
+type ReviewState string
+
+const (
+	ReviewNone             ReviewState = "none"
+	ReviewRequired         ReviewState = "required"
+	ReviewApproved         ReviewState = "approved"
+	ReviewChangesRequested ReviewState = "changes_requested"
+)
+
+type ChecksState string
+
+const (
+	ChecksNone    ChecksState = "none"
+	ChecksPending ChecksState = "pending"
+	ChecksSuccess ChecksState = "success"
+	ChecksFailure ChecksState = "failure"
+)
+
+type MergeableState string
+
+const (
+	MergeableUnknown     MergeableState = "unknown"
+	MergeableYes         MergeableState = "mergeable"
+	MergeableConflicting MergeableState = "conflicting"
+)
+
+type PrStatus struct {
+	Url       string         `json:"url"`
+	State     PrState        `json:"state"`
+	Review    ReviewState    `json:"review"`
+	Checks    ChecksState    `json:"checks"`
+	Mergeable MergeableState `json:"mergeable"`
+}
+
+type DomainStatus struct {
+	Domain string `json:"domain"`
+	Branch string `json:"branch"`
+	PrStatus
+}
+
+type ExportStatusFunc func(context.Context, *Flags, []*DomainStatus) error
+
+func noPrStatus() *PrStatus {
+	return &PrStatus{
+		State:     PrStateNone,
+		Review:    ReviewNone,
+		Checks:    ChecksNone,
+		Mergeable: MergeableUnknown,
+	}
+}
+
+// Reports the state of the PR of each domain, nothing is changed on the repository or the platform
+func (bit *BigIsTiny) status(ctx context.Context, config *BigChange) error {
+	statuses := make([]*DomainStatus, len(config.Domains))
+
+	errGrp := new(errgroup.Group)
+	for i, domain := range config.Domains {
+		domain.initDomain(config)
+
+		errGrp.Go(func() error {
+			prStatus, err := bit.gitOps.platform.PrStatus(ctx, config.Settings, domain.Branch.Name)
+			if err != nil {
+				log := LoggerFromContext(ctx)
+				log.Error("failed to get Pull Request status", "branch", domain.Branch.Name)
+				return err
+			}
+			statuses[i] = &DomainStatus{
+				Domain:   domain.Name,
+				Branch:   domain.Branch.Name,
+				PrStatus: *prStatus,
+			}
+			return nil
+		})
+	}
+	if err := errGrp.Wait(); err != nil {
+		return err
+	}
+
+	return bit.exportStatus(ctx, bit.flags, statuses)
+}
+
+func exportStatus(ctx context.Context, flags *Flags, statuses []*DomainStatus) (err error) {
+	var fdOut *os.File
+	if flags.FileOut == "" {
+		fdOut = os.Stdout
+	} else {
+		fdOut, err = os.OpenFile(flags.FileOut, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+		if err != nil {
+			log := LoggerFromContext(ctx)
+			log.Error("failed to create status file", "path", flags.FileOut, "error", err)
+			return err
+		}
+		defer fdOut.Close()
+	}
+
+	if flags.Format == JsonFormat {
+		jsonFormattedStatus, err := json.MarshalIndent(statuses, "", "    ")
+		if err != nil {
+			log := LoggerFromContext(ctx)
+			log.Error("failed to marshal status", "error", err)
+			return err
+		}
+		fmt.Fprintln(fdOut, string(jsonFormattedStatus))
+		return nil
+	}
+
+	table := tabwriter.NewWriter(fdOut, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "DOMAIN\tBRANCH\tSTATE\tREVIEW\tCHECKS\tMERGEABLE\tURL")
+	for _, status := range statuses {
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			status.Domain, status.Branch, status.State, status.Review, status.Checks, status.Mergeable, status.Url)
+	}
+	return table.Flush()
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func checkExportStatus(expectedStatuses []*DomainStatus) ExportStatusFunc {
+	return func(ctx context.Context, flags *Flags, statuses []*DomainStatus) error {
+		diff := cmp.Diff(expectedStatuses, statuses)
+		if diff != "" {
+			return fmt.Errorf("%v", diff)
+		}
+		return nil
+	}
+}
+
+var statusTests = []struct {
+	description string
+	gitOps      *GitOps
+	expectedErr error
+}{
+	{
+		description: "Happy path",
+		gitOps: fixtureReadOnlyGitOps(func(g *GitOps) {
+			platformOf(g).prStatus = func(ctx context.Context, s1 *Settings, s2 string) (*PrStatus, error) {
+				if s2 == "bit-dom3-big-change-split" {
+					return noPrStatus(), nil
+				}
+				return &PrStatus{
+					Url:       s2 + "/pr",
+					State:     PrStateOpen,
+					Review:    ReviewApproved,
+					Checks:    ChecksSuccess,
+					Mergeable: MergeableYes,
+				}, nil
+			}
+		}),
+	},
+	{
+		description: "Fail on prStatus",
+		gitOps: fixtureReadOnlyGitOps(func(g *GitOps) {
+			platformOf(g).prStatus = func(ctx context.Context, s1 *Settings, s2 string) (*PrStatus, error) {
+				return nil, fmt.Errorf("prStatus failed")
+			}
+		}),
+		expectedErr: fmt.Errorf("prStatus failed"),
+	},
+}
+
+func TestStatus(t *testing.T) {
+	ctxWithSilentLogger := ContextWithSilentLogger(context.Background())
+
+	expectedStatuses := []*DomainStatus{
+		{
+			Domain: "dom1",
+			Branch: "bit-dom1-big-change-split",
+			PrStatus: PrStatus{
+				Url:       "bit-dom1-big-change-split/pr",
+				State:     PrStateOpen,
+				Review:    ReviewApproved,
+				Checks:    ChecksSuccess,
+				Mergeable: MergeableYes,
+			},
+		},
+		{
+			Domain: "dom2",
+			Branch: "bit-dom2-big-change-split",
+			PrStatus: PrStatus{
+				Url:       "bit-dom2-big-change-split/pr",
+				State:     PrStateOpen,
+				Review:    ReviewApproved,
+				Checks:    ChecksSuccess,
+				Mergeable: MergeableYes,
+			},
+		},
+		{
+			Domain:   "dom3",
+			Branch:   "bit-dom3-big-change-split",
+			PrStatus: *noPrStatus(),
+		},
+	}
+
+	for _, tt := range statusTests {
+		t.Run(tt.description, func(t *testing.T) {
+			bit := &BigIsTiny{
+				exportStatus: checkExportStatus(expectedStatuses),
+				flags:        fixtureFlags(func(f *Flags) { f.Command = StatusCommand }),
+				gitOps:       tt.gitOps,
+			}
+			gotErr := bit.status(ctxWithSilentLogger, fixtureBigChange())
+
+			// We get an error when we don't expect it or we don't get one when we expect it
+			if tt.expectedErr != nil != (gotErr != nil) {
+				t.Errorf("got '%v', want '%v'", gotErr, tt.expectedErr)
+			}
+			// We get a different error of what's expected
+			if tt.expectedErr != nil && gotErr != nil &&
+				tt.expectedErr.Error() != gotErr.Error() {
+				t.Errorf("got '%v', want '%v'", gotErr, tt.expectedErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// Updates the split branches and PRs after new commits landed on the big change branch
+func (bit *BigIsTiny) sync(ctx context.Context, config *BigChange) (err error) {
+	for _, domain := range config.Domains {
+		domain.initDomain(config)
+	}
+
+	// The steps of the previous runs are kept, merged domains are recorded in it
+	state, err := bit.stateOps.loadState(ctx, config.Id)
+	if err != nil {
+		return err
+	}
+	if state == nil {
+		state = newRunState(config.Id)
+	}
+
+	// Get the latest version of the big change, of the main branch and of the split branches
+	err = bit.gitOps.gitFetch(ctx, config.Settings.Remote)
+	if err != nil {
+		return err
+	}
+	// The local main branch is not updated by the fetch, the branches are rebuilt on the remote one
+	gitSettings := *config.Settings
+	gitSettings.MainBranch = fmt.Sprintf("%s/%s", config.Settings.Remote, config.Settings.MainBranch)
+
+	err = bit.collectChanges(ctx, config, &gitSettings)
+	if err != nil {
+		return err
+	}
+
+	if config.Settings.Tracking != nil {
+		err = bit.openTracking(ctx, config)
+		if err != nil {
+			return err
+		}
+	}
+
+	errGrp := new(errgroup.Group)
+	for _, domain := range config.Domains {
+		if domainState := state.domain(domain.Branch.Name); domainState.Merged {
+			domain.PullRequest.Url = domainState.PrUrl
+			continue
+		}
+
+		existingPrUrl, err := bit.gitOps.platform.FindPr(ctx, config.Settings, domain.Branch.Name)
+		if err != nil {
+			return err
+		}
+
+		if len(domain.Files) == 0 {
+			if existingPrUrl == "" {
+				continue
+			}
+			// The domain is not touched anymore by the big change
+			errGrp.Go(func() error {
+				return bit.closeDomain(ctx, config.Settings, domain)
+			})
+			continue
+		}
+
+		// Only open PRs are found, the domain files stay in the diff once its PR is merged
+		if existingPrUrl == "" {
+			merged, err := bit.isMerged(ctx, config.Settings, state, domain)
+			if err != nil {
+				return err
+			}
+			if merged {
+				continue
+			}
+		}
+
+		// The branch is rebuilt from the main branch so it only contains the latest changes
+		err = bit.createBranch(ctx, config, domain, &gitSettings, bit.gitOps.gitResetBranch)
+		if errors.Is(err, ErrNoChanges) {
+			skipDomain(ctx, domain)
+			if existingPrUrl != "" {
+				errGrp.Go(func() error {
+					return bit.closeDomain(ctx, config.Settings, domain)
+				})
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		commitSha, err := bit.gitOps.gitRevParse(ctx, domain.Branch.Name)
+		if err != nil {
+			return err
+		}
+		err = bit.recordStep(ctx, state, domain.Branch.Name, func(ds *DomainState) { ds.CommitSha = commitSha })
+		if err != nil {
+			return err
+		}
+
+		errGrp.Go(func() error {
+			if existingPrUrl == "" {
+				return bit.openDomain(ctx, config, state, domain)
+			}
+			return bit.updateDomain(ctx, config.Settings, state, domain, existingPrUrl)
+		})
+	}
+	if err := errGrp.Wait(); err != nil {
+		return err
+	}
+
+	if config.Settings.Tracking != nil {
+		err = bit.updateTracking(ctx, config)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = bit.exportResults(ctx, bit.flags, config)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// A merged PR is recorded in the state so the platform is not asked again on the next syncs
+func (bit *BigIsTiny) isMerged(ctx context.Context, settings *Settings, state *RunState, domain *Domain) (bool, error) {
+	log := LoggerFromContext(ctx)
+
+	prStatus, err := bit.gitOps.platform.PrStatus(ctx, settings, domain.Branch.Name)
+	if err != nil {
+		log.Error("failed to get Pull Request status", "branch", domain.Branch.Name)
+		return false, err
+	}
+	if prStatus.State != PrStateMerged {
+		return false, nil
+	}
+
+	log.Info("domain Pull Request already merged, skipping it", "branch", domain.Branch.Name, "url", prStatus.Url)
+	domain.PullRequest.Url = prStatus.Url
+	err = bit.recordStep(ctx, state, domain.Branch.Name, func(ds *DomainState) {
+		ds.PrUrl = prStatus.Url
+		ds.Merged = true
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (bit *BigIsTiny) openDomain(ctx context.Context, config *BigChange, state *RunState, domain *Domain) error {
+	err := bit.gitOps.gitPushSetUpstream(ctx, config.Settings.Remote, domain.Branch.Name)
+	if err != nil {
+		return err
+	}
+	err = bit.recordStep(ctx, state, domain.Branch.Name, func(ds *DomainState) { ds.Pushed = true })
+	if err != nil {
+		return err
+	}
+
+	return bit.createPullRequest(ctx, config, state, domain)
+}
+
+func (bit *BigIsTiny) updateDomain(ctx context.Context, settings *Settings, state *RunState, domain *Domain, prUrl string) error {
+	log := LoggerFromContext(ctx)
+	domain.PullRequest.Url = prUrl
+
+	// Rebuilt branches with the same content are not pushed again so reviews are not disturbed
+	if bit.sameTree(ctx, domain.Branch.Name, fmt.Sprintf("%s/%s", settings.Remote, domain.Branch.Name)) {
+		log.Debug("split branch already up to date", "branch", domain.Branch.Name)
+	} else {
+		err := bit.gitOps.gitPushForce(ctx, settings.Remote, domain.Branch.Name)
+		if err != nil {
+			return err
+		}
+	}
+	err := bit.recordStep(ctx, state, domain.Branch.Name, func(ds *DomainState) { ds.Pushed = true })
+	if err != nil {
+		return err
+	}
+
+	err = bit.gitOps.platform.UpdatePr(ctx, settings, domain.Branch.Name, domain.PullRequest.Title, domain.PullRequest.Body)
+	if err != nil {
+		log.Error("failed to update Pull Request", "branch", domain.Branch.Name)
+		return err
+	}
+	return bit.recordStep(ctx, state, domain.Branch.Name, func(ds *DomainState) { ds.PrUrl = prUrl })
+}
+
+func (bit *BigIsTiny) closeDomain(ctx context.Context, settings *Settings, domain *Domain) error {
+	log := LoggerFromContext(ctx)
+	log.Info("domain not touched anymore, closing its Pull Request", "branch", domain.Branch.Name)
+
+	err := bit.gitOps.platform.ClosePr(ctx, settings, domain.Branch.Name)
+	if err != nil {
+		log.Error("failed to close Pull Request", "branch", domain.Branch.Name)
+		return err
+	}
+	err = bit.gitOps.gitDeleteRemoteBranch(ctx, settings.Remote, domain.Branch.Name)
+	if err != nil {
+		return err
+	}
+	// The local branch may not exist when the split was done on another machine
+	_ = bit.gitOps.gitDeleteBranch(ctx, domain.Branch.Name)
+	return nil
+}
+
+func (bit *BigIsTiny) sameTree(ctx context.Context, ref string, otherRef string) bool {
+	tree, err := bit.gitOps.gitRevParse(ctx, ref+"^{tree}")
+	if err != nil {
+		return false
+	}
+	otherTree, err := bit.gitOps.gitRevParse(ctx, otherRef+"^{tree}")
+	if err != nil {
+		return false
+	}
+	return tree == otherTree
+}
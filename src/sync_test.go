@@ -0,0 +1,408 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type givenSync struct {
+	gitOps   *GitOps
+	stateOps *StateOps
+	config   *BigChange
+}
+
+// dom1 already has a PR and is still touched, dom2 is newly touched and
+// dom3 has a PR but is not touched anymore
+func fixtureSyncGitOps(calls *syncCalls, mods ...func(*GitOps)) *GitOps {
+	return fixtureGitOps(append([]func(*GitOps){func(g *GitOps) {
+		platformOf(g).findPr = func(ctx context.Context, s1 *Settings, s2 string) (string, error) {
+			switch s2 {
+			case "bit-dom1-big-change-split":
+				return "https://example.com/pr/1", nil
+			case "bit-dom3-big-change-split":
+				return "https://example.com/pr/3", nil
+			}
+			return "", nil
+		}
+		g.gitCreateBranch = func(ctx context.Context, s1, s2 string) error {
+			return fmt.Errorf("gitCreateBranch should not be called")
+		}
+		g.gitPushForce = func(ctx context.Context, s1, s2 string) error { calls.add("gitPushForce", s2); return nil }
+		g.gitPushSetUpstream = func(ctx context.Context, s1, s2 string) error { calls.add("gitPushSetUpstream", s2); return nil }
+		g.gitDeleteRemoteBranch = func(ctx context.Context, s1, s2 string) error { calls.add("gitDeleteRemoteBranch", s2); return nil }
+		platformOf(g).createPr = func(ctx context.Context, s1 *Settings, s2, s3, s4 string) (string, error) {
+			calls.add("createPr", s2)
+			return s2 + "/pr", nil
+		}
+		platformOf(g).updatePr = func(ctx context.Context, s1 *Settings, s2, s3, s4 string) error {
+			calls.add("updatePr", s2)
+			return nil
+		}
+		platformOf(g).closePr = func(ctx context.Context, s1 *Settings, s string) error { calls.add("abandonPr", s); return nil }
+	}}, mods...)...)
+}
+
+type syncCalls struct {
+	mu    sync.Mutex
+	calls map[string][]string
+}
+
+func (c *syncCalls) add(operation string, branch string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls[operation] = append(c.calls[operation], strings.TrimSuffix(branch, "-big-change-split"))
+}
+
+var syncTests = []struct {
+	description    string
+	given          func(*syncCalls) givenSync
+	expectedCalls  map[string][]string
+	expectedErr    error
+	expectedPrUrls []string
+}{
+	{
+		description: "Happy path - update, open and close PRs",
+		given: func(calls *syncCalls) givenSync {
+			return givenSync{
+				gitOps: fixtureSyncGitOps(calls),
+				config: fixtureBigChange(),
+			}
+		},
+		expectedCalls: map[string][]string{
+			"gitPushForce":          {"bit-dom1"},
+			"updatePr":              {"bit-dom1"},
+			"gitPushSetUpstream":    {"bit-dom2"},
+			"createPr":              {"bit-dom2"},
+			"abandonPr":             {"bit-dom3"},
+			"gitDeleteRemoteBranch": {"bit-dom3"},
+		},
+		expectedPrUrls: []string{"https://example.com/pr/1", "bit-dom2-big-change-split/pr", ""},
+	},
+	{
+		description: "Unchanged branches are not pushed again",
+		given: func(calls *syncCalls) givenSync {
+			return givenSync{
+				gitOps: fixtureSyncGitOps(calls, func(g *GitOps) {
+					g.gitRevParse = func(ctx context.Context, s string) (string, error) {
+						if strings.HasSuffix(s, "^{tree}") {
+							return "same-tree", nil
+						}
+						return s + "-sha", nil
+					}
+				}),
+				config: fixtureBigChange(func(bc *BigChange) {
+					bc.Domains = bc.Domains[:1]
+				}),
+			}
+		},
+		expectedCalls: map[string][]string{
+			"updatePr": {"bit-dom1"},
+		},
+		expectedPrUrls: []string{"https://example.com/pr/1"},
+	},
+	{
+		description: "Close the PRs of the domains whose replayed commits change nothing on the main branch",
+		given: func(calls *syncCalls) givenSync {
+			return givenSync{
+				gitOps: fixtureSyncGitOps(calls, func(g *GitOps) {
+					g.gitReplayCommits = func(ctx context.Context, s1, s2 string, files []string) (string, error) {
+						return "", ErrNoChanges
+					}
+				}),
+				config: fixtureBigChange(func(bc *BigChange) {
+					bc.Settings.PreserveHistory = true
+					bc.Domains = bc.Domains[:2]
+				}),
+			}
+		},
+		expectedCalls: map[string][]string{
+			"abandonPr":             {"bit-dom1"},
+			"gitDeleteRemoteBranch": {"bit-dom1"},
+		},
+		expectedPrUrls: []string{"", ""},
+	},
+	{
+		description: "Update the umbrella PR with the synced PRs",
+		given: func(calls *syncCalls) givenSync {
+			return givenSync{
+				gitOps: fixtureSyncGitOps(calls, func(g *GitOps) {
+					platformOf(g).findPr = func(ctx context.Context, s1 *Settings, s2 string) (string, error) {
+						switch s2 {
+						case "big-change-to-split":
+							return "https://example.com/pr/0", nil
+						case "bit-dom1-big-change-split":
+							return "https://example.com/pr/1", nil
+						}
+						return "", nil
+					}
+				}),
+				config: fixtureBigChange(func(bc *BigChange) {
+					bc.Domains = bc.Domains[:1]
+					bc.Settings.TrackingTemplate = defaultTrackingTemplate
+					bc.Settings.Tracking = &Tracking{Type: TrackingPr, Title: defaultTrackingTitle}
+				}),
+			}
+		},
+		expectedCalls: map[string][]string{
+			"gitPushForce": {"bit-dom1"},
+			"updatePr":     {"bit-dom1", "big-change-to-split"},
+		},
+		expectedPrUrls: []string{"https://example.com/pr/1"},
+	},
+	{
+		description: "Merged PRs are skipped and recorded in the existing run state",
+		given: func(calls *syncCalls) givenSync {
+			return givenSync{
+				gitOps: fixtureSyncGitOps(calls, func(g *GitOps) {
+					platformOf(g).prStatus = func(ctx context.Context, s1 *Settings, s2 string) (*PrStatus, error) {
+						if s2 != "bit-dom2-big-change-split" {
+							return nil, fmt.Errorf("prStatus should not be called for '%s'", s2)
+						}
+						return &PrStatus{Url: "https://example.com/pr/2", State: PrStateMerged}, nil
+					}
+				}),
+				stateOps: fixtureStateOps(func(so *StateOps) {
+					so.loadState = func(ctx context.Context, s string) (*RunState, error) {
+						state := newRunState("")
+						state.Domains["previous-branch"] = &DomainState{Branch: "previous-branch", Pushed: true}
+						return state, nil
+					}
+					so.saveState = func(ctx context.Context, rs *RunState) error {
+						if _, found := rs.Domains["previous-branch"]; !found {
+							return fmt.Errorf("the previous run state was not kept")
+						}
+						calls.add("saveState", "")
+						return nil
+					}
+				}),
+				config: fixtureBigChange(func(bc *BigChange) {
+					bc.Domains = bc.Domains[:2]
+				}),
+			}
+		},
+		expectedCalls: map[string][]string{
+			"gitPushForce": {"bit-dom1"},
+			"updatePr":     {"bit-dom1"},
+			"saveState":    {"", "", "", ""},
+		},
+		expectedPrUrls: []string{"https://example.com/pr/1", "https://example.com/pr/2"},
+	},
+	{
+		description: "Merged domains of the run state are not checked again",
+		given: func(calls *syncCalls) givenSync {
+			return givenSync{
+				gitOps: fixtureSyncGitOps(calls, func(g *GitOps) {
+					platformOf(g).findPr = func(ctx context.Context, s1 *Settings, s2 string) (string, error) {
+						return "", fmt.Errorf("findPr should not be called")
+					}
+				}),
+				stateOps: fixtureStateOps(func(so *StateOps) {
+					so.loadState = func(ctx context.Context, s string) (*RunState, error) {
+						state := newRunState("")
+						state.Domains["bit-dom2-big-change-split"] = &DomainState{
+							Branch: "bit-dom2-big-change-split",
+							PrUrl:  "https://example.com/pr/2",
+							Merged: true,
+						}
+						return state, nil
+					}
+				}),
+				config: fixtureBigChange(func(bc *BigChange) {
+					bc.Domains = bc.Domains[1:2]
+				}),
+			}
+		},
+		expectedCalls:  map[string][]string{},
+		expectedPrUrls: []string{"https://example.com/pr/2"},
+	},
+	{
+		description: "Branches are rebuilt on the fetched main branch",
+		given: func(calls *syncCalls) givenSync {
+			return givenSync{
+				gitOps: fixtureSyncGitOps(calls, func(g *GitOps) {
+					g.gitDiffNameStatus = func(ctx context.Context, from, to string) ([]byte, error) {
+						if from != "origin/main" {
+							return nil, fmt.Errorf("unexpected diff from '%s'", from)
+						}
+						return []byte("M\x00domains/dom1/file1\x00"), nil
+					}
+					g.gitCommitFiles = func(ctx context.Context, parent, source string, files []string, message string) (string, error) {
+						if parent != "origin/main" {
+							return "", fmt.Errorf("unexpected commit on '%s'", parent)
+						}
+						return "commit-sha", nil
+					}
+				}),
+				config: fixtureBigChange(func(bc *BigChange) {
+					bc.Domains = bc.Domains[:1]
+				}),
+			}
+		},
+		expectedCalls: map[string][]string{
+			"gitPushForce": {"bit-dom1"},
+			"updatePr":     {"bit-dom1"},
+		},
+		expectedPrUrls: []string{"https://example.com/pr/1"},
+	},
+	{
+		description: "Fail on loadState",
+		given: func(calls *syncCalls) givenSync {
+			return givenSync{
+				gitOps: fixtureSyncGitOps(calls),
+				stateOps: fixtureStateOps(func(so *StateOps) {
+					so.loadState = func(ctx context.Context, s string) (*RunState, error) { return nil, fmt.Errorf("loadState failed") }
+				}),
+				config: fixtureBigChange(),
+			}
+		},
+		expectedCalls: map[string][]string{},
+		expectedErr:   fmt.Errorf("loadState failed"),
+	},
+	{
+		description: "Fail on prStatus",
+		given: func(calls *syncCalls) givenSync {
+			return givenSync{
+				gitOps: fixtureSyncGitOps(calls, func(g *GitOps) {
+					platformOf(g).prStatus = func(ctx context.Context, s1 *Settings, s2 string) (*PrStatus, error) {
+						return nil, fmt.Errorf("prStatus failed")
+					}
+				}),
+				config: fixtureBigChange(func(bc *BigChange) {
+					bc.Domains = bc.Domains[1:2]
+				}),
+			}
+		},
+		expectedCalls: map[string][]string{},
+		expectedErr:   fmt.Errorf("prStatus failed"),
+	},
+	{
+		description: "Fail on gitFetch",
+		given: func(calls *syncCalls) givenSync {
+			return givenSync{
+				gitOps: fixtureSyncGitOps(calls, func(g *GitOps) {
+					g.gitFetch = func(ctx context.Context, s string) error { return fmt.Errorf("gitFetch failed") }
+				}),
+				config: fixtureBigChange(),
+			}
+		},
+		expectedCalls: map[string][]string{},
+		expectedErr:   fmt.Errorf("gitFetch failed"),
+	},
+	{
+		description: "Fail on findPr",
+		given: func(calls *syncCalls) givenSync {
+			return givenSync{
+				gitOps: fixtureSyncGitOps(calls, func(g *GitOps) {
+					platformOf(g).findPr = func(ctx context.Context, s1 *Settings, s2 string) (string, error) {
+						return "", fmt.Errorf("findPr failed")
+					}
+				}),
+				config: fixtureBigChange(),
+			}
+		},
+		expectedCalls: map[string][]string{},
+		expectedErr:   fmt.Errorf("findPr failed"),
+	},
+	{
+		description: "Fail on gitResetBranch",
+		given: func(calls *syncCalls) givenSync {
+			return givenSync{
+				gitOps: fixtureSyncGitOps(calls, func(g *GitOps) {
+					g.gitResetBranch = func(ctx context.Context, s1, s2 string) error {
+						return fmt.Errorf("gitResetBranch failed")
+					}
+				}),
+				config: fixtureBigChange(func(bc *BigChange) {
+					bc.Domains = bc.Domains[:1]
+				}),
+			}
+		},
+		expectedCalls: map[string][]string{},
+		expectedErr:   fmt.Errorf("gitResetBranch failed"),
+	},
+	{
+		description: "Fail on updatePr",
+		given: func(calls *syncCalls) givenSync {
+			return givenSync{
+				gitOps: fixtureSyncGitOps(calls, func(g *GitOps) {
+					platformOf(g).updatePr = func(ctx context.Context, s1 *Settings, s2, s3, s4 string) error {
+						return fmt.Errorf("updatePr failed")
+					}
+				}),
+				config: fixtureBigChange(func(bc *BigChange) {
+					bc.Domains = bc.Domains[:1]
+				}),
+			}
+		},
+		expectedCalls: map[string][]string{
+			"gitPushForce": {"bit-dom1"},
+		},
+		expectedErr: fmt.Errorf("updatePr failed"),
+	},
+	{
+		description: "Fail on abandonPr",
+		given: func(calls *syncCalls) givenSync {
+			return givenSync{
+				gitOps: fixtureSyncGitOps(calls, func(g *GitOps) {
+					platformOf(g).closePr = func(ctx context.Context, s1 *Settings, s string) error { return fmt.Errorf("abandonPr failed") }
+				}),
+				config: fixtureBigChange(func(bc *BigChange) {
+					bc.Domains = bc.Domains[2:]
+				}),
+			}
+		},
+		expectedCalls: map[string][]string{},
+		expectedErr:   fmt.Errorf("abandonPr failed"),
+	},
+}
+
+func TestSync(t *testing.T) {
+	ctxWithSilentLogger := ContextWithSilentLogger(context.Background())
+
+	for _, tt := range syncTests {
+		t.Run(tt.description, func(t *testing.T) {
+			calls := &syncCalls{calls: make(map[string][]string)}
+			given := tt.given(calls)
+			if given.stateOps == nil {
+				given.stateOps = fixtureStateOps()
+			}
+			var gotPrUrls []string
+			bit := &BigIsTiny{
+				exportResults: func(ctx context.Context, f *Flags, bc *BigChange) error {
+					for _, domain := range bc.Domains {
+						gotPrUrls = append(gotPrUrls, domain.PullRequest.Url)
+					}
+					return nil
+				},
+				flags:    fixtureFlags(func(f *Flags) { f.Command = SyncCommand }),
+				gitOps:   given.gitOps,
+				stateOps: given.stateOps,
+			}
+			gotErr := bit.sync(ctxWithSilentLogger, given.config)
+
+			// We get an error when we don't expect it or we don't get one when we expect it
+			if tt.expectedErr != nil != (gotErr != nil) {
+				t.Errorf("got '%v', want '%v'", gotErr, tt.expectedErr)
+			}
+			// We get a different error of what's expected
+			if tt.expectedErr != nil && gotErr != nil &&
+				tt.expectedErr.Error() != gotErr.Error() {
+				t.Errorf("got '%v', want '%v'", gotErr, tt.expectedErr)
+			}
+
+			diff := cmp.Diff(calls.calls, tt.expectedCalls)
+			if diff != "" {
+				t.Errorf("%v", diff)
+			}
+			diff = cmp.Diff(gotPrUrls, tt.expectedPrUrls)
+			if diff != "" {
+				t.Errorf("%v", diff)
+			}
+		})
+	}
+}
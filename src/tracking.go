@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+)
+
+type TrackingType string
+
+const (
+	TrackingIssue TrackingType = "issue"
+	TrackingPr    TrackingType = "pr"
+)
+
+const defaultTrackingTitle = "{{change_id}}: big change split"
+
+const defaultTrackingTemplate = "- [{{pr_checked}}] {{domain_id}} {{domain_name}} ({{team_names}}): {{pr_url}} {{pr_state}}"
+
+// Finds or creates the tracking item so its url can be linked from the PR bodies,
+// the checklist is filled by updateTracking once the PRs exist
+func (bit *BigIsTiny) openTracking(ctx context.Context, config *BigChange) error {
+	log := LoggerFromContext(ctx)
+	settings := config.Settings
+	tracking := settings.Tracking
+	platform := bit.gitOps.platform
+	title := config.generateFromTemplate(&Domain{}, tracking.Title)
+	description := config.generateFromTemplate(&Domain{}, tracking.Description)
+
+	var trackingUrl string
+	var err error
+	switch tracking.Type {
+	case TrackingIssue:
+		trackingUrl, err = platform.FindIssue(ctx, settings, title)
+		if err == nil && trackingUrl == "" {
+			trackingUrl, err = platform.CreateIssue(ctx, settings, title, description)
+		}
+	case TrackingPr:
+		if isBranchless(platform) {
+			err = &UnsupportedFeatureError{Feature: "umbrella PR"}
+			break
+		}
+		// An existing PR of the branch to split becomes the umbrella PR
+		trackingUrl, err = platform.FindPr(ctx, settings, settings.BranchToSplit)
+		if err == nil && trackingUrl == "" {
+			draftSettings := *settings
+			draftSettings.IsDraftPrs = true
+			trackingUrl, err = platform.CreatePr(ctx, &draftSettings, settings.BranchToSplit, title, description)
+		}
+	}
+	if err != nil {
+		log.Error("failed to open the tracking "+string(tracking.Type), "title", title, "error", err)
+		return err
+	}
+	log.Debug("tracking "+string(tracking.Type)+" opened", "url", trackingUrl)
+
+	config.TrackingUrl = trackingUrl
+	for _, domain := range config.Domains {
+		domain.PullRequest.Body = config.generateFromTemplate(domain, settings.PrDescTemplate)
+	}
+	return nil
+}
+
+// Rewrites the tracking item with the description and a checklist line for each domain with a PR
+func (bit *BigIsTiny) updateTracking(ctx context.Context, config *BigChange) error {
+	log := LoggerFromContext(ctx)
+	settings := config.Settings
+	tracking := settings.Tracking
+	platform := bit.gitOps.platform
+
+	lines := make([]string, len(config.Domains))
+	errGrp := new(errgroup.Group)
+	for i, domain := range config.Domains {
+		if domain.PullRequest.Url == "" {
+			continue
+		}
+		errGrp.Go(func() error {
+			prStatus, err := platform.PrStatus(ctx, settings, domain.Branch.Name)
+			if err != nil {
+				log.Error("failed to get Pull Request status", "branch", domain.Branch.Name)
+				return err
+			}
+			lines[i] = config.trackingLine(domain, prStatus)
+			return nil
+		})
+	}
+	if err := errGrp.Wait(); err != nil {
+		return err
+	}
+
+	var body []string
+	if tracking.Description != "" {
+		body = append(body, config.generateFromTemplate(&Domain{}, tracking.Description), "")
+	}
+	for _, line := range lines {
+		if line != "" {
+			body = append(body, line)
+		}
+	}
+
+	title := config.generateFromTemplate(&Domain{}, tracking.Title)
+	var err error
+	switch tracking.Type {
+	case TrackingIssue:
+		err = platform.UpdateIssue(ctx, settings, config.TrackingUrl, title, strings.Join(body, "\n"))
+	case TrackingPr:
+		err = platform.UpdatePr(ctx, settings, settings.BranchToSplit, title, strings.Join(body, "\n"))
+	}
+	if err != nil {
+		log.Error("failed to update the tracking "+string(tracking.Type), "url", config.TrackingUrl, "error", err)
+		return err
+	}
+	return nil
+}
+
+// On top of the usual placeholders the tracking template has the state of the PR
+func (bigChange *BigChange) trackingLine(domain *Domain, prStatus *PrStatus) string {
+	checked := " "
+	if prStatus.State == PrStateMerged {
+		checked = "x"
+	}
+	r := strings.NewReplacer(
+		"{{pr_state}}", string(prStatus.State),
+		"{{pr_checked}}", checked,
+	)
+	return bigChange.generateFromTemplate(domain, r.Replace(bigChange.Settings.TrackingTemplate))
+}
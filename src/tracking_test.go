@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var openTrackingTests = []struct {
+	description  string
+	tracking     *Tracking
+	gitOps       *GitOps
+	expectedUrl  string
+	expectedBody string
+	expectedErr  error
+}{
+	{
+		description: "Existing issue is reused",
+		tracking:    &Tracking{Type: TrackingIssue, Title: "big change split"},
+		gitOps: fixtureGitOps(func(g *GitOps) {
+			platformOf(g).findIssue = func(ctx context.Context, s1 *Settings, s2 string) (string, error) {
+				if s2 != "big change split" {
+					return "", fmt.Errorf("unexpected title '%s'", s2)
+				}
+				return "https://example.com/issues/1", nil
+			}
+			platformOf(g).createIssue = func(ctx context.Context, s1 *Settings, s2, s3 string) (string, error) {
+				return "", fmt.Errorf("createIssue should not be called")
+			}
+		}),
+		expectedUrl:  "https://example.com/issues/1",
+		expectedBody: "Tracked in https://example.com/issues/1",
+	},
+	{
+		description: "Missing issue is created",
+		tracking:    &Tracking{Type: TrackingIssue, Title: "big change split", Description: "Split of the big change"},
+		gitOps: fixtureGitOps(func(g *GitOps) {
+			platformOf(g).findIssue = func(ctx context.Context, s1 *Settings, s2 string) (string, error) { return "", nil }
+			platformOf(g).createIssue = func(ctx context.Context, s1 *Settings, s2, s3 string) (string, error) {
+				if s3 != "Split of the big change" {
+					return "", fmt.Errorf("unexpected description '%s'", s3)
+				}
+				return "https://example.com/issues/2", nil
+			}
+		}),
+		expectedUrl:  "https://example.com/issues/2",
+		expectedBody: "Tracked in https://example.com/issues/2",
+	},
+	{
+		description: "Existing PR of the branch to split becomes the umbrella PR",
+		tracking:    &Tracking{Type: TrackingPr, Title: "big change split"},
+		gitOps: fixtureGitOps(func(g *GitOps) {
+			platformOf(g).findPr = func(ctx context.Context, s1 *Settings, s2 string) (string, error) {
+				return s2 + "/pr", nil
+			}
+			platformOf(g).createPr = func(ctx context.Context, s1 *Settings, s2, s3, s4 string) (string, error) {
+				return "", fmt.Errorf("createPr should not be called")
+			}
+		}),
+		expectedUrl:  "big-change-to-split/pr",
+		expectedBody: "Tracked in big-change-to-split/pr",
+	},
+	{
+		description: "Umbrella PR is created as draft",
+		tracking:    &Tracking{Type: TrackingPr, Title: "big change split"},
+		gitOps: fixtureGitOps(func(g *GitOps) {
+			platformOf(g).createPr = func(ctx context.Context, s1 *Settings, s2, s3, s4 string) (string, error) {
+				if !s1.IsDraftPrs {
+					return "", fmt.Errorf("the umbrella PR should be a draft")
+				}
+				return s2 + "/draft-pr", nil
+			}
+		}),
+		expectedUrl:  "big-change-to-split/draft-pr",
+		expectedBody: "Tracked in big-change-to-split/draft-pr",
+	},
+	{
+		description: "Fail on issues not supported by the platform",
+		tracking:    &Tracking{Type: TrackingIssue, Title: "big change split"},
+		gitOps:      fixtureGitOps(),
+		expectedErr: fmt.Errorf("issues not supported by the platform"),
+	},
+	{
+		description: "Fail on umbrella PR with a branchless platform",
+		tracking:    &Tracking{Type: TrackingPr, Title: "big change split"},
+		gitOps: fixtureGitOps(func(g *GitOps) {
+			g.platform = Gerrit{}
+		}),
+		expectedErr: fmt.Errorf("umbrella PR not supported by the platform"),
+	},
+}
+
+func TestOpenTracking(t *testing.T) {
+	ctxWithSilentLogger := ContextWithSilentLogger(context.Background())
+
+	for _, tt := range openTrackingTests {
+		t.Run(tt.description, func(t *testing.T) {
+			config := fixtureBigChange(func(bc *BigChange) {
+				bc.Settings.PrDescTemplate = "Tracked in {{tracking_url}}"
+				bc.Settings.Tracking = tt.tracking
+			})
+			bit := &BigIsTiny{gitOps: tt.gitOps}
+			gotErr := bit.openTracking(ctxWithSilentLogger, config)
+
+			// We get an error when we don't expect it or we don't get one when we expect it
+			if tt.expectedErr != nil != (gotErr != nil) {
+				t.Errorf("got '%v', want '%v'", gotErr, tt.expectedErr)
+			}
+			// We get a different error of what's expected
+			if tt.expectedErr != nil && gotErr != nil &&
+				tt.expectedErr.Error() != gotErr.Error() {
+				t.Errorf("got '%v', want '%v'", gotErr, tt.expectedErr)
+			}
+
+			diff := cmp.Diff(config.TrackingUrl, tt.expectedUrl)
+			if diff != "" {
+				t.Errorf("%v", diff)
+			}
+			diff = cmp.Diff(config.Domains[0].PullRequest.Body, tt.expectedBody)
+			if diff != "" {
+				t.Errorf("%v", diff)
+			}
+		})
+	}
+}
+
+// dom1 has a merged PR, dom2 an open one and dom3 no PR, the update of the tracking item is recorded
+func fixtureTrackingGitOps(gotUpdate *[]string, mods ...func(*GitOps)) *GitOps {
+	return fixtureGitOps(append([]func(*GitOps){func(g *GitOps) {
+		platformOf(g).prStatus = func(ctx context.Context, s1 *Settings, s2 string) (*PrStatus, error) {
+			if s2 == "bit-dom1-big-change-split" {
+				return &PrStatus{State: PrStateMerged}, nil
+			}
+			return &PrStatus{State: PrStateOpen}, nil
+		}
+		platformOf(g).updateIssue = func(ctx context.Context, s1 *Settings, s2, s3, s4 string) error {
+			*gotUpdate = []string{s2, s3, s4}
+			return nil
+		}
+		platformOf(g).updatePr = func(ctx context.Context, s1 *Settings, s2, s3, s4 string) error {
+			*gotUpdate = []string{s2, s3, s4}
+			return nil
+		}
+	}}, mods...)...)
+}
+
+var updateTrackingTests = []struct {
+	description    string
+	tracking       *Tracking
+	gitOps         func(gotUpdate *[]string) *GitOps
+	expectedUpdate []string
+	expectedErr    error
+}{
+	{
+		description: "Issue is rewritten with the description and a line for each PR",
+		tracking:    &Tracking{Type: TrackingIssue, Title: "big change split", Description: "Split of the big change"},
+		gitOps: func(gotUpdate *[]string) *GitOps {
+			return fixtureTrackingGitOps(gotUpdate)
+		},
+		expectedUpdate: []string{
+			"https://example.com/issues/1",
+			"big change split",
+			"Split of the big change\n\n- [x] AA merged\n- [ ] BB open",
+		},
+	},
+	{
+		description: "Umbrella PR is rewritten on the branch to split",
+		tracking:    &Tracking{Type: TrackingPr, Title: "big change split"},
+		gitOps: func(gotUpdate *[]string) *GitOps {
+			return fixtureTrackingGitOps(gotUpdate)
+		},
+		expectedUpdate: []string{
+			"big-change-to-split",
+			"big change split",
+			"- [x] AA merged\n- [ ] BB open",
+		},
+	},
+	{
+		description: "Fail on prStatus",
+		tracking:    &Tracking{Type: TrackingIssue, Title: "big change split"},
+		gitOps: func(gotUpdate *[]string) *GitOps {
+			return fixtureTrackingGitOps(gotUpdate, func(g *GitOps) {
+				platformOf(g).prStatus = func(ctx context.Context, s1 *Settings, s2 string) (*PrStatus, error) {
+					return nil, fmt.Errorf("prStatus failed")
+				}
+			})
+		},
+		expectedErr: fmt.Errorf("prStatus failed"),
+	},
+	{
+		description: "Fail on updateIssue",
+		tracking:    &Tracking{Type: TrackingIssue, Title: "big change split"},
+		gitOps: func(gotUpdate *[]string) *GitOps {
+			return fixtureTrackingGitOps(gotUpdate, func(g *GitOps) {
+				platformOf(g).updateIssue = func(ctx context.Context, s1 *Settings, s2, s3, s4 string) error {
+					return fmt.Errorf("updateIssue failed")
+				}
+			})
+		},
+		expectedErr: fmt.Errorf("updateIssue failed"),
+	},
+}
+
+func TestUpdateTracking(t *testing.T) {
+	ctxWithSilentLogger := ContextWithSilentLogger(context.Background())
+
+	for _, tt := range updateTrackingTests {
+		t.Run(tt.description, func(t *testing.T) {
+			var gotUpdate []string
+			config := fixtureBigChange(func(bc *BigChange) {
+				bc.TrackingUrl = "https://example.com/issues/1"
+				bc.Settings.Tracking = tt.tracking
+				bc.Settings.TrackingTemplate = "- [{{pr_checked}}] {{domain_id}} {{pr_state}}"
+				for _, domain := range bc.Domains {
+					domain.Branch = &Branch{Name: "bit-" + domain.Name + "-big-change-split"}
+				}
+				bc.Domains[0].PullRequest.Url = "https://example.com/pr/1"
+				bc.Domains[1].PullRequest.Url = "https://example.com/pr/2"
+			})
+			bit := &BigIsTiny{gitOps: tt.gitOps(&gotUpdate)}
+			gotErr := bit.updateTracking(ctxWithSilentLogger, config)
+
+			// We get an error when we don't expect it or we don't get one when we expect it
+			if tt.expectedErr != nil != (gotErr != nil) {
+				t.Errorf("got '%v', want '%v'", gotErr, tt.expectedErr)
+			}
+			// We get a different error of what's expected
+			if tt.expectedErr != nil && gotErr != nil &&
+				tt.expectedErr.Error() != gotErr.Error() {
+				t.Errorf("got '%v', want '%v'", gotErr, tt.expectedErr)
+			}
+
+			diff := cmp.Diff(gotUpdate, tt.expectedUpdate)
+			if diff != "" {
+				t.Errorf("%v", diff)
+			}
+		})
+	}
+}
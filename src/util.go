@@ -14,6 +14,8 @@ func (bigChange *BigChange) generateFromTemplate(domain *Domain, template string
 		"{{domain_name}}", domain.Name,
 		"{{pr_title}}", domain.PullRequest.Title,
 		"{{pr_url}}", domain.PullRequest.Url,
+		"{{tracking_url}}", bigChange.TrackingUrl,
+		"{{team_names}}", domain.teamNames(),
 	}
 	for i, team := range domain.Teams {
 		replacements = append(replacements,
@@ -25,11 +27,63 @@ func (bigChange *BigChange) generateFromTemplate(domain *Domain, template string
 	return r.Replace(template)
 }
 
+func (domain *Domain) teamNames() string {
+	names := make([]string, 0, len(domain.Teams))
+	for _, team := range domain.Teams {
+		names = append(names, team.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// Reviewers of all the domain teams, without duplicates
+func (domain *Domain) reviewers() []string {
+	var reviewers []string
+	for _, team := range domain.Teams {
+		reviewers = append(reviewers, team.Reviewers...)
+	}
+	return dedup(reviewers)
+}
+
+// Labels of the settings and of the domain, with their placeholders replaced
+func (bigChange *BigChange) prLabels(domain *Domain) []string {
+	var labels []string
+	for _, label := range append(append([]string{}, bigChange.Settings.Labels...), domain.Labels...) {
+		if label = bigChange.generateFromTemplate(domain, label); label != "" {
+			labels = append(labels, label)
+		}
+	}
+	return dedup(labels)
+}
+
+// The domain milestone overrides the settings one
+func (domain *Domain) prMilestone(settings *Settings) string {
+	if domain.Milestone != "" {
+		return domain.Milestone
+	}
+	return settings.Milestone
+}
+
+func (domain *Domain) prWorkItems(settings *Settings) []int {
+	return dedup(append(append([]int{}, settings.WorkItems...), domain.WorkItems...))
+}
+
+// Keeps the first occurrence of each value
+func dedup[T comparable](values []T) []T {
+	var unique []T
+	seen := make(map[T]bool)
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
+
 func (bit *BigIsTiny) cleanup(ctx context.Context, bigChange *BigChange) {
 	log := LoggerFromContext(ctx)
 	log.Debug("remove all branches and PRs")
 
-	_ = bit.gitOps.gitCheckout(ctx, bigChange.Settings.MainBranch)
 	var wg sync.WaitGroup
 	for _, domain := range bigChange.Domains {
 		wg.Add(1)
@@ -38,8 +92,10 @@ func (bit *BigIsTiny) cleanup(ctx context.Context, bigChange *BigChange) {
 			// Errors are expected to be logged here as branch existence is not checked
 			_ = bit.gitOps.gitDeleteBranch(ctx, domain.Branch.Name)
 			_ = bit.gitOps.gitDeleteRemoteBranch(ctx, bigChange.Settings.Remote, domain.Branch.Name)
-			_ = bit.gitOps.abandonPr(ctx, domain.Branch.Name)
+			_ = bit.gitOps.platform.ClosePr(ctx, bigChange.Settings, domain.Branch.Name)
 		}()
 	}
 	wg.Wait()
+
+	_ = bit.stateOps.deleteState(ctx, bigChange.Id)
 }
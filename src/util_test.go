@@ -7,8 +7,9 @@ import (
 )
 
 type givenGenerateFromTemplate struct {
-	domain   *Domain
-	template string
+	domain      *Domain
+	template    string
+	trackingUrl string
 }
 
 var generateFromTemplateTests = []struct {
@@ -37,11 +38,22 @@ var generateFromTemplateTests = []struct {
 		},
 		expectedResult: "[BIT001] AA backend: Big change split backend, principal(team1.com), secondary(team2.com)\n{{team_name_3}}({{team_url_3}})",
 	},
+	{
+		description: "Team names and tracking url",
+		given: &givenGenerateFromTemplate{
+			domain: &Domain{
+				Teams: []Team{{Name: "principal"}, {Name: "secondary"}},
+			},
+			template:    "Teams: {{team_names}}, tracked in {{tracking_url}}",
+			trackingUrl: "https://example.com/issues/7",
+		},
+		expectedResult: "Teams: principal, secondary, tracked in https://example.com/issues/7",
+	},
 }
 
 func TestGenerateFromTemplate(t *testing.T) {
 	for _, tt := range generateFromTemplateTests {
-		svc := &BigChange{Id: "BIT001"}
+		svc := &BigChange{Id: "BIT001", TrackingUrl: tt.given.trackingUrl}
 
 		gotResult := svc.generateFromTemplate(tt.given.domain, tt.given.template)
 
@@ -52,3 +64,48 @@ func TestGenerateFromTemplate(t *testing.T) {
 		}
 	}
 }
+
+var prLabelsTests = []struct {
+	description    string
+	settings       *Settings
+	domain         *Domain
+	expectedResult []string
+}{
+	{
+		description: "Settings and domain labels with placeholders",
+		settings:    &Settings{Labels: []string{"big-change", "{{change_id}}"}},
+		domain:      &Domain{Name: "backend", Labels: []string{"domain:{{domain_name}}"}},
+		expectedResult: []string{
+			"big-change",
+			"BIT001",
+			"domain:backend",
+		},
+	},
+	{
+		description:    "Duplicated and empty labels are skipped",
+		settings:       &Settings{Labels: []string{"backend", ""}},
+		domain:         &Domain{Name: "backend", Labels: []string{"{{domain_name}}"}},
+		expectedResult: []string{"backend"},
+	},
+	{
+		description:    "No labels",
+		settings:       &Settings{},
+		domain:         &Domain{Name: "backend"},
+		expectedResult: nil,
+	},
+}
+
+func TestPrLabels(t *testing.T) {
+	for _, tt := range prLabelsTests {
+		t.Run(tt.description, func(t *testing.T) {
+			svc := &BigChange{Id: "BIT001", Settings: tt.settings}
+
+			gotResult := svc.prLabels(tt.domain)
+
+			diff := cmp.Diff(gotResult, tt.expectedResult)
+			if diff != "" {
+				t.Errorf("%v", diff)
+			}
+		})
+	}
+}